@@ -0,0 +1,178 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dipt/internal/docker"
+	"dipt/internal/types"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Entry 批量拉取列表中的一项
+type Entry struct {
+	Image    string `json:"image" yaml:"image"`
+	Platform string `json:"platform,omitempty" yaml:"platform,omitempty"` // 形如 linux/arm64，多个平台用逗号分隔，all 表示全部平台，留空使用默认平台
+	Output   string `json:"output,omitempty" yaml:"output,omitempty"`     // 输出文件名，相对路径基于保存目录
+}
+
+// listFile YAML/JSON 列表文件结构
+type listFile struct {
+	Images []Entry `json:"images" yaml:"images"`
+}
+
+// UnmarshalYAML 允许列表项直接写成镜像名字符串
+func (e *Entry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		e.Image = node.Value
+		return nil
+	}
+	type plain Entry
+	return node.Decode((*plain)(e))
+}
+
+// UnmarshalJSON 允许列表项直接写成镜像名字符串
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &e.Image)
+	}
+	type plain Entry
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// PlatformSelection 条目的平台选择：单个平台、多个平台或全部平台
+type PlatformSelection struct {
+	All       bool
+	Platforms []types.Platform
+}
+
+// Multi 是否需要按多平台镜像索引拉取
+func (s PlatformSelection) Multi() bool {
+	return s.All || len(s.Platforms) > 1
+}
+
+// ResolvePlatforms 解析条目平台，未指定时返回默认选择
+func (e Entry) ResolvePlatforms(def PlatformSelection) (PlatformSelection, error) {
+	if e.Platform == "" {
+		return def, nil
+	}
+	all, platforms, err := ParsePlatforms(e.Platform)
+	return PlatformSelection{All: all, Platforms: platforms}, err
+}
+
+// ParsePlatforms 解析逗号分隔的平台列表，all 表示全部平台
+func ParsePlatforms(s string) (all bool, platforms []types.Platform, err error) {
+	if strings.TrimSpace(s) == docker.AllPlatformsLabel {
+		return true, nil, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		p, err := ParsePlatform(part)
+		if err != nil {
+			return false, nil, err
+		}
+		platforms = append(platforms, p)
+	}
+	return false, platforms, nil
+}
+
+// ParsePlatform 解析 os/arch 形式的平台字符串
+func ParsePlatform(s string) (types.Platform, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.Platform{}, fmt.Errorf("无效的平台格式: %s (应为 os/arch)", s)
+	}
+	return types.Platform{OS: parts[0], Arch: parts[1]}, nil
+}
+
+// ParseFile 读取镜像列表文件
+// .yaml/.yml/.json 按结构化格式解析，其余按纯文本（每行一个镜像，# 开头为注释）解析
+func ParseFile(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取列表文件失败: %v", err)
+	}
+
+	var entries []Entry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		entries, err = parseYAML(data)
+	case ".json":
+		entries, err = parseJSON(data)
+	default:
+		entries, err = parseText(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析列表文件失败: %v", err)
+	}
+
+	for i, e := range entries {
+		entries[i].Image = strings.TrimSpace(e.Image)
+		if entries[i].Image == "" {
+			return nil, fmt.Errorf("列表文件第 %d 项缺少镜像名称", i+1)
+		}
+		if _, err := e.ResolvePlatforms(PlatformSelection{}); err != nil {
+			return nil, fmt.Errorf("列表文件第 %d 项: %v", i+1, err)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("列表文件 %s 中没有镜像", path)
+	}
+	return entries, nil
+}
+
+// parseYAML 支持顶层 images 字段或直接为列表
+func parseYAML(data []byte) ([]Entry, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	if len(node.Content) == 0 {
+		return nil, nil
+	}
+	root := node.Content[0]
+	if root.Kind == yaml.SequenceNode {
+		var entries []Entry
+		err := root.Decode(&entries)
+		return entries, err
+	}
+	var lf listFile
+	err := root.Decode(&lf)
+	return lf.Images, err
+}
+
+// parseJSON 支持顶层 images 字段或直接为数组
+func parseJSON(data []byte) ([]Entry, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var entries []Entry
+		err := json.Unmarshal(data, &entries)
+		return entries, err
+	}
+	var lf listFile
+	err := json.Unmarshal(data, &lf)
+	return lf.Images, err
+}
+
+// parseText 每行一个镜像引用，忽略空行与注释
+func parseText(data []byte) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		entries = append(entries, Entry{Image: line})
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,120 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dipt/internal/docker"
+	"dipt/internal/types"
+)
+
+func writeList(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []Entry
+	}{
+		{
+			name:    "plain text",
+			file:    "images.txt",
+			content: "nginx:1.27\n# comment\n\n  redis:7  # trailing\n",
+			want:    []Entry{{Image: "nginx:1.27"}, {Image: "redis:7"}},
+		},
+		{
+			name: "yaml with images key",
+			file: "images.yaml",
+			content: "images:\n" +
+				"  - alpine:3.20\n" +
+				"  - image: nginx:1.27\n" +
+				"    platform: linux/arm64\n" +
+				"    output: nginx.tar\n",
+			want: []Entry{
+				{Image: "alpine:3.20"},
+				{Image: "nginx:1.27", Platform: "linux/arm64", Output: "nginx.tar"},
+			},
+		},
+		{
+			name:    "json array",
+			file:    "images.json",
+			content: `["alpine:3.20", {"image": "redis:7", "platform": "linux/amd64"}]`,
+			want: []Entry{
+				{Image: "alpine:3.20"},
+				{Image: "redis:7", Platform: "linux/amd64"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFile(writeList(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseFile() got %d entries, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	if _, err := ParseFile(writeList(t, "empty.txt", "# nothing\n")); err == nil {
+		t.Error("expected error for empty list")
+	}
+	if _, err := ParseFile(writeList(t, "bad.yaml", "images:\n  - image: nginx\n    platform: linux\n")); err == nil {
+		t.Error("expected error for invalid platform")
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	p := types.Platform{OS: "linux", Arch: "arm64"}
+	if got := OutputPath(Entry{Image: "nginx:1.27", Output: "n.tar"}, p, docker.FormatDocker, "/data"); got != filepath.Join("/data", "n.tar") {
+		t.Errorf("relative output = %s", got)
+	}
+	if got := OutputPath(Entry{Image: "nginx:1.27"}, p, docker.FormatDocker, "/data"); got != filepath.Join("/data", "nginx_1.27_linux_arm64.tar") {
+		t.Errorf("generated output = %s", got)
+	}
+	if got := OutputPath(Entry{Image: "nginx:1.27"}, p, docker.FormatOCI, "/data"); got != filepath.Join("/data", "nginx_1.27_linux_arm64") {
+		t.Errorf("generated oci output = %s", got)
+	}
+}
+
+func TestParsePlatforms(t *testing.T) {
+	all, platforms, err := ParsePlatforms("all")
+	if err != nil || !all || platforms != nil {
+		t.Errorf("ParsePlatforms(all) = %v, %v, %v", all, platforms, err)
+	}
+	all, platforms, err = ParsePlatforms("linux/amd64, linux/arm64")
+	if err != nil || all || len(platforms) != 2 || platforms[1] != (types.Platform{OS: "linux", Arch: "arm64"}) {
+		t.Errorf("ParsePlatforms(list) = %v, %v, %v", all, platforms, err)
+	}
+	if _, _, err := ParsePlatforms("linux/amd64,arm64"); err == nil {
+		t.Error("expected error for invalid platform in list")
+	}
+}
+
+func TestMultiOutputPath(t *testing.T) {
+	sel := PlatformSelection{Platforms: []types.Platform{{OS: "linux", Arch: "amd64"}, {OS: "linux", Arch: "arm64"}}}
+	if got := MultiOutputPath(Entry{Image: "nginx:1.27"}, sel, docker.FormatOCIArchive, "/data"); got != filepath.Join("/data", "nginx_1.27_linux-amd64_linux-arm64.oci.tar") {
+		t.Errorf("generated output = %s", got)
+	}
+	if got := MultiOutputPath(Entry{Image: "nginx:1.27"}, PlatformSelection{All: true}, docker.FormatOCI, ""); got != "nginx_1.27_all" {
+		t.Errorf("generated all output = %s", got)
+	}
+}
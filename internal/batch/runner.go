@@ -0,0 +1,281 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"dipt/internal/docker"
+	"dipt/internal/errors"
+	"dipt/internal/history"
+	"dipt/internal/types"
+)
+
+// Options 批量拉取选项
+type Options struct {
+	Config          types.Config
+	DefaultPlatform types.Platform
+	// 未指定平台的条目按多平台拉取时的默认选择，优先于 DefaultPlatform
+	AllPlatforms bool
+	Platforms    []types.Platform
+	Format       docker.OutputFormat // 多平台条目在 docker 格式下改用 oci-archive
+	// 输出压缩与分卷，自动生成的文件名会带上压缩扩展名
+	docker.OutputOptions
+	SaveDir string
+	// Context 被取消时中断当前条目，并跳过尚未开始的条目
+	Context context.Context
+	// History 非空时将每一项的拉取记入历史
+	History *history.Store
+
+	OnStart    func(index int, entry Entry, outputFile string) // 开始拉取某一项
+	OnProgress func(index int, downloaded, total int64)        // 某一项的下载进度
+	OnLog      func(index int, level, msg string)              // 某一项的日志
+	OnWritten  func(index int, written int64)                  // 某一项已写入输出文件的字节数
+	OnDone     func(index int, result Result)                  // 某一项结束
+}
+
+// Result 单项拉取结果
+type Result struct {
+	Entry      Entry
+	Platform   types.Platform
+	Platforms  PlatformSelection // 多平台条目的平台选择
+	OutputFile string
+	Duration   time.Duration
+	Pull       *docker.PullResult // 拉取成功时的详细结果
+	Err        error
+}
+
+// OutputPath 计算条目的输出文件路径
+func OutputPath(entry Entry, platform types.Platform, format docker.OutputFormat, saveDir string) string {
+	if entry.Output == "" {
+		return docker.DefaultOutputPath(entry.Image, platform, format, saveDir)
+	}
+	if filepath.IsAbs(entry.Output) || saveDir == "" {
+		return entry.Output
+	}
+	return filepath.Join(saveDir, entry.Output)
+}
+
+// MultiOutputPath 计算多平台条目的输出文件路径
+func MultiOutputPath(entry Entry, sel PlatformSelection, format docker.OutputFormat, saveDir string) string {
+	if entry.Output == "" {
+		return docker.DefaultMultiOutputPath(entry.Image, sel.All, sel.Platforms, format, saveDir)
+	}
+	return OutputPath(entry, types.Platform{}, format, saveDir)
+}
+
+// PlatformLabel 返回结果的平台描述
+func (r Result) PlatformLabel() string {
+	if r.Platforms.Multi() {
+		return docker.PlatformsLabel(r.Platforms.All, r.Platforms.Platforms)
+	}
+	if r.Platform.OS == "" {
+		return "-"
+	}
+	return r.Platform.OS + "/" + r.Platform.Arch
+}
+
+// Run 依次拉取所有条目，单项失败不会中断后续条目；Context 被取消后其余条目记为已取消
+func Run(entries []Entry, opts Options) []Result {
+	results := make([]Result, len(entries))
+	for i, entry := range entries {
+		results[i] = runOne(i, entry, opts, nil)
+		if opts.OnDone != nil {
+			opts.OnDone(i, results[i])
+		}
+	}
+	return results
+}
+
+// RunBundle 依次拉取所有条目，并将成功的镜像合并写入 bundleFile（docker save 格式）
+// 各镜像先暂存到同一个临时 OCI layout 中，相同的层只下载一次；条目的 output 与 Format 被忽略
+// 单项失败不会中断后续条目，返回的 error 仅表示打包本身失败
+func RunBundle(entries []Entry, opts Options, bundleFile string) ([]Result, *docker.BundleInfo, error) {
+	if err := os.MkdirAll(filepath.Dir(bundleFile), 0755); err != nil {
+		return nil, nil, fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	stagingDir, err := os.MkdirTemp(filepath.Dir(bundleFile), ".dipt-bundle-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	target := &bundleTarget{dir: stagingDir, file: bundleFile}
+	results := make([]Result, len(entries))
+	for i, entry := range entries {
+		results[i] = runOne(i, entry, opts, target)
+		if opts.OnDone != nil {
+			opts.OnDone(i, results[i])
+		}
+	}
+	if opts.Context != nil && opts.Context.Err() != nil {
+		return results, nil, errors.NewCanceledError(opts.Context.Err())
+	}
+	if Failed(results) == len(results) {
+		return results, nil, fmt.Errorf("所有镜像均拉取失败，未生成打包文件")
+	}
+
+	info, err := docker.WriteBundle(stagingDir, bundleFile, opts.OutputOptions)
+	if err != nil {
+		return results, nil, err
+	}
+	var pulls []*docker.PullResult
+	for _, r := range results {
+		if r.Err == nil && r.Pull != nil {
+			pulls = append(pulls, r.Pull)
+		}
+	}
+	if err := docker.WriteBundleMetadata(bundleFile, opts.OutputOptions, info, pulls); err != nil && opts.OnLog != nil {
+		opts.OnLog(len(entries)-1, "warning", err.Error())
+	}
+	return results, info, nil
+}
+
+// bundleTarget 打包模式下的暂存目录与最终打包文件
+type bundleTarget struct {
+	dir  string
+	file string
+}
+
+// runOne 拉取单个条目，bundle 非空时写入其暂存 OCI layout 供打包使用
+func runOne(index int, entry Entry, opts Options, bundle *bundleTarget) Result {
+	start := time.Now()
+	result := Result{Entry: entry}
+	if opts.Context != nil && opts.Context.Err() != nil {
+		result.Err = errors.NewCanceledError(opts.Context.Err())
+		return result
+	}
+
+	def := PlatformSelection{All: opts.AllPlatforms, Platforms: opts.Platforms}
+	if !def.Multi() {
+		def = PlatformSelection{Platforms: []types.Platform{opts.DefaultPlatform}}
+	}
+	sel, err := entry.ResolvePlatforms(def)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	format := opts.Format
+	pullOutput := ""
+	if bundle != nil {
+		if sel.Multi() {
+			result.Err = fmt.Errorf("打包为 docker save 文件时不支持多平台条目")
+			return result
+		}
+		format = docker.FormatOCI
+		result.Platform = sel.Platforms[0]
+		result.OutputFile = bundle.file
+		pullOutput = bundle.dir
+	} else if sel.Multi() {
+		// docker save 格式无法保存镜像索引，多平台条目改用 oci-archive
+		if format == "" || format == docker.FormatDocker {
+			format = docker.FormatOCIArchive
+		}
+		result.Platforms = sel
+		result.OutputFile = MultiOutputPath(entry, sel, format, opts.SaveDir)
+	} else {
+		result.Platform = sel.Platforms[0]
+		result.OutputFile = OutputPath(entry, result.Platform, format, opts.SaveDir)
+	}
+	if bundle == nil && entry.Output == "" && !format.IsDir() {
+		result.OutputFile = docker.AppendCompressionExtension(result.OutputFile, opts.Compression)
+	}
+
+	if opts.OnStart != nil {
+		opts.OnStart(index, entry, result.OutputFile)
+	}
+	if err := os.MkdirAll(filepath.Dir(result.OutputFile), 0755); err != nil {
+		result.Err = fmt.Errorf("创建输出目录失败: %v", err)
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	if pullOutput == "" {
+		pullOutput = result.OutputFile
+	}
+	pullOpts := docker.PullOptions{
+		ImageName:  entry.Image,
+		OutputFile: pullOutput,
+		Platform:   result.Platform,
+		Format:     format,
+		Config:     opts.Config,
+		Context:    opts.Context,
+
+		OutputOptions: opts.OutputOptions,
+		// 打包时暂存目录中的产物不需要元数据，由 RunBundle 为打包文件统一写出
+		SkipMetadata: bundle != nil,
+	}
+	if sel.Multi() {
+		pullOpts.AllPlatforms, pullOpts.Platforms = sel.All, sel.Platforms
+	}
+	if opts.OnProgress != nil {
+		pullOpts.OnProgress = func(downloaded, total int64) {
+			opts.OnProgress(index, downloaded, total)
+		}
+	}
+	if opts.OnWritten != nil {
+		pullOpts.OnWritten = func(written int64) {
+			opts.OnWritten(index, written)
+		}
+	}
+	if opts.OnLog != nil {
+		pullOpts.OnLog = func(level, msg string) {
+			opts.OnLog(index, level, msg)
+		}
+	}
+
+	rec := history.Track(opts.History, &pullOpts)
+	if bundle != nil {
+		rec.SetBundle(bundle.file)
+	}
+
+	result.Pull, result.Err = docker.PullAndSave(pullOpts)
+	if result.Pull != nil {
+		result.Pull.OutputFile = result.OutputFile
+	}
+	if err := rec.Finish(result.Pull, result.Err); err != nil && opts.OnLog != nil {
+		opts.OnLog(index, "warning", err.Error())
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// Failed 统计失败项数量
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteSummary 以表格形式输出批量拉取汇总
+func WriteSummary(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\t镜像\t平台\t结果\t耗时\t输出")
+	for i, r := range results {
+		status := "成功"
+		if errors.IsCanceledError(r.Err) {
+			status = "已取消"
+		} else if r.Err != nil {
+			status = "失败"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, r.Entry.Image, r.PlatformLabel(), status,
+			r.Duration.Round(100*time.Millisecond), r.OutputFile)
+	}
+	tw.Flush()
+
+	failed := Failed(results)
+	fmt.Fprintf(w, "\n共 %d 个镜像，成功 %d 个，失败 %d 个\n", len(results), len(results)-failed, failed)
+	for i, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "\n[%d] %s 失败原因:\n%v\n", i+1, r.Entry.Image, r.Err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"dipt/internal/batch"
+	"dipt/internal/config"
+	"dipt/internal/docker"
+	"dipt/internal/history"
+)
+
+// runBatch 处理 dipt batch 子命令
+func runBatch(args []string) int {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	var (
+		osName    string
+		arch      string
+		platforms string
+		allPlat   bool
+		saveDir   string
+		bundle    string
+		imgFmt    string
+		compress  string
+		level     int
+		splitSize string
+		parallel  int
+		limitRate string
+		burst     string
+		format    string
+		quiet     bool
+	)
+	fs.StringVar(&osName, "os", "", "未指定平台的条目使用的操作系统，默认取用户配置")
+	fs.StringVar(&arch, "arch", "", "未指定平台的条目使用的架构，默认取用户配置")
+	fs.StringVar(&platforms, "platforms", "", "未指定平台的条目拉取多个平台保存为一个镜像索引，如 linux/amd64,linux/arm64")
+	fs.BoolVar(&allPlat, "all-platforms", false, "未指定平台的条目拉取全部平台保存为一个镜像索引")
+	fs.StringVar(&saveDir, "d", "", "保存目录，默认取用户配置")
+	fs.StringVar(&saveDir, "dir", "", "同 -d")
+	fs.StringVar(&bundle, "bundle", "", "将所有镜像合并写入一个 docker save 兼容的 tar 文件，可一次 docker load 导入")
+	fs.StringVar(&imgFmt, "format", string(docker.FormatDocker), "镜像保存格式 (docker, oci, oci-archive, rootfs, rootfs-dir)")
+	fs.StringVar(&compress, "compress", "", "输出压缩算法 (none, gzip, zstd)，默认按输出文件扩展名 .gz/.zst 决定")
+	fs.IntVar(&level, "compress-level", 0, "压缩级别，gzip 为 1-9，zstd 为 1-22，0 为默认级别")
+	fs.StringVar(&splitSize, "split", "", "按该大小分卷写入并生成校验清单，如 4GiB、2G、500M，可用 dipt join 合并")
+	fs.IntVar(&parallel, "concurrency", 0, fmt.Sprintf("每个镜像同时下载的层数，默认取配置（未配置时为 %d）", docker.DefaultConcurrency))
+	fs.StringVar(&limitRate, "limit-rate", "", "全局带宽上限（每秒），如 10M、512K，0 表示不限速，默认取配置")
+	fs.StringVar(&burst, "burst", "", "允许瞬时超出带宽上限的数据量，如 4M，默认为上限的 1/10")
+	fs.StringVar(&format, "output-format", formatText, "汇总输出格式 (text, json)")
+	fs.BoolVar(&quiet, "q", false, "仅输出错误信息与汇总")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt batch FILE [--os OS] [--arch ARCH | --platforms LIST | --all-platforms] [-d DIR] [--format FORMAT | --bundle FILE] [--compress ALGO] [--split SIZE] [--concurrency N] [--limit-rate RATE] [--output-format text|json] [-q]")
+		fmt.Fprintln(fs.Output(), "\nFILE 可以是纯文本（每行一个镜像）或 .yaml/.yml/.json 列表:")
+		fmt.Fprintln(fs.Output(), "  images:\n    - image: nginx:1.27\n      platform: linux/arm64\n      output: nginx.tar\n    - image: redis:7\n      platform: all")
+		fmt.Fprintln(fs.Output(), "\nplatform 可写多个平台（逗号分隔）或 all，此类条目保存为镜像索引，docker 格式下改用 oci-archive")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) != 1 {
+		return usageFail(fs, "需要且只能指定一个列表文件")
+	}
+	if !validOutputFormat(format) {
+		return usageFail(fs, "不支持的输出格式: %s", format)
+	}
+	outFormat, err := docker.ParseOutputFormat(imgFmt)
+	if err != nil {
+		return usageFail(fs, "%v", err)
+	}
+	// 多平台条目的格式由 batch.Run 按条目决定，这里不改动单平台条目的格式
+	multiFormat := outFormat
+	multi, err := parsePlatformFlags(fs, platforms, allPlat, &multiFormat)
+	if err != nil {
+		return usageFail(fs, "%v", err)
+	}
+	outOpts, err := parseOutputFlags(compress, level, splitSize, outFormat)
+	if err != nil {
+		return usageFail(fs, "%v", err)
+	}
+	if outOpts.Compression != "" {
+		if err := docker.ValidateCompressionLevel(outOpts.Compression, level); err != nil {
+			return usageFail(fs, "%v", err)
+		}
+	}
+
+	entries, err := batch.ParseFile(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	userCfg, effCfg, err := config.LoadEffectiveConfigs()
+	if err != nil {
+		return fail(err)
+	}
+	if err := applyTransferFlags(fs, parallel, limitRate, burst, &effCfg.Transfer); err != nil {
+		return usageFail(fs, "%v", err)
+	}
+	platform := defaultPlatform(userCfg)
+	if osName != "" {
+		platform.OS = osName
+	}
+	if arch != "" {
+		platform.Arch = arch
+	}
+	if len(multi.Platforms) == 1 {
+		platform = multi.Platforms[0]
+	}
+	if !config.IsValidOS(platform.OS) {
+		return usageFail(fs, "不支持的操作系统: %s", platform.OS)
+	}
+	if !config.IsValidArch(platform.Arch) {
+		return usageFail(fs, "不支持的架构: %s", platform.Arch)
+	}
+	if saveDir == "" {
+		saveDir = defaultSaveDir(userCfg)
+	}
+	if bundle != "" {
+		if flagSet(fs, "format") && outFormat != docker.FormatDocker {
+			return usageFail(fs, "--bundle 只能生成 docker 格式的打包文件")
+		}
+		if multi.Multi() {
+			return usageFail(fs, "--bundle 不支持多平台拉取")
+		}
+	}
+
+	return runEntries(entries, batch.Options{
+		Config:          effCfg,
+		DefaultPlatform: platform,
+		AllPlatforms:    multi.All,
+		Platforms:       multi.Platforms,
+		Format:          outFormat,
+		SaveDir:         saveDir,
+		OutputOptions:   outOpts,
+	}, bundle, format, quiet)
+}
+
+// runEntries 执行批量拉取并输出汇总，bundle 非空时合并写入该打包文件
+func runEntries(entries []batch.Entry, opts batch.Options, bundle, format string, quiet bool) int {
+	ctx, stop := interruptContext()
+	defer stop()
+	opts.Context = ctx
+	opts.History = history.DefaultStore()
+
+	var reporter *lineReporter
+	opts.OnStart = func(index int, entry batch.Entry, outputFile string) {
+		reporter = newLineReporter(os.Stderr, quiet)
+		reporter.log("info", fmt.Sprintf("[%d/%d] 拉取 %s -> %s", index+1, len(entries), entry.Image, outputFile))
+	}
+	opts.OnProgress = func(index int, downloaded, total int64) {
+		reporter.progress(downloaded, total)
+	}
+	opts.OnWritten = func(index int, written int64) {
+		reporter.write(written)
+	}
+	opts.OnLog = func(index int, level, msg string) {
+		reporter.log(level, msg)
+	}
+	opts.OnDone = func(index int, result batch.Result) {
+		if result.Err != nil {
+			newLineReporter(os.Stderr, quiet).log("error", fmt.Sprintf("[%d/%d] %s 失败", index+1, len(entries), result.Entry.Image))
+		}
+	}
+
+	var (
+		results   []batch.Result
+		bundleErr error
+	)
+	if bundle == "" {
+		results = batch.Run(entries, opts)
+	} else {
+		var info *docker.BundleInfo
+		results, info, bundleErr = batch.RunBundle(entries, opts, bundle)
+		if bundleErr == nil {
+			target := bundle
+			if info.PartsManifest != "" {
+				target = fmt.Sprintf("%s 的分卷（清单 %s）", bundle, info.PartsManifest)
+			}
+			newLineReporter(os.Stderr, quiet).log("success", fmt.Sprintf("已打包 %d 个引用（%d 个镜像，%d 个层，%s）到 %s",
+				len(info.Refs), info.Images, info.Layers, docker.FormatBytes(info.Size), target))
+		}
+	}
+
+	if format == formatJSON {
+		reports := make([]pullReport, len(results))
+		for i, r := range results {
+			pull := r.Pull
+			if pull == nil {
+				pull = &docker.PullResult{Reference: r.Entry.Image, Platform: r.Platform, OutputFile: r.OutputFile}
+			}
+			reports[i] = newPullReport(pull, r.Err)
+		}
+		if err := writeJSON(os.Stdout, reports); err != nil {
+			return fail(err)
+		}
+	} else {
+		fmt.Println()
+		batch.WriteSummary(os.Stdout, results)
+	}
+	if bundleErr != nil {
+		return fail(bundleErr)
+	}
+	if ctx.Err() != nil {
+		return ExitInterrupted
+	}
+	if batch.Failed(results) > 0 {
+		return ExitFailure
+	}
+	return ExitOK
+}
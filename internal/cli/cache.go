@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"dipt/internal/docker"
+	"dipt/internal/split"
+)
+
+const cacheUsage = `用法: dipt cache <子命令> [参数]
+
+子命令:
+  info                                      显示缓存目录、blob 数量与占用大小
+  prune [--max-size SIZE] [--max-age AGE]   按大小与最近使用时间清理缓存
+  prune --all                               清空缓存
+
+拉取时下载的层按 digest 缓存，中断后重新拉取只下载缺失的层
+缓存目录可通过 DIPT_CACHE_DIR 指定，设置 DIPT_NO_CACHE=1 时不使用缓存
+`
+
+// runCache 处理 dipt cache 子命令
+func runCache(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, cacheUsage)
+		return ExitUsage
+	}
+	switch args[0] {
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, cacheUsage)
+		return ExitOK
+	case "info":
+		return runCacheInfo(args[1:])
+	case "prune":
+		return runCachePrune(args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "错误: 未知的子命令: %s\n\n%s", args[0], cacheUsage)
+	return ExitUsage
+}
+
+func runCacheInfo(args []string) int {
+	fs := flag.NewFlagSet("cache info", flag.ContinueOnError)
+	var format string
+	fs.StringVar(&format, "output-format", formatText, "输出格式 (text, json)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt cache info [--output-format text|json]")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) > 0 {
+		return usageFail(fs, "多余的参数: %s", strings.Join(positional, " "))
+	}
+	if !validOutputFormat(format) {
+		return usageFail(fs, "不支持的输出格式: %s", format)
+	}
+
+	stats, err := docker.NewBlobCache(docker.DefaultCacheDir()).Stats()
+	if err != nil {
+		return fail(err)
+	}
+	if format == formatJSON {
+		if err := writeJSON(os.Stdout, stats); err != nil {
+			return fail(err)
+		}
+		return ExitOK
+	}
+	fmt.Printf("目录:     %s\n", stats.Dir)
+	fmt.Printf("blob:     %d 个，共 %s\n", stats.Blobs, docker.FormatBytes(stats.Size))
+	if stats.Partial > 0 {
+		fmt.Printf("未完成:   %s\n", docker.FormatBytes(stats.Partial))
+	}
+	if stats.Oldest != nil {
+		fmt.Printf("最早使用: %s\n", stats.Oldest.Local().Format("2006-01-02 15:04:05"))
+	}
+	if os.Getenv("DIPT_NO_CACHE") == "1" {
+		fmt.Println("DIPT_NO_CACHE=1，拉取时不使用缓存")
+	}
+	return ExitOK
+}
+
+func runCachePrune(args []string) int {
+	fs := flag.NewFlagSet("cache prune", flag.ContinueOnError)
+	var (
+		maxSize string
+		maxAge  string
+		all     bool
+		format  string
+	)
+	fs.StringVar(&maxSize, "max-size", "", "清理后缓存的最大大小，按最久未使用的顺序删除 (如 10G)")
+	fs.StringVar(&maxAge, "max-age", "", "删除超过该时间未使用的 blob (如 30d、72h)")
+	fs.BoolVar(&all, "all", false, "清空缓存")
+	fs.StringVar(&format, "output-format", formatText, "输出格式 (text, json)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt cache prune [--max-size SIZE] [--max-age AGE] [--all] [--output-format text|json]")
+		fmt.Fprintln(fs.Output(), "\n同时指定 --max-size 与 --max-age 时先删除过期的 blob，再按最久未使用的顺序删除直到不超过大小限制")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) > 0 {
+		return usageFail(fs, "多余的参数: %s", strings.Join(positional, " "))
+	}
+	if !validOutputFormat(format) {
+		return usageFail(fs, "不支持的输出格式: %s", format)
+	}
+	if all == (maxSize != "" || maxAge != "") {
+		return usageFail(fs, "需要指定 --max-size、--max-age 或 --all 之一（--all 不能与其他条件同时使用）")
+	}
+
+	var opts docker.PruneOptions
+	if maxSize != "" {
+		if opts.MaxSize, err = split.ParseSize(maxSize); err != nil {
+			return usageFail(fs, "无效的缓存大小: %s (示例: 10G, 500M)", maxSize)
+		}
+	}
+	if maxAge != "" {
+		if opts.MaxAge, err = parseAge(maxAge); err != nil {
+			return usageFail(fs, "%v", err)
+		}
+	}
+
+	result, err := docker.NewBlobCache(docker.DefaultCacheDir()).Prune(opts)
+	if err != nil {
+		return fail(err)
+	}
+	if format == formatJSON {
+		if err := writeJSON(os.Stdout, result); err != nil {
+			return fail(err)
+		}
+		return ExitOK
+	}
+	fmt.Printf("✅ 已删除 %d 个 blob，释放 %s，缓存剩余 %s\n", result.Removed, docker.FormatBytes(result.Freed), docker.FormatBytes(result.Remaining))
+	return ExitOK
+}
+
+// parseAge 解析时长，除 time.ParseDuration 支持的格式外还支持以天为单位的 d 后缀
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err == nil && n > 0 {
+			return time.Duration(n * float64(24*time.Hour)), nil
+		}
+	} else if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("无效的时长: %s (示例: 30d, 72h)", s)
+}
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"dipt/internal/errors"
+	"dipt/internal/tui"
+	"dipt/internal/version"
+)
+
+// 退出码
+const (
+	ExitOK      = 0 // 成功
+	ExitFailure = 1 // 执行失败
+	ExitUsage   = 2 // 用法错误
+
+	ExitInterrupted = 130 // 被 ctrl+c 中断
+)
+
+// command 子命令定义
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands 返回所有子命令（按帮助中展示的顺序）
+func commands() []command {
+	return []command{
+		{"pull", "拉取镜像并保存为 tar 文件", runPull},
+		{"batch", "按列表文件批量拉取镜像", runBatch},
+		{"join", "校验并合并 --split 生成的分卷", runJoin},
+		{"verify", "校验保存的 tar 文件及其中每一层的 digest", runVerify},
+		{"push", "将保存的镜像文件推送到仓库", runPush},
+		{"copy", "在仓库之间直接复制镜像（不落盘）", runCopy},
+		{"serve", "把保存的镜像作为只读仓库提供给 docker/containerd 拉取", runServe},
+		{"info", "显示已保存镜像的来源信息", runInfo},
+		{"list", "列出保存目录中的已保存镜像", runList},
+		{"cache", "查看或清理已下载层的本地缓存 (info/prune)", runCache},
+		{"mirror", "管理镜像加速器 (list/add/del/clear/test)", runMirror},
+		{"config", "查看或修改用户配置 (get/set/list)", runConfig},
+		{"tui", "启动交互式界面", runTUI},
+		{"version", "显示版本信息", runVersion},
+		{"help", "显示帮助信息", runHelp},
+	}
+}
+
+// Run 执行命令行子命令，返回进程退出码
+func Run(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "-h", "--help":
+		return runHelp(args[1:])
+	case "-v", "--version":
+		return runVersion(args[1:])
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "错误: 未知的命令: %s\n\n", args[0])
+	printUsage(os.Stderr)
+	return ExitUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "用法: dipt [命令] [参数]")
+	fmt.Fprintln(w, "\n不带命令运行时启动交互式界面。\n\n命令:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\n运行 \"dipt help <命令>\" 或 \"dipt <命令> -h\" 查看命令的详细用法")
+}
+
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return ExitOK
+	}
+	for _, cmd := range commands() {
+		if cmd.name == args[0] && cmd.name != "help" {
+			return cmd.run([]string{"-h"})
+		}
+	}
+	fmt.Fprintf(os.Stderr, "错误: 未知的命令: %s\n", args[0])
+	return ExitUsage
+}
+
+func runVersion(args []string) int {
+	fmt.Printf("dipt %s\n", version.Version)
+	return ExitOK
+}
+
+func runTUI(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "用法: dipt tui")
+		if isHelpArg(args[0]) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if err := tui.Run(); err != nil {
+		return fail(err)
+	}
+	return ExitOK
+}
+
+// parseInterspersed 解析允许标志与位置参数交错出现的参数列表
+// 标准库 flag 在遇到第一个位置参数时即停止解析，这里逐段继续解析
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		// "--" 之后的参数全部视为位置参数
+		if rest[0] == "--" {
+			return append(positional, rest[1:]...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// fail 输出错误并返回退出码，用法错误返回 ExitUsage，被中断时返回 ExitInterrupted
+func fail(err error) int {
+	fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+	if errors.IsCanceledError(err) {
+		return ExitInterrupted
+	}
+	if errors.IsUsageError(err) {
+		return ExitUsage
+	}
+	return ExitFailure
+}
+
+// interruptContext 返回收到 SIGINT/SIGTERM 时取消的上下文，用于中断拉取并删除不完整的输出
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// isHelpArg 判断参数是否为帮助标志
+func isHelpArg(arg string) bool {
+	return arg == "-h" || arg == "--help" || arg == "help"
+}
+
+// usageFail 输出用法错误并返回用法错误退出码
+func usageFail(fs *flag.FlagSet, format string, args ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "错误: %s\n\n", fmt.Sprintf(format, args...))
+	fs.Usage()
+	return ExitUsage
+}
+
+// envOr 读取环境变量，为空时返回默认值
+func envOr(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
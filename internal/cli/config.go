@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"dipt/internal/config"
+)
+
+var configUsage = `用法: dipt config <子命令> [参数]
+
+子命令:
+  list                列出所有配置项
+  get <KEY>           读取配置项
+  set <KEY> <VALUE>   修改配置项
+
+配置项: ` + strings.Join(config.ConfigKeys, ", ") + `
+bandwidth 与 burst 接受 10M、512K 等大小（每秒），0 表示不限速；concurrency 与 jobs 为 0 时使用默认值
+镜像加速器请使用 "dipt mirror" 管理
+`
+
+// runConfig 处理 dipt config 子命令
+func runConfig(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, configUsage)
+		return ExitOK
+	case "list":
+		if len(args) != 1 {
+			return configUsageFail("用法: dipt config list")
+		}
+		values, err := config.ListConfigValues()
+		if err != nil {
+			return fail(err)
+		}
+		for _, kv := range values {
+			fmt.Printf("%-12s %s\n", kv[0], kv[1])
+		}
+	case "get":
+		if len(args) != 2 {
+			return configUsageFail("用法: dipt config get <KEY>")
+		}
+		value, err := config.GetConfigValue(args[1])
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Println(value)
+	case "set":
+		if len(args) != 3 {
+			return configUsageFail("用法: dipt config set <KEY> <VALUE>")
+		}
+		if err := config.SetConfigValue(args[1], args[2]); err != nil {
+			return fail(err)
+		}
+		fmt.Printf("✅ 已设置 %s\n", args[1])
+	default:
+		return configUsageFail("未知的子命令: %s", args[0])
+	}
+	return ExitOK
+}
+
+func configUsageFail(format string, args ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "错误: %s\n\n%s", fmt.Sprintf(format, args...), configUsage)
+	return ExitUsage
+}
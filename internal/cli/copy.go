@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"dipt/internal/config"
+	"dipt/internal/docker"
+)
+
+// runCopy 处理 dipt copy 子命令，在仓库之间直接复制镜像
+func runCopy(args []string) int {
+	fs := flag.NewFlagSet("copy", flag.ContinueOnError)
+	var (
+		insecure bool
+		format   string
+		quiet    bool
+	)
+	fs.BoolVar(&insecure, "insecure", false, "使用 HTTP 访问目标仓库")
+	fs.StringVar(&format, "output-format", formatText, "结果输出格式 (text, json)，json 结果写到 stdout")
+	fs.BoolVar(&quiet, "q", false, "仅输出错误信息")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt copy SRC DST [--insecure] [--output-format text|json] [-q]")
+		fmt.Fprintln(fs.Output(), "\n将 SRC 直接复制到 DST，数据不落盘；多平台索引按原样复制，目标已存在的层不会重复上传")
+		fmt.Fprintln(fs.Output(), "DST 未写 tag 时沿用 SRC 的 tag，如 dipt copy redis:7 registry.internal/mirror/redis")
+		fmt.Fprintln(fs.Output(), "源为 Docker Hub 镜像时优先使用配置的镜像加速器")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) != 2 {
+		return usageFail(fs, "需要指定源镜像和目标引用")
+	}
+	if !validOutputFormat(format) {
+		return usageFail(fs, "不支持的输出格式: %s", format)
+	}
+
+	_, effCfg, err := config.LoadEffectiveConfigs()
+	if err != nil {
+		return fail(err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	reporter := newLineReporter(os.Stderr, quiet)
+	result, err := docker.CopyImage(docker.CopyOptions{
+		Source:      positional[0],
+		Destination: positional[1],
+		Insecure:    insecure,
+		Context:     ctx,
+		Config:      effCfg,
+		OnProgress:  reporter.progress,
+		OnLog:       reporter.log,
+	})
+	if err != nil {
+		return fail(err)
+	}
+	if format == formatJSON {
+		if err := writeJSON(os.Stdout, result); err != nil {
+			return fail(err)
+		}
+		return ExitOK
+	}
+	reporter.finish()
+	reporter.log("success", fmt.Sprintf("已复制到 %s (%s)", result.Destination, result.Digest))
+	return ExitOK
+}
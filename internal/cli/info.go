@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"dipt/internal/config"
+	"dipt/internal/docker"
+)
+
+// runInfo 处理 dipt info 子命令，显示输出文件旁元数据文件中记录的来源信息
+func runInfo(args []string) int {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	var format string
+	fs.StringVar(&format, "output-format", formatText, "输出格式 (text, json)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt info FILE... [--output-format text|json]")
+		fmt.Fprintln(fs.Output(), "\nFILE 为拉取保存的文件（或目录），也可直接给出其 .dipt.json 元数据文件")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) == 0 {
+		return usageFail(fs, "需要指定文件")
+	}
+	if !validOutputFormat(format) {
+		return usageFail(fs, "不支持的输出格式: %s", format)
+	}
+
+	var list []*docker.Metadata
+	code := ExitOK
+	for _, path := range positional {
+		md, err := docker.ReadMetadata(path)
+		if err != nil {
+			code = fail(err)
+			continue
+		}
+		list = append(list, md)
+	}
+
+	if format == formatJSON {
+		var v interface{} = list
+		if len(positional) == 1 && len(list) == 1 {
+			v = list[0]
+		}
+		if err := writeJSON(os.Stdout, v); err != nil {
+			return fail(err)
+		}
+		return code
+	}
+	for i, md := range list {
+		if i > 0 {
+			fmt.Println()
+		}
+		printMetadata(os.Stdout, md)
+	}
+	return code
+}
+
+// printMetadata 以文本形式输出元数据
+func printMetadata(w io.Writer, md *docker.Metadata) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, f := range md.Fields() {
+		fmt.Fprintf(tw, "%s:\t%s\n", f[0], f[1])
+	}
+	tw.Flush()
+
+	if len(md.Labels) > 0 {
+		fmt.Fprintln(w, "标签:")
+		for _, l := range md.SortedLabels() {
+			fmt.Fprintf(w, "  %s\n", l)
+		}
+	}
+	if len(md.Images) > 0 {
+		fmt.Fprintf(w, "包含 %d 个镜像:\n", len(md.Images))
+		for _, img := range md.Images {
+			fmt.Fprintf(w, "  %s (%s) %s\n", img.Reference, img.PlatformLabel(), img.ManifestDigest)
+		}
+	}
+}
+
+// runList 处理 dipt list 子命令，列出目录下带有元数据的已保存镜像
+func runList(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	var format string
+	fs.StringVar(&format, "output-format", formatText, "输出格式 (text, json)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt list [DIR] [--output-format text|json]")
+		fmt.Fprintln(fs.Output(), "\n列出目录（默认为保存目录）中已保存的镜像及其来源，按拉取时间从新到旧排列")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) > 1 {
+		return usageFail(fs, "最多指定一个目录")
+	}
+	if !validOutputFormat(format) {
+		return usageFail(fs, "不支持的输出格式: %s", format)
+	}
+
+	dir := ""
+	if len(positional) == 1 {
+		dir = positional[0]
+	} else {
+		userCfg, _, err := config.LoadEffectiveConfigs()
+		if err != nil {
+			return fail(err)
+		}
+		dir = defaultSaveDir(userCfg)
+	}
+
+	list, err := docker.ListMetadata(dir)
+	if err != nil {
+		return fail(err)
+	}
+	if format == formatJSON {
+		if list == nil {
+			list = []*docker.Metadata{}
+		}
+		if err := writeJSON(os.Stdout, list); err != nil {
+			return fail(err)
+		}
+		return ExitOK
+	}
+	if len(list) == 0 {
+		fmt.Fprintf(os.Stderr, "%s 中没有找到已保存的镜像\n", dir)
+		return ExitOK
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "文件\t镜像\t平台\t格式\tDigest\t拉取时间")
+	for _, md := range list {
+		ref := md.Reference
+		if len(md.Images) > 0 {
+			ref = fmt.Sprintf("(%d 个镜像)", len(md.Images))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", md.Output, ref, orDash(md.PlatformLabel()), md.Format,
+			orDash(shortDigest(md.ManifestDigest)), md.PulledAt.Local().Format("2006-01-02 15:04"))
+	}
+	tw.Flush()
+	return ExitOK
+}
+
+// shortDigest 截短 digest 用于表格展示
+func shortDigest(digest string) string {
+	const n = len("sha256:") + 12
+	if len(digest) > n {
+		return digest[:n]
+	}
+	return digest
+}
+
+// orDash 空字符串显示为 -
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"dipt/internal/docker"
+	"dipt/internal/split"
+)
+
+// runJoin 处理 dipt join 子命令，校验并合并分卷
+func runJoin(args []string) int {
+	fs := flag.NewFlagSet("join", flag.ContinueOnError)
+	var (
+		output     string
+		verifyOnly bool
+		quiet      bool
+	)
+	fs.StringVar(&output, "o", "", "合并后的输出文件路径，默认为清单所在目录下的原文件名")
+	fs.StringVar(&output, "output", "", "同 -o")
+	fs.BoolVar(&verifyOnly, "verify", false, "仅校验分卷，不合并")
+	fs.BoolVar(&quiet, "q", false, "仅输出错误信息")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt join MANIFEST [-o FILE] [--verify] [-q]")
+		fmt.Fprintln(fs.Output(), "\nMANIFEST 为 --split 生成的分卷清单（<输出文件>.parts.json），也可直接给出输出文件路径")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) != 1 {
+		return usageFail(fs, "需要指定一个分卷清单")
+	}
+	manifest := positional[0]
+	if _, err := os.Stat(manifest); os.IsNotExist(err) {
+		if _, err := os.Stat(split.ManifestPath(manifest)); err == nil {
+			manifest = split.ManifestPath(manifest)
+		}
+	}
+
+	reporter := newLineReporter(os.Stderr, quiet)
+	onPart := func(i int, part split.Part) {
+		reporter.log("info", fmt.Sprintf("分卷 %s 校验通过（%s）", part.Name, docker.FormatBytes(part.Size)))
+	}
+
+	if verifyOnly {
+		m, err := split.Verify(manifest, onPart)
+		if err != nil {
+			return fail(err)
+		}
+		reporter.log("success", fmt.Sprintf("%d 个分卷全部校验通过，合并后为 %s（%s）", len(m.Parts), m.File, docker.FormatBytes(m.Size)))
+		return ExitOK
+	}
+
+	path, err := split.Join(manifest, output, onPart)
+	if err != nil {
+		return fail(err)
+	}
+	reporter.log("success", fmt.Sprintf("已合并到 %s", path))
+	return ExitOK
+}
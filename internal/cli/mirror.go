@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"dipt/internal/config"
+)
+
+const mirrorUsage = `用法: dipt mirror <子命令> [参数]
+
+子命令:
+  list          列出所有镜像加速器
+  add <URL>     添加镜像加速器
+  del <URL>     删除镜像加速器
+  clear         清空所有镜像加速器
+  test <URL>    测试镜像加速器连通性
+`
+
+// runMirror 处理 dipt mirror 子命令
+func runMirror(args []string) int {
+	if len(args) > 0 && isHelpArg(args[0]) {
+		fmt.Fprint(os.Stdout, mirrorUsage)
+		return ExitOK
+	}
+	if err := config.HandleMirrorCommand(args); err != nil {
+		code := fail(err)
+		if code == ExitUsage {
+			fmt.Fprint(os.Stderr, "\n"+mirrorUsage)
+		}
+		return code
+	}
+	return ExitOK
+}
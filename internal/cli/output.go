@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"dipt/internal/docker"
+)
+
+// 结果输出格式
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// pullReport JSON 模式下输出的单次拉取结果
+type pullReport struct {
+	*docker.PullResult
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+func newPullReport(result *docker.PullResult, err error) pullReport {
+	r := pullReport{PullResult: result, Success: err == nil}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// validOutputFormat 检查输出格式是否受支持
+func validOutputFormat(format string) bool {
+	return format == formatText || format == formatJSON
+}
+
+// writeJSON 以缩进格式输出 JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化结果失败: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
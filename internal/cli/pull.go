@@ -0,0 +1,325 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"dipt/internal/batch"
+	"dipt/internal/config"
+	"dipt/internal/docker"
+	"dipt/internal/history"
+	"dipt/internal/split"
+	"dipt/internal/types"
+)
+
+// runPull 处理 dipt pull 子命令
+func runPull(args []string) int {
+	fs := flag.NewFlagSet("pull", flag.ContinueOnError)
+	var (
+		osName    string
+		arch      string
+		platforms string
+		allPlat   bool
+		output    string
+		imgFmt    string
+		compress  string
+		level     int
+		splitSize string
+		parallel  int
+		limitRate string
+		burst     string
+		format    string
+		quiet     bool
+	)
+	fs.StringVar(&osName, "os", "", "目标操作系统 (linux, windows, darwin)，默认取用户配置")
+	fs.StringVar(&arch, "arch", "", "目标架构 (amd64, arm64, arm, 386)，默认取用户配置")
+	fs.StringVar(&platforms, "platforms", "", "拉取多个平台保存为一个镜像索引，如 linux/amd64,linux/arm64")
+	fs.BoolVar(&allPlat, "all-platforms", false, "拉取镜像的全部平台保存为一个镜像索引")
+	fs.StringVar(&output, "o", "", "输出文件路径，默认在保存目录下自动生成")
+	fs.StringVar(&output, "output", "", "同 -o")
+	fs.StringVar(&imgFmt, "format", string(docker.FormatDocker), "镜像保存格式 (docker, oci, oci-archive, rootfs, rootfs-dir)")
+	fs.StringVar(&compress, "compress", "", "输出压缩算法 (none, gzip, zstd)，默认按输出文件扩展名 .gz/.zst 决定")
+	fs.IntVar(&level, "compress-level", 0, "压缩级别，gzip 为 1-9，zstd 为 1-22，0 为默认级别")
+	fs.StringVar(&splitSize, "split", "", "按该大小分卷写入并生成校验清单，如 4GiB、2G、500M，可用 dipt join 合并")
+	fs.IntVar(&parallel, "concurrency", 0, fmt.Sprintf("每个镜像同时下载的层数，默认取配置（未配置时为 %d）", docker.DefaultConcurrency))
+	fs.StringVar(&limitRate, "limit-rate", "", "全局带宽上限（每秒），如 10M、512K，0 表示不限速，默认取配置")
+	fs.StringVar(&burst, "burst", "", "允许瞬时超出带宽上限的数据量，如 4M，默认为上限的 1/10")
+	fs.StringVar(&format, "output-format", formatText, "结果输出格式 (text, json)，json 结果写到 stdout")
+	fs.BoolVar(&quiet, "q", false, "仅输出错误信息")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt pull IMAGE... [--os OS] [--arch ARCH | --platforms LIST | --all-platforms] [-o FILE] [--format FORMAT] [--compress ALGO] [--split SIZE] [--concurrency N] [--limit-rate RATE] [--output-format text|json] [-q]")
+		fmt.Fprintln(fs.Output(), "\n指定多个镜像时合并写入一个 docker save 兼容的 tar 文件（-o，默认 bundle.tar），共享的层只保存一次")
+		fmt.Fprintln(fs.Output(), "多平台拉取（--platforms/--all-platforms）仅支持 oci 与 oci-archive 格式，未指定 --format 时默认 oci-archive")
+		fmt.Fprintln(fs.Output(), "rootfs 导出按顺序合并所有层（处理 whiteout 与 opaque 目录）写成单个 tar，rootfs-dir 则解压到空目录")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) == 0 {
+		return usageFail(fs, "需要指定镜像名称")
+	}
+	imageName := positional[0]
+	if !validOutputFormat(format) {
+		return usageFail(fs, "不支持的输出格式: %s", format)
+	}
+	outFormat, err := docker.ParseOutputFormat(imgFmt)
+	if err != nil {
+		return usageFail(fs, "%v", err)
+	}
+	multi, err := parsePlatformFlags(fs, platforms, allPlat, &outFormat)
+	if err != nil {
+		return usageFail(fs, "%v", err)
+	}
+	outOpts, err := parseOutputFlags(compress, level, splitSize, outFormat)
+	if err != nil {
+		return usageFail(fs, "%v", err)
+	}
+
+	if len(positional) > 1 {
+		if flagSet(fs, "format") && outFormat != docker.FormatDocker {
+			return usageFail(fs, "指定多个镜像时只能生成 docker 格式的打包文件")
+		}
+		if multi.Multi() {
+			return usageFail(fs, "指定多个镜像时不支持多平台拉取")
+		}
+	}
+
+	userCfg, effCfg, err := config.LoadEffectiveConfigs()
+	if err != nil {
+		return fail(err)
+	}
+	if err := applyTransferFlags(fs, parallel, limitRate, burst, &effCfg.Transfer); err != nil {
+		return usageFail(fs, "%v", err)
+	}
+
+	platform := defaultPlatform(userCfg)
+	if osName != "" {
+		platform.OS = osName
+	}
+	if arch != "" {
+		platform.Arch = arch
+	}
+	if len(multi.Platforms) == 1 {
+		platform = multi.Platforms[0]
+	}
+	if !config.IsValidOS(platform.OS) {
+		return usageFail(fs, "不支持的操作系统: %s", platform.OS)
+	}
+	if !config.IsValidArch(platform.Arch) {
+		return usageFail(fs, "不支持的架构: %s", platform.Arch)
+	}
+
+	if len(positional) > 1 {
+		if output == "" {
+			output = docker.AppendCompressionExtension(filepath.Join(defaultSaveDir(userCfg), "bundle.tar"), outOpts.Compression)
+		}
+		if err := validateCompressionLevel(outOpts, output); err != nil {
+			return usageFail(fs, "%v", err)
+		}
+		entries := make([]batch.Entry, len(positional))
+		for i, image := range positional {
+			entries[i] = batch.Entry{Image: image}
+		}
+		return runEntries(entries, batch.Options{
+			Config:          effCfg,
+			DefaultPlatform: platform,
+			OutputOptions:   outOpts,
+		}, output, format, quiet)
+	}
+
+	if output == "" {
+		if multi.Multi() {
+			output = docker.DefaultMultiOutputPath(imageName, multi.All, multi.Platforms, outFormat, defaultSaveDir(userCfg))
+		} else {
+			output = docker.DefaultOutputPath(imageName, platform, outFormat, defaultSaveDir(userCfg))
+		}
+		if !outFormat.IsDir() {
+			output = docker.AppendCompressionExtension(output, outOpts.Compression)
+		}
+	}
+	if !outFormat.IsDir() {
+		if err := validateCompressionLevel(outOpts, output); err != nil {
+			return usageFail(fs, "%v", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return fail(fmt.Errorf("创建输出目录失败: %v", err))
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	reporter := newLineReporter(os.Stderr, quiet)
+	opts := docker.PullOptions{
+		ImageName:  imageName,
+		OutputFile: output,
+		Platform:   platform,
+		Format:     outFormat,
+		Config:     effCfg,
+		Context:    ctx,
+		OnProgress: reporter.progress,
+		OnLog:      reporter.log,
+		OnWritten:  reporter.write,
+
+		OutputOptions: outOpts,
+	}
+	platformLabel := platform.OS + "/" + platform.Arch
+	if multi.Multi() {
+		opts.AllPlatforms, opts.Platforms = multi.All, multi.Platforms
+		platformLabel = docker.PlatformsLabel(multi.All, multi.Platforms)
+	}
+
+	rec := history.Track(history.DefaultStore(), &opts)
+
+	reporter.log("info", fmt.Sprintf("拉取 %s (%s) -> %s", imageName, platformLabel, output))
+	result, err := docker.PullAndSave(opts)
+	if herr := rec.Finish(result, err); herr != nil {
+		reporter.log("warning", herr.Error())
+	}
+	if err == nil {
+		reporter.finish()
+	}
+
+	if format == formatJSON {
+		if result == nil {
+			result = &docker.PullResult{Reference: imageName, Platform: platform, OutputFile: output}
+		}
+		if werr := writeJSON(os.Stdout, newPullReport(result, err)); werr != nil {
+			return fail(werr)
+		}
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return ExitOK
+}
+
+// parsePlatformFlags 解析 --platforms/--all-platforms，返回多平台选择
+// 选择多个平台且未显式指定 --format 时改用 oci-archive，显式指定其他格式则报错
+func parsePlatformFlags(fs *flag.FlagSet, platforms string, all bool, outFormat *docker.OutputFormat) (batch.PlatformSelection, error) {
+	var sel batch.PlatformSelection
+	if all && platforms != "" {
+		return sel, fmt.Errorf("--platforms 与 --all-platforms 不能同时使用")
+	}
+	if all {
+		sel.All = true
+	} else if platforms != "" {
+		var err error
+		if sel.All, sel.Platforms, err = batch.ParsePlatforms(platforms); err != nil {
+			return sel, err
+		}
+		for _, p := range sel.Platforms {
+			if !config.IsValidOS(p.OS) || !config.IsValidArch(p.Arch) {
+				return sel, fmt.Errorf("不支持的平台: %s/%s", p.OS, p.Arch)
+			}
+		}
+	}
+	if !sel.Multi() {
+		return sel, nil
+	}
+	if !flagSet(fs, "format") {
+		*outFormat = docker.FormatOCIArchive
+	} else if *outFormat != docker.FormatOCI && *outFormat != docker.FormatOCIArchive {
+		return sel, fmt.Errorf("多平台拉取仅支持 oci 或 oci-archive 格式")
+	}
+	return sel, nil
+}
+
+// parseOutputFlags 解析 --compress、--compress-level 与 --split
+// 目录格式不支持压缩；分卷时目录格式会改为写出对应的 tar 流，因此允许压缩
+func parseOutputFlags(compress string, level int, splitSize string, format docker.OutputFormat) (docker.OutputOptions, error) {
+	var opts docker.OutputOptions
+	c, err := docker.ParseCompression(compress)
+	if err != nil {
+		return opts, err
+	}
+	opts.Compression, opts.CompressionLevel = c, level
+	if splitSize != "" {
+		if opts.SplitSize, err = split.ParseSize(splitSize); err != nil {
+			return opts, err
+		}
+	}
+	if format.IsDir() && opts.SplitSize == 0 && c != "" && c != docker.CompressionNone {
+		return opts, fmt.Errorf("%s 格式输出为目录，无法压缩", format)
+	}
+	return opts, nil
+}
+
+// applyTransferFlags 用命令行指定的 --concurrency、--limit-rate 与 --burst 覆盖配置中的设置
+func applyTransferFlags(fs *flag.FlagSet, concurrency int, limitRate, burst string, cfg *types.Transfer) error {
+	if flagSet(fs, "concurrency") {
+		if concurrency < 1 {
+			return fmt.Errorf("--concurrency 至少为 1")
+		}
+		cfg.Concurrency = concurrency
+	}
+	if flagSet(fs, "limit-rate") {
+		rate, err := config.ParseRate(limitRate)
+		if err != nil {
+			return err
+		}
+		cfg.BandwidthLimit = rate
+	}
+	if flagSet(fs, "burst") {
+		b, err := config.ParseRate(burst)
+		if err != nil {
+			return err
+		}
+		cfg.BandwidthBurst = b
+	}
+	return nil
+}
+
+// validateCompressionLevel 按实际生效的压缩算法（未指定时取输出文件扩展名）检查压缩级别
+func validateCompressionLevel(opts docker.OutputOptions, output string) error {
+	c := opts.Compression
+	if c == "" {
+		c = docker.CompressionFromPath(output)
+	}
+	return docker.ValidateCompressionLevel(c, opts.CompressionLevel)
+}
+
+// flagSet 判断命令行中是否显式指定了某个参数
+func flagSet(fs *flag.FlagSet, name string) bool {
+	found := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// defaultPlatform 返回默认平台（用户配置 > 环境变量 > linux/amd64）
+func defaultPlatform(userCfg *types.UserConfig) types.Platform {
+	p := types.Platform{
+		OS:   envOr("DIPT_DEFAULT_OS", "linux"),
+		Arch: envOr("DIPT_DEFAULT_ARCH", "amd64"),
+	}
+	if userCfg != nil {
+		if userCfg.DefaultOS != "" {
+			p.OS = userCfg.DefaultOS
+		}
+		if userCfg.DefaultArch != "" {
+			p.Arch = userCfg.DefaultArch
+		}
+	}
+	return p
+}
+
+// defaultSaveDir 返回默认保存目录，未配置时使用当前目录
+func defaultSaveDir(userCfg *types.UserConfig) string {
+	if userCfg != nil && userCfg.DefaultSaveDir != "" {
+		return userCfg.DefaultSaveDir
+	}
+	return envOr("DIPT_DEFAULT_SAVE_DIR", "")
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"dipt/internal/config"
+	"dipt/internal/docker"
+)
+
+// runPush 处理 dipt push 子命令，将保存的镜像推送到仓库
+func runPush(args []string) int {
+	fs := flag.NewFlagSet("push", flag.ContinueOnError)
+	var (
+		prefix   string
+		insecure bool
+		format   string
+		quiet    bool
+	)
+	fs.StringVar(&prefix, "to", "", "目标仓库前缀，如 harbor.example.com/mirror，镜像推送到 <前缀>/<原仓库路径>:<原 tag>；默认推送到原镜像引用")
+	fs.BoolVar(&insecure, "insecure", false, "使用 HTTP 访问目标仓库")
+	fs.StringVar(&format, "output-format", formatText, "结果输出格式 (text, json)，json 结果写到 stdout")
+	fs.BoolVar(&quiet, "q", false, "仅输出错误信息")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt push SOURCE... [--to PREFIX] [--insecure] [--output-format text|json] [-q]")
+		fmt.Fprintln(fs.Output(), "\nSOURCE 为 docker 格式的 tar（可为 gzip/zstd 压缩或分卷后的输出文件路径）、oci-archive 文件或 OCI layout 目录，")
+		fmt.Fprintln(fs.Output(), "也可以是包含这些文件的目录，此时推送其中的全部镜像")
+		fmt.Fprintln(fs.Output(), "认证使用 docker login 保存的凭据；目标正是配置凭据所属的仓库（server，默认为 Docker Hub）")
+		fmt.Fprintln(fs.Output(), "且 docker login 中没有该仓库的凭据时，才使用配置中的仓库用户名和密码")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) == 0 {
+		return usageFail(fs, "需要指定文件或目录")
+	}
+	if !validOutputFormat(format) {
+		return usageFail(fs, "不支持的输出格式: %s", format)
+	}
+	if prefix != "" {
+		if err := docker.ValidatePushPrefix(prefix); err != nil {
+			return usageFail(fs, "%v", err)
+		}
+	}
+
+	_, effCfg, err := config.LoadEffectiveConfigs()
+	if err != nil {
+		return fail(err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	start := time.Now()
+	reporter := newLineReporter(os.Stderr, quiet)
+	total := &docker.PushResult{}
+	code := ExitOK
+	for _, source := range positional {
+		result, err := docker.PushArchive(docker.PushOptions{
+			Source:     source,
+			Prefix:     prefix,
+			Insecure:   insecure,
+			Context:    ctx,
+			Config:     effCfg,
+			OnProgress: reporter.progress,
+			OnLog:      reporter.log,
+		})
+		if result != nil {
+			// 取消时返回已推送的部分结果
+			total.Images = append(total.Images, result.Images...)
+			total.Failed = append(total.Failed, result.Failed...)
+		}
+		if err != nil {
+			code = fail(err)
+			total.Failed = append(total.Failed, docker.PushFailure{File: source, Error: err.Error()})
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	total.Duration = time.Since(start)
+	total.DurationSeconds = total.Duration.Round(time.Millisecond).Seconds()
+
+	if len(total.Failed) > 0 && code != ExitInterrupted {
+		code = ExitFailure
+	}
+	if format == formatJSON {
+		if total.Images == nil {
+			total.Images = []docker.PushedImage{}
+		}
+		if err := writeJSON(os.Stdout, total); err != nil {
+			return fail(err)
+		}
+		return code
+	}
+	if len(total.Failed) > 0 {
+		reporter.log("error", fmt.Sprintf("已推送 %d 个镜像，%d 个失败", len(total.Images), len(total.Failed)))
+	} else {
+		reporter.log("success", fmt.Sprintf("已推送 %d 个镜像，耗时 %s", len(total.Images), total.Duration.Round(time.Second)))
+	}
+	return code
+}
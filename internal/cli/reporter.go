@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"dipt/internal/docker"
+)
+
+// progressInterval 进度行的最小输出间隔
+const progressInterval = time.Second
+
+// lineReporter 以逐行文本的形式输出日志与进度，适用于脚本和 CI
+type lineReporter struct {
+	mu         sync.Mutex
+	w          io.Writer
+	quiet      bool
+	lastPrint  time.Time
+	lastPct    int
+	downloaded int64
+	total      int64
+	written    int64
+}
+
+func newLineReporter(w io.Writer, quiet bool) *lineReporter {
+	return &lineReporter{w: w, quiet: quiet, lastPct: -1}
+}
+
+// log 输出一行日志，quiet 模式下只输出错误
+func (r *lineReporter) log(level, msg string) {
+	if r.quiet && level != "error" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.w, "%s %s\n", levelTag(level), msg)
+}
+
+// progress 输出下载进度，按时间间隔节流
+func (r *lineReporter) progress(downloaded, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downloaded, r.total = downloaded, total
+	if r.quiet || total <= 0 {
+		return
+	}
+	pct := int(downloaded * 100 / total)
+	if pct > 100 {
+		pct = 100
+	}
+	if pct == r.lastPct || (time.Since(r.lastPrint) < progressInterval && pct < 100) {
+		return
+	}
+	r.lastPct = pct
+	r.lastPrint = time.Now()
+	line := fmt.Sprintf("[PROG]  %3d%% %s / %s", pct, docker.FormatBytes(downloaded), docker.FormatBytes(total))
+	if r.written > 0 {
+		line += "，已写入 " + docker.FormatBytes(r.written)
+	}
+	fmt.Fprintln(r.w, line)
+}
+
+// write 记录已写入输出文件的字节数，随下一条进度行输出
+func (r *lineReporter) write(written int64) {
+	r.mu.Lock()
+	r.written = written
+	r.mu.Unlock()
+}
+
+// finish 输出最终进度
+func (r *lineReporter) finish() {
+	r.mu.Lock()
+	total := r.total
+	r.mu.Unlock()
+	if total > 0 {
+		r.progress(total, total)
+	}
+}
+
+func levelTag(level string) string {
+	switch level {
+	case "debug":
+		return "[DEBUG]"
+	case "info":
+		return "[INFO] "
+	case "warning":
+		return "[WARN] "
+	case "error":
+		return "[ERROR]"
+	case "success":
+		return "[OK]   "
+	default:
+		return "       "
+	}
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"dipt/internal/config"
+	"dipt/internal/serve"
+)
+
+// runServe 处理 dipt serve 子命令，把保存的镜像作为只读仓库提供出去
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	var (
+		dir      string
+		addr     string
+		certFile string
+		keyFile  string
+		quiet    bool
+	)
+	fs.StringVar(&dir, "dir", "", "镜像目录 (默认为保存目录)")
+	fs.StringVar(&addr, "addr", ":5000", "监听地址")
+	fs.StringVar(&certFile, "tls-cert", "", "TLS 证书文件，与 --tls-key 一起使用时以 HTTPS 提供服务")
+	fs.StringVar(&keyFile, "tls-key", "", "TLS 私钥文件")
+	fs.BoolVar(&quiet, "q", false, "仅输出错误信息")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt serve [--dir DIR] [--addr :5000] [--tls-cert FILE --tls-key FILE] [-q]")
+		fmt.Fprintln(fs.Output(), "\n把目录中保存的镜像（tar 文件、分卷输出与 OCI layout）作为只读仓库提供，")
+		fmt.Fprintln(fs.Output(), "docker pull、containerd、k3s 等可以直接从本机拉取，如 docker pull 192.168.1.10:5000/library/nginx:latest")
+		fmt.Fprintln(fs.Output(), "未配置 TLS 时客户端需要把该地址加入 insecure-registries")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) > 0 {
+		return usageFail(fs, "多余的参数: %s", strings.Join(positional, " "))
+	}
+	if (certFile == "") != (keyFile == "") {
+		return usageFail(fs, "--tls-cert 与 --tls-key 需要同时指定")
+	}
+	if dir == "" {
+		userCfg, _, err := config.LoadEffectiveConfigs()
+		if err != nil {
+			return fail(err)
+		}
+		dir = defaultSaveDir(userCfg)
+	}
+
+	reporter := newLineReporter(os.Stderr, quiet)
+	srv, err := serve.New(serve.Options{Dir: dir, OnLog: reporter.log})
+	if err != nil {
+		return fail(err)
+	}
+	defer srv.Close()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fail(err)
+	}
+	repos := srv.Repositories()
+	reporter.log("info", fmt.Sprintf("提供 %s 中的 %d 个仓库，监听 %s", dir, len(repos), ln.Addr()))
+	for _, repo := range repos {
+		reporter.log("info", fmt.Sprintf("  %s: %s", repo, strings.Join(srv.Tags(repo), ", ")))
+	}
+
+	httpSrv := &http.Server{Handler: srv, ReadHeaderTimeout: 30 * time.Second}
+	errCh := make(chan error, 1)
+	go func() {
+		if certFile != "" {
+			errCh <- httpSrv.ServeTLS(ln, certFile, keyFile)
+		} else {
+			errCh <- httpSrv.Serve(ln)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	select {
+	case err := <-errCh:
+		return fail(err)
+	case <-sigCh:
+	}
+
+	reporter.log("info", "正在停止服务...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpSrv.Shutdown(ctx); err != nil {
+		return fail(err)
+	}
+	return ExitOK
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"dipt/internal/docker"
+)
+
+// runVerify 处理 dipt verify 子命令，校验保存的 tar 文件是否完整
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	var (
+		format string
+		quiet  bool
+	)
+	fs.StringVar(&format, "output-format", formatText, "输出格式 (text, json)")
+	fs.BoolVar(&quiet, "q", false, "仅输出错误信息")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: dipt verify FILE... [--output-format text|json] [-q]")
+		fmt.Fprintln(fs.Output(), "\nFILE 为 docker 格式保存的 tar（可为 gzip/zstd 压缩或 --split 分卷后的输出文件路径）")
+		fmt.Fprintln(fs.Output(), "先按 <FILE>.sha256 重新计算文件校验和，再逐个重新计算镜像 config 与各层的 digest 并与清单比对")
+		fmt.Fprintln(fs.Output(), "\n选项:")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if len(positional) == 0 {
+		return usageFail(fs, "需要指定文件")
+	}
+	if !validOutputFormat(format) {
+		return usageFail(fs, "不支持的输出格式: %s", format)
+	}
+
+	reporter := newLineReporter(os.Stderr, quiet || format == formatJSON)
+	var reports []*docker.VerifyReport
+	code := ExitOK
+	for _, path := range positional {
+		report, err := docker.VerifyArchive(path, reporter.log)
+		if err != nil {
+			code = fail(fmt.Errorf("%s: %v", path, err))
+			continue
+		}
+		reports = append(reports, report)
+		if !report.OK() {
+			code = ExitFailure
+			if format != formatJSON {
+				reporter.log("error", fmt.Sprintf("%s 校验失败: %d 个文件校验和不符，%d 处 config 或层损坏",
+					path, len(report.Mismatch), len(report.Problems)))
+			}
+			continue
+		}
+		reporter.log("success", fmt.Sprintf("%s 校验通过（%d 个镜像）", path, len(report.Images)))
+	}
+
+	if format == formatJSON {
+		var v interface{} = reports
+		if len(positional) == 1 && len(reports) == 1 {
+			v = reports[0]
+		}
+		if err := writeJSON(os.Stdout, v); err != nil {
+			return fail(err)
+		}
+	}
+	return code
+}
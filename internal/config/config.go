@@ -6,9 +6,12 @@ import (
     "net/http"
     "os"
     "path/filepath"
+    "strconv"
     "strings"
     "time"
 
+    "dipt/internal/errors"
+    "dipt/internal/split"
     "dipt/internal/types"
 )
 
@@ -43,18 +46,10 @@ func LoadUserConfig() (*types.UserConfig, error) {
             if defArch == "" {
                 defArch = "amd64"
             }
-            homeDir, _ := os.UserHomeDir()
-            defSave := os.Getenv("DIPT_DEFAULT_SAVE_DIR")
-            if defSave == "" {
-                defSave = filepath.Join(homeDir, "DockerImages")
-            }
-            _ = os.MkdirAll(defSave, 0755)
-
-            cfg := &types.UserConfig{
-                DefaultOS:      defOS,
-                DefaultArch:    defArch,
-                DefaultSaveDir: defSave,
-            }
+            cfg := defaultUserConfig()
+            cfg.DefaultOS = defOS
+            cfg.DefaultArch = defArch
+            _ = os.MkdirAll(cfg.DefaultSaveDir, 0755)
             _ = SaveUserConfig(cfg)
             return cfg, nil
         }
@@ -76,6 +71,32 @@ func LoadUserConfig() (*types.UserConfig, error) {
 	return &config, nil
 }
 
+// defaultUserConfig 返回默认用户配置
+func defaultUserConfig() *types.UserConfig {
+	homeDir, _ := os.UserHomeDir()
+	defSave := os.Getenv("DIPT_DEFAULT_SAVE_DIR")
+	if defSave == "" {
+		defSave = filepath.Join(homeDir, "DockerImages")
+	}
+	return &types.UserConfig{
+		DefaultOS:      "linux",
+		DefaultArch:    "amd64",
+		DefaultSaveDir: defSave,
+	}
+}
+
+// loadOrDefaultUserConfig 加载用户配置，配置文件不存在时返回默认配置
+func loadOrDefaultUserConfig() (*types.UserConfig, error) {
+	config, err := LoadUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = defaultUserConfig()
+	}
+	return config, nil
+}
+
 // SaveUserConfig 保存用户配置
 func SaveUserConfig(config *types.UserConfig) error {
 	configPath, err := getConfigFilePath()
@@ -96,22 +117,25 @@ func SaveUserConfig(config *types.UserConfig) error {
 	return nil
 }
 
+// ConfigKeys 可通过 dipt config 读写的配置项
+var ConfigKeys = []string{"os", "arch", "save_dir", "username", "password", "server", "concurrency", "bandwidth", "burst", "jobs"}
+
 // SetConfigValue 设置配置值
 func SetConfigValue(key, value string) error {
-	config, err := LoadUserConfig()
+	config, err := loadOrDefaultUserConfig()
 	if err != nil {
 		return err
 	}
 
 	switch key {
 	case "os":
-		if !isValidOS(value) {
-			return fmt.Errorf("不支持的操作系统: %s", value)
+		if !IsValidOS(value) {
+			return errors.NewUsageError("不支持的操作系统: %s", value)
 		}
 		config.DefaultOS = value
 	case "arch":
-		if !isValidArch(value) {
-			return fmt.Errorf("不支持的架构: %s", value)
+		if !IsValidArch(value) {
+			return errors.NewUsageError("不支持的架构: %s", value)
 		}
 		config.DefaultArch = value
 	case "save_dir":
@@ -129,26 +153,158 @@ func SetConfigValue(key, value string) error {
 			return fmt.Errorf("转换路径失败: %v", err)
 		}
 		config.DefaultSaveDir = absPath
-	case "mirror":
-		return fmt.Errorf("请使用 mirror 相关的子命令管理镜像加速器:\n" +
+	case "username":
+		config.Registry.Username = value
+	case "password":
+		config.Registry.Password = value
+	case "server":
+		config.Registry.Server = strings.TrimSpace(value)
+	case "concurrency":
+		n, err := ParseConcurrency(value)
+		if err != nil {
+			return err
+		}
+		config.Transfer.Concurrency = n
+	case "bandwidth":
+		rate, err := ParseRate(value)
+		if err != nil {
+			return err
+		}
+		config.Transfer.BandwidthLimit = rate
+	case "burst":
+		burst, err := ParseRate(value)
+		if err != nil {
+			return err
+		}
+		config.Transfer.BandwidthBurst = burst
+	case "jobs":
+		n, err := ParseConcurrency(value)
+		if err != nil {
+			return err
+		}
+		config.Transfer.MaxJobs = n
+	case "mirror", "mirrors":
+		return errors.NewUsageError("请使用 mirror 相关的子命令管理镜像加速器:\n" +
 			"  dipt mirror list          # 列出所有镜像加速器\n" +
 			"  dipt mirror add <URL>     # 添加镜像加速器\n" +
 			"  dipt mirror del <URL>     # 删除镜像加速器\n" +
 			"  dipt mirror clear         # 清空所有镜像加速器")
 	default:
-		return fmt.Errorf("未知的配置项: %s", key)
+		return errors.NewUsageError("未知的配置项: %s", key)
 	}
 
 	return SaveUserConfig(config)
 }
 
+// GetConfigValue 读取配置值
+func GetConfigValue(key string) (string, error) {
+	config, err := loadOrDefaultUserConfig()
+	if err != nil {
+		return "", err
+	}
+
+	switch key {
+	case "os":
+		return config.DefaultOS, nil
+	case "arch":
+		return config.DefaultArch, nil
+	case "save_dir":
+		return config.DefaultSaveDir, nil
+	case "username":
+		return config.Registry.Username, nil
+	case "password":
+		return config.Registry.Password, nil
+	case "server":
+		return config.Registry.Server, nil
+	case "concurrency":
+		if config.Transfer.Concurrency == 0 {
+			return "", nil
+		}
+		return strconv.Itoa(config.Transfer.Concurrency), nil
+	case "bandwidth":
+		return FormatRate(config.Transfer.BandwidthLimit), nil
+	case "burst":
+		return FormatRate(config.Transfer.BandwidthBurst), nil
+	case "jobs":
+		if config.Transfer.MaxJobs == 0 {
+			return "", nil
+		}
+		return strconv.Itoa(config.Transfer.MaxJobs), nil
+	case "mirror", "mirrors":
+		return strings.Join(config.Registry.Mirrors, ","), nil
+	default:
+		return "", errors.NewUsageError("未知的配置项: %s", key)
+	}
+}
+
+// ParseConcurrency 解析同时下载的层数，空字符串或 0 表示使用默认值
+func ParseConcurrency(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errors.NewUsageError("无效的并发数: %s (0 表示使用默认值)", value)
+	}
+	return n, nil
+}
+
+// ParseRate 解析带宽（字节/秒），支持 K/M/G 单位与可选的 /s 后缀；空字符串或 0 表示不限速
+func ParseRate(value string) (int64, error) {
+	s := strings.TrimSpace(value)
+	if len(s) > 2 && strings.EqualFold(s[len(s)-2:], "/s") {
+		s = s[:len(s)-2]
+	}
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	rate, err := split.ParseSize(s)
+	if err != nil {
+		return 0, errors.NewUsageError("无效的带宽: %s (示例: 10M、512K，0 表示不限速)", value)
+	}
+	return rate, nil
+}
+
+// FormatRate 将带宽格式化为 ParseRate 可以解析的形式，0 返回空字符串
+func FormatRate(rate int64) string {
+	if rate <= 0 {
+		return ""
+	}
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}} {
+		if rate%u.size == 0 {
+			return strconv.FormatInt(rate/u.size, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(rate, 10)
+}
+
+// ListConfigValues 按固定顺序列出所有配置项，密码以掩码显示
+func ListConfigValues() ([][2]string, error) {
+	out := make([][2]string, 0, len(ConfigKeys)+1)
+	for _, key := range append(ConfigKeys, "mirrors") {
+		value, err := GetConfigValue(key)
+		if err != nil {
+			return nil, err
+		}
+		if key == "password" && value != "" {
+			value = "******"
+		}
+		out = append(out, [2]string{key, value})
+	}
+	return out, nil
+}
+
 // HandleMirrorCommand 处理镜像加速器相关命令
 func HandleMirrorCommand(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("缺少子命令，可用命令：list, add, del, clear, test")
+		return errors.NewUsageError("缺少子命令，可用命令：list, add, del, clear, test")
 	}
 
-	config, err := LoadUserConfig()
+	config, err := loadOrDefaultUserConfig()
 	if err != nil {
 		return err
 	}
@@ -166,7 +322,7 @@ func HandleMirrorCommand(args []string) error {
 
 	case "add":
 		if len(args) != 2 {
-			return fmt.Errorf("用法: dipt mirror add <URL>")
+			return errors.NewUsageError("用法: dipt mirror add <URL>")
 		}
 		mirror := args[1]
 		// 检查是否已存在
@@ -184,7 +340,7 @@ func HandleMirrorCommand(args []string) error {
 
 	case "del":
 		if len(args) != 2 {
-			return fmt.Errorf("用法: dipt mirror del <URL>")
+			return errors.NewUsageError("用法: dipt mirror del <URL>")
 		}
 		mirror := args[1]
 		found := false
@@ -216,7 +372,7 @@ func HandleMirrorCommand(args []string) error {
 
     case "test":
         if len(args) != 2 {
-            return fmt.Errorf("用法: dipt mirror test <URL>")
+            return errors.NewUsageError("用法: dipt mirror test <URL>")
         }
         mirror := args[1]
         url := mirror
@@ -234,8 +390,7 @@ func HandleMirrorCommand(args []string) error {
         client := &http.Client{Timeout: 5 * time.Second}
         resp, err := client.Get(url)
         if err != nil {
-            fmt.Printf("❌ 连接失败: %v\n", err)
-            return nil
+            return fmt.Errorf("连接失败: %v", err)
         }
         defer resp.Body.Close()
 		fmt.Printf("返回状态码: %d\n", resp.StatusCode)
@@ -254,12 +409,12 @@ func HandleMirrorCommand(args []string) error {
 		} else if resp.StatusCode == 401 {
 			fmt.Println("✅ 连接成功 (401)，需要认证，通常也代表加速器可用")
 		} else {
-			fmt.Println("⚠️ 连接异常，状态码请参考上方信息")
+			return fmt.Errorf("连接异常 (状态码 %d)，请参考上方信息", resp.StatusCode)
 		}
 		return nil
 
 	default:
-		return fmt.Errorf("未知的子命令: %s", args[0])
+		return errors.NewUsageError("未知的子命令: %s", args[0])
 	}
 
 	return nil
@@ -281,7 +436,7 @@ func LoadProjectConfig() (*types.Config, error) {
     return &cfg, nil
 }
 
-// EffectiveRegistry 合并生效的 Registry（优先级：环境变量 > 项目配置 > 用户配置）
+// EffectiveRegistry 合并生效的 Registry 与下载配置（优先级：环境变量 > 项目配置 > 用户配置）
 func EffectiveRegistry(user *types.UserConfig, project *types.Config) types.Config {
     var out types.Config
     // 先复制用户配置
@@ -289,6 +444,8 @@ func EffectiveRegistry(user *types.UserConfig, project *types.Config) types.Conf
         out.Registry.Mirrors = append(out.Registry.Mirrors, user.Registry.Mirrors...)
         out.Registry.Username = user.Registry.Username
         out.Registry.Password = user.Registry.Password
+        out.Registry.Server = user.Registry.Server
+        out.Transfer = user.Transfer
     }
     // 项目配置覆盖
     if project != nil {
@@ -301,6 +458,21 @@ func EffectiveRegistry(user *types.UserConfig, project *types.Config) types.Conf
         if project.Registry.Password != "" {
             out.Registry.Password = project.Registry.Password
         }
+        if project.Registry.Server != "" {
+            out.Registry.Server = project.Registry.Server
+        }
+        if project.Transfer.Concurrency > 0 {
+            out.Transfer.Concurrency = project.Transfer.Concurrency
+        }
+        if project.Transfer.BandwidthLimit > 0 {
+            out.Transfer.BandwidthLimit = project.Transfer.BandwidthLimit
+        }
+        if project.Transfer.BandwidthBurst > 0 {
+            out.Transfer.BandwidthBurst = project.Transfer.BandwidthBurst
+        }
+        if project.Transfer.MaxJobs > 0 {
+            out.Transfer.MaxJobs = project.Transfer.MaxJobs
+        }
     }
     // 环境变量最终覆盖
     if u := os.Getenv("DIPT_REGISTRY_USERNAME"); u != "" {
@@ -309,6 +481,9 @@ func EffectiveRegistry(user *types.UserConfig, project *types.Config) types.Conf
     if p := os.Getenv("DIPT_REGISTRY_PASSWORD"); p != "" {
         out.Registry.Password = p
     }
+    if s := os.Getenv("DIPT_REGISTRY_SERVER"); s != "" {
+        out.Registry.Server = s
+    }
     if m := os.Getenv("DIPT_REGISTRY_MIRRORS"); m != "" {
         // 逗号分隔
         parts := strings.Split(m, ",")
@@ -323,6 +498,27 @@ func EffectiveRegistry(user *types.UserConfig, project *types.Config) types.Conf
             out.Registry.Mirrors = mirrors
         }
     }
+    // 无效的值忽略，沿用配置文件中的设置
+    if v := os.Getenv("DIPT_CONCURRENCY"); v != "" {
+        if n, err := ParseConcurrency(v); err == nil {
+            out.Transfer.Concurrency = n
+        }
+    }
+    if v := os.Getenv("DIPT_BANDWIDTH_LIMIT"); v != "" {
+        if rate, err := ParseRate(v); err == nil {
+            out.Transfer.BandwidthLimit = rate
+        }
+    }
+    if v := os.Getenv("DIPT_BANDWIDTH_BURST"); v != "" {
+        if burst, err := ParseRate(v); err == nil {
+            out.Transfer.BandwidthBurst = burst
+        }
+    }
+    if v := os.Getenv("DIPT_MAX_JOBS"); v != "" {
+        if n, err := ParseConcurrency(v); err == nil {
+            out.Transfer.MaxJobs = n
+        }
+    }
     return out
 }
 
@@ -340,8 +536,8 @@ func LoadEffectiveConfigs() (*types.UserConfig, types.Config, error) {
     return userCfg, eff, nil
 }
 
-// isValidOS 检查操作系统是否有效
-func isValidOS(os string) bool {
+// IsValidOS 检查操作系统是否有效
+func IsValidOS(os string) bool {
 	validOS := []string{"linux", "windows", "darwin"}
 	for _, v := range validOS {
 		if v == os {
@@ -351,8 +547,8 @@ func isValidOS(os string) bool {
 	return false
 }
 
-// isValidArch 检查架构是否有效
-func isValidArch(arch string) bool {
+// IsValidArch 检查架构是否有效
+func IsValidArch(arch string) bool {
 	validArch := []string{"amd64", "arm64", "arm", "386"}
 	for _, v := range validArch {
 		if v == arch {
@@ -0,0 +1,121 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"dipt/internal/split"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// BundleInfo 打包结果统计
+type BundleInfo struct {
+	Refs   []string // 写入的镜像引用
+	Images int      // 去重后的镜像数（多个 tag 指向同一镜像时只算一个）
+	Layers int      // 去重后的层数
+	Size   int64    // 去重后层与 config 的总大小
+
+	PartsManifest string // 分卷写入时的校验清单
+}
+
+// WriteBundle 将 OCI layout 中的全部镜像写入同一个 docker save 兼容的 tar 文件
+// 指向同一镜像的多个引用合并为一条记录，共享的层只写入一次，可用一次 docker load 全部导入
+// 压缩与分卷由 opts 决定
+func WriteBundle(layoutDir, outputFile string, opts OutputOptions) (*BundleInfo, error) {
+	out := newOutputSpec(outputFile, FormatDocker, opts, nil)
+	if err := out.validate(); err != nil {
+		return nil, err
+	}
+
+	lp, err := layout.FromPath(layoutDir)
+	if err != nil {
+		return nil, fmt.Errorf("打开 OCI layout 失败: %v", err)
+	}
+	idx, err := lp.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("读取 OCI layout 失败: %v", err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("读取 OCI layout 失败: %v", err)
+	}
+
+	info := &BundleInfo{}
+	refToImage := make(map[name.Reference]v1.Image, len(im.Manifests))
+	seen := make(map[v1.Hash]bool)
+	// 同一 digest 复用同一个 v1.Image，tarball 才会把多个 tag 合并到一条记录
+	images := make(map[v1.Hash]v1.Image)
+	for _, desc := range im.Manifests {
+		refName := desc.Annotations[annotationContainerdRef]
+		if !desc.MediaType.IsImage() {
+			return nil, fmt.Errorf("%s 是多平台镜像索引，无法写入 docker save 格式", refName)
+		}
+		ref, err := name.ParseReference(refName)
+		if err != nil {
+			return nil, fmt.Errorf("无效的镜像引用 %q: %v", refName, err)
+		}
+		info.Refs = append(info.Refs, ref.Name())
+		if img, ok := images[desc.Digest]; ok {
+			refToImage[ref] = img
+			continue
+		}
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("读取镜像 %s 失败: %v", refName, err)
+		}
+		images[desc.Digest] = img
+		refToImage[ref] = img
+		info.Images++
+		m, err := img.Manifest()
+		if err != nil {
+			return nil, fmt.Errorf("读取镜像 %s 清单失败: %v", refName, err)
+		}
+		if !seen[m.Config.Digest] {
+			seen[m.Config.Digest] = true
+			info.Size += m.Config.Size
+		}
+		for _, l := range m.Layers {
+			if seen[l.Digest] {
+				continue
+			}
+			seen[l.Digest] = true
+			info.Layers++
+			info.Size += l.Size
+		}
+	}
+	if len(refToImage) == 0 {
+		return nil, fmt.Errorf("没有可打包的镜像")
+	}
+	sort.Strings(info.Refs)
+
+	err = out.write(func(w io.Writer) error { return tarball.MultiRefWrite(refToImage, w) })
+	if err != nil {
+		return nil, fmt.Errorf("写入打包文件失败: %v", err)
+	}
+	if out.splitSize > 0 {
+		info.PartsManifest = split.ManifestPath(outputFile)
+	}
+	return info, nil
+}
+
+// WriteBundleMetadata 在打包文件旁写出元数据文件，pulls 为打包中各镜像的拉取结果
+func WriteBundleMetadata(bundleFile string, opts OutputOptions, info *BundleInfo, pulls []*PullResult) error {
+	out := newOutputSpec(bundleFile, FormatDocker, opts, nil)
+	result := &PullResult{
+		OutputFile:    bundleFile,
+		TotalSize:     info.Size,
+		PartsManifest: info.PartsManifest,
+	}
+	out.record(result)
+	md := NewMetadata(result, FormatDocker, out.compression)
+	md.Platform = nil
+	for _, p := range pulls {
+		md.Images = append(md.Images, NewMetadata(p, FormatDocker, CompressionNone))
+	}
+	return WriteMetadata(bundleFile, md)
+}
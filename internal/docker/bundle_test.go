@@ -0,0 +1,65 @@
+package docker
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+func TestWriteBundle(t *testing.T) {
+	dir := t.TempDir()
+	layoutDir := filepath.Join(dir, "layout")
+
+	app, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := map[string]v1.Image{
+		"example.com/app:1.0":    app,
+		"example.com/app:latest": app,
+		"example.com/db:2":       db,
+	}
+	for r, img := range images {
+		ref, err := name.NewTag(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeOCILayout(layoutDir, ref, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bundle := filepath.Join(dir, "bundle.tar")
+	info, err := WriteBundle(layoutDir, bundle, OutputOptions{})
+	if err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+	if len(info.Refs) != 3 || info.Images != 2 || info.Layers != 3 {
+		t.Errorf("WriteBundle() info = %+v, want 3 refs, 2 images, 3 layers", info)
+	}
+
+	// 每个引用都能从打包文件中单独读出
+	for r, want := range images {
+		tag, err := name.NewTag(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := tarball.ImageFromPath(bundle, &tag)
+		if err != nil {
+			t.Fatalf("read %s from bundle: %v", r, err)
+		}
+		got, _ := img.Digest()
+		wantDigest, _ := want.Digest()
+		if got != wantDigest {
+			t.Errorf("%s digest = %s, want %s", r, got, wantDigest)
+		}
+	}
+}
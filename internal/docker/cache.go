@@ -0,0 +1,368 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// BlobCache 按 digest 保存已下载层的本地缓存
+// 拉取时先查缓存，缺失的层经由 Transport 下载，下载完成并校验 digest 后写入缓存，
+// 中断的拉取重试时只需下载缺失的层（未下载完的层用 Range 请求续传），共享基础层的镜像也不会重复下载
+type BlobCache struct {
+	dir       string
+	temporary bool // 未启用缓存时本次拉取专用的临时目录，其中的层不计为缓存命中
+
+	// verified 本进程中已校验过 digest 的 blob，避免每次查询都重新计算
+	verified sync.Map
+}
+
+// NewBlobCache 创建位于 dir 的缓存
+func NewBlobCache(dir string) *BlobCache {
+	return &BlobCache{dir: dir}
+}
+
+// DefaultBlobCache 返回默认缓存：位置可通过 DIPT_CACHE_DIR 指定，默认为用户缓存目录下的 dipt；
+// 设置 DIPT_NO_CACHE=1 时不使用缓存，返回 nil
+func DefaultBlobCache() *BlobCache {
+	if os.Getenv("DIPT_NO_CACHE") == "1" {
+		return nil
+	}
+	return NewBlobCache(DefaultCacheDir())
+}
+
+// DefaultCacheDir 返回默认缓存目录
+func DefaultCacheDir() string {
+	if dir := os.Getenv("DIPT_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "dipt")
+}
+
+// Dir 返回缓存目录
+func (c *BlobCache) Dir() string {
+	return c.dir
+}
+
+// blobDir 已完成 blob 所在目录
+func (c *BlobCache) blobDir() string {
+	return filepath.Join(c.dir, "blobs", "sha256")
+}
+
+// tmpDir 未下载完的 blob 所在目录
+func (c *BlobCache) tmpDir() string {
+	return filepath.Join(c.dir, "tmp")
+}
+
+func (c *BlobCache) path(h v1.Hash) string {
+	return filepath.Join(c.blobDir(), h.Hex)
+}
+
+// has 判断缓存中是否有完整的 blob
+// 大小相同的 blob 首次查询时校验 digest，内容损坏的 blob 会被删除并视为缺失，之后重新下载
+func (c *BlobCache) has(h v1.Hash, size int64) bool {
+	if h.Algorithm != "sha256" {
+		return false
+	}
+	p := c.path(h)
+	fi, err := os.Stat(p)
+	if err != nil || fi.Size() != size {
+		return false
+	}
+	if _, ok := c.verified.Load(h); ok {
+		return true
+	}
+	got, err := fileSHA256(p)
+	if err != nil {
+		return false
+	}
+	if got != h.Hex {
+		os.Remove(p)
+		return false
+	}
+	c.verified.Store(h, true)
+	return true
+}
+
+// open 打开缓存中的 blob，并更新其修改时间以便按最近使用时间清理
+func (c *BlobCache) open(h v1.Hash) (io.ReadCloser, error) {
+	p := c.path(h)
+	now := time.Now()
+	os.Chtimes(p, now, now)
+	return os.Open(p)
+}
+
+// CacheStats 缓存占用情况
+type CacheStats struct {
+	Dir     string     `json:"dir"`
+	Blobs   int        `json:"blobs"`
+	Size    int64      `json:"size"`
+	Partial int64      `json:"partial_size,omitempty"` // 未完成下载的临时文件大小
+	Oldest  *time.Time `json:"oldest,omitempty"`       // 最久未使用的 blob 的使用时间
+}
+
+// cacheEntry 缓存中的一个文件
+type cacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// cacheEntries 返回目录中的文件，按修改时间从旧到新排列
+func cacheEntries(dir string) ([]cacheEntry, error) {
+	list, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []cacheEntry
+	for _, e := range list {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		entries = append(entries, cacheEntry{path: filepath.Join(dir, e.Name()), size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	return entries, nil
+}
+
+// Stats 统计缓存占用
+func (c *BlobCache) Stats() (*CacheStats, error) {
+	stats := &CacheStats{Dir: c.dir}
+	blobs, err := cacheEntries(c.blobDir())
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range blobs {
+		stats.Blobs++
+		stats.Size += e.size
+	}
+	if len(blobs) > 0 {
+		oldest := blobs[0].modTime
+		stats.Oldest = &oldest
+	}
+	tmp, err := cacheEntries(c.tmpDir())
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range tmp {
+		stats.Partial += e.size
+	}
+	return stats, nil
+}
+
+// PruneOptions 清理条件，都为零值时清空缓存
+type PruneOptions struct {
+	MaxSize int64         // 清理后缓存的最大大小，按最久未使用的顺序删除
+	MaxAge  time.Duration // 删除超过该时间未使用的 blob
+}
+
+// PruneResult 清理结果
+type PruneResult struct {
+	Removed   int   `json:"removed"`
+	Freed     int64 `json:"freed"`
+	Remaining int64 `json:"remaining"`
+}
+
+// partialMaxAge 未下载完的文件超过该时间未更新时总会被清理，不再等待续传
+const partialMaxAge = 7 * 24 * time.Hour
+
+// Prune 按大小与使用时间清理缓存
+func (c *BlobCache) Prune(opts PruneOptions) (*PruneResult, error) {
+	result := &PruneResult{}
+	all := opts.MaxSize <= 0 && opts.MaxAge <= 0
+
+	tmp, err := cacheEntries(c.tmpDir())
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range tmp {
+		age := time.Since(e.modTime)
+		if all || age > partialMaxAge || (opts.MaxAge > 0 && age > opts.MaxAge) {
+			if os.Remove(e.path) == nil {
+				result.Freed += e.size
+			}
+		}
+	}
+
+	blobs, err := cacheEntries(c.blobDir())
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, e := range blobs {
+		total += e.size
+	}
+	for _, e := range blobs {
+		expired := opts.MaxAge > 0 && time.Since(e.modTime) > opts.MaxAge
+		oversize := opts.MaxSize > 0 && total > opts.MaxSize
+		if !all && !expired && !oversize {
+			continue
+		}
+		if err := os.Remove(e.path); err != nil {
+			return result, fmt.Errorf("删除 %s 失败: %v", e.path, err)
+		}
+		result.Removed++
+		result.Freed += e.size
+		total -= e.size
+	}
+	result.Remaining = total
+	return result, nil
+}
+
+// cachedImage 从缓存读取镜像层，缺失的层下载时写入缓存
+type cachedImage struct {
+	v1.Image
+	cache *BlobCache
+}
+
+// withCache 用缓存包装镜像，cache 为 nil 时原样返回
+func withCache(img v1.Image, cache *BlobCache) v1.Image {
+	if cache == nil {
+		return img
+	}
+	return &cachedImage{Image: img, cache: cache}
+}
+
+func (i *cachedImage) Layers() ([]v1.Layer, error) {
+	layers, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]v1.Layer, len(layers))
+	for n, l := range layers {
+		out[n] = &cachedLayer{Layer: l, cache: i.cache}
+	}
+	return out, nil
+}
+
+func (i *cachedImage) LayerByDigest(h v1.Hash) (v1.Layer, error) {
+	l, err := i.Image.LayerByDigest(h)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedLayer{Layer: l, cache: i.cache}, nil
+}
+
+func (i *cachedImage) LayerByDiffID(h v1.Hash) (v1.Layer, error) {
+	l, err := i.Image.LayerByDiffID(h)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedLayer{Layer: l, cache: i.cache}, nil
+}
+
+// cachedLayer 优先从缓存读取的层
+type cachedLayer struct {
+	v1.Layer
+	cache *BlobCache
+}
+
+func (l *cachedLayer) Compressed() (io.ReadCloser, error) {
+	d, err := l.Digest()
+	if err != nil {
+		return nil, err
+	}
+	size, err := l.Size()
+	if err != nil {
+		return nil, err
+	}
+	if l.cache.has(d, size) {
+		if rc, err := l.cache.open(d); err == nil {
+			return rc, nil
+		}
+	}
+	// 未缓存的层经由 Transport 下载，下载的同时写入缓存
+	return l.Layer.Compressed()
+}
+
+// Uncompressed 解压缓存中的数据，使未压缩读取（如导出根文件系统）同样使用缓存
+func (l *cachedLayer) Uncompressed() (io.ReadCloser, error) {
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	return decompress(rc)
+}
+
+// cachedIndex 镜像索引中的镜像都使用缓存
+type cachedIndex struct {
+	inner v1.ImageIndex
+	cache *BlobCache
+}
+
+// withCacheIndex 用缓存包装镜像索引，cache 为 nil 时原样返回
+func withCacheIndex(idx v1.ImageIndex, cache *BlobCache) v1.ImageIndex {
+	if cache == nil {
+		return idx
+	}
+	return &cachedIndex{inner: idx, cache: cache}
+}
+
+func (i *cachedIndex) MediaType() (types.MediaType, error)       { return i.inner.MediaType() }
+func (i *cachedIndex) Digest() (v1.Hash, error)                  { return i.inner.Digest() }
+func (i *cachedIndex) Size() (int64, error)                      { return i.inner.Size() }
+func (i *cachedIndex) IndexManifest() (*v1.IndexManifest, error) { return i.inner.IndexManifest() }
+func (i *cachedIndex) RawManifest() ([]byte, error)              { return i.inner.RawManifest() }
+
+func (i *cachedIndex) Image(h v1.Hash) (v1.Image, error) {
+	img, err := i.inner.Image(h)
+	if err != nil {
+		return nil, err
+	}
+	return withCache(img, i.cache), nil
+}
+
+func (i *cachedIndex) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
+	idx, err := i.inner.ImageIndex(h)
+	if err != nil {
+		return nil, err
+	}
+	return withCacheIndex(idx, i.cache), nil
+}
+
+// noteCached 统计结果中已在缓存里的层，记录到结果并计入下载进度
+func (o *PullOptions) noteCached(cache *BlobCache, rt *TotalTrackingRoundTripper, result *PullResult) {
+	result.CachedLayers, result.CachedBytes = 0, 0
+	if cache == nil {
+		return
+	}
+	for _, l := range result.Layers {
+		d, err := v1.NewHash(l.Digest)
+		if err != nil || !types.MediaType(l.MediaType).IsDistributable() {
+			continue
+		}
+		if cache.has(d, l.Size) {
+			result.CachedLayers++
+			result.CachedBytes += l.Size
+			rt.tracker.MarkCached(d)
+		}
+	}
+	if cache.temporary {
+		// 重试前已下载到临时目录的层照常计入进度，但不算作缓存命中
+		result.CachedLayers, result.CachedBytes = 0, 0
+		return
+	}
+	switch {
+	case result.CachedLayers == 0:
+		return
+	case result.CachedLayers == len(result.Layers):
+		o.logMsg("info", "全部 %d 层已在本地缓存中 (%s)，无需下载", result.CachedLayers, FormatBytes(result.CachedBytes))
+	default:
+		o.logMsg("info", "本地缓存中已有 %d/%d 层 (%s)，只下载缺失的层", result.CachedLayers, len(result.Layers), FormatBytes(result.CachedBytes))
+	}
+}
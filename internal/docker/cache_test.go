@@ -0,0 +1,137 @@
+package docker
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"dipt/internal/types"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestPullReusesCachedLayers(t *testing.T) {
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	var mu sync.Mutex
+	fetched := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if i := strings.LastIndex(r.URL.Path, "/blobs/"); i >= 0 && r.Method == http.MethodGet {
+			mu.Lock()
+			fetched[r.URL.Path[i+len("/blobs/"):]]++
+			mu.Unlock()
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(4096, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(u.Host + "/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DIPT_CACHE_DIR", t.TempDir())
+	dir := t.TempDir()
+	pull := func(file string) *PullResult {
+		t.Helper()
+		result, err := PullAndSave(PullOptions{
+			ImageName:  ref.String(),
+			OutputFile: filepath.Join(dir, file),
+			Platform:   types.Platform{OS: "linux", Arch: "amd64"},
+		})
+		if err != nil {
+			t.Fatalf("PullAndSave() error = %v", err)
+		}
+		return result
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layerFetches := func() (n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, l := range layers {
+			d, _ := l.Digest()
+			n += fetched[d.String()]
+		}
+		return n
+	}
+
+	pull("first.tar")
+	if n := layerFetches(); n != 3 {
+		t.Fatalf("first pull fetched layers %d times, want 3", n)
+	}
+	result := pull("second.tar")
+	if result.CachedLayers != 3 {
+		t.Errorf("cached layers = %d, want 3", result.CachedLayers)
+	}
+	if n := layerFetches(); n != 3 {
+		t.Errorf("second pull fetched layers again (%d fetches in total)", n)
+	}
+
+	// 大小不变但内容损坏的缓存层不能被使用，应删除后重新下载
+	cache := DefaultBlobCache()
+	d, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := os.ReadFile(cache.path(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob[len(blob)/2] ^= 0xff
+	if err := os.WriteFile(cache.path(d), blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	result = pull("third.tar")
+	if result.CachedLayers != 2 {
+		t.Errorf("cached layers with a corrupted blob = %d, want 2", result.CachedLayers)
+	}
+	if n := layerFetches(); n != 4 {
+		t.Errorf("layers fetched %d times in total, want 4 (corrupted layer fetched again)", n)
+	}
+	if got, err := fileSHA256(cache.path(d)); err != nil || got != d.Hex {
+		t.Errorf("cached layer after re-download = %s (%v), want %s", got, err, d.Hex)
+	}
+	if report, err := VerifyArchive(filepath.Join(dir, "third.tar"), nil); err != nil || !report.OK() {
+		t.Errorf("VerifyArchive() = %+v, %v, want a valid archive", report, err)
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 三个层与 config
+	if stats.Blobs != 4 {
+		t.Errorf("cache holds %d blobs, want 4", stats.Blobs)
+	}
+	pruned, err := cache.Prune(PruneOptions{MaxSize: stats.Size - 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned.Removed != 1 || pruned.Remaining >= stats.Size {
+		t.Errorf("prune = %+v, want one blob removed", pruned)
+	}
+}
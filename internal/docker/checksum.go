@@ -0,0 +1,120 @@
+package docker
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checksumSuffix 校验和文件后缀，内容与 sha256sum 输出格式相同，可直接用 sha256sum -c 校验
+const checksumSuffix = ".sha256"
+
+// ChecksumPath 返回输出文件对应的校验和文件路径
+func ChecksumPath(output string) string {
+	return output + checksumSuffix
+}
+
+// fileSum 校验和文件中的一行
+type fileSum struct {
+	Name   string // 相对校验和文件所在目录的文件名
+	SHA256 string
+}
+
+// writeChecksumFile 在 output 旁写出校验和文件
+func writeChecksumFile(output string, sums []fileSum) error {
+	var b strings.Builder
+	for _, s := range sums {
+		fmt.Fprintf(&b, "%s  %s\n", s.SHA256, s.Name)
+	}
+	if err := writeFileAtomic(ChecksumPath(output), []byte(b.String())); err != nil {
+		return fmt.Errorf("写入校验和文件失败: %v", err)
+	}
+	return nil
+}
+
+// writeFileAtomic 先写入临时文件再重命名为 path，写入失败时已有的同名文件保持不变
+func writeFileAtomic(path string, data []byte) error {
+	tmp := partialPath(path)
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// readChecksumFile 读取 sha256sum 格式的校验和文件
+func readChecksumFile(path string) ([]fileSum, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sums []fileSum
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		if !ok || len(sum) != sha256.Size*2 || name == "" || name != filepath.Base(name) {
+			return nil, fmt.Errorf("校验和文件 %s 格式无效: %s", path, line)
+		}
+		sums = append(sums, fileSum{Name: name, SHA256: strings.ToLower(sum)})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// ChecksumMismatch 与校验和文件不符的文件
+type ChecksumMismatch struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// verifyChecksumFile 按校验和文件重新计算各文件的 SHA-256，返回不符的文件
+func verifyChecksumFile(path string) ([]ChecksumMismatch, error) {
+	sums, err := readChecksumFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var bad []ChecksumMismatch
+	dir := filepath.Dir(path)
+	for _, s := range sums {
+		got, err := fileSHA256(filepath.Join(dir, s.Name))
+		switch {
+		case err != nil:
+			bad = append(bad, ChecksumMismatch{File: s.Name, Reason: fmt.Sprintf("无法读取: %v", err)})
+		case got != s.SHA256:
+			bad = append(bad, ChecksumMismatch{File: s.Name, Reason: fmt.Sprintf("SHA-256 为 %s，应为 %s", got, s.SHA256)})
+		}
+	}
+	return bad, nil
+}
+
+// fileSHA256 计算文件的 SHA-256
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,215 @@
+package docker
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"dipt/internal/split"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression 输出文件的压缩算法
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// Compressions 所有支持的压缩算法
+var Compressions = []Compression{CompressionNone, CompressionGzip, CompressionZstd}
+
+// ParseCompression 解析压缩算法，空字符串表示按输出文件扩展名决定
+func ParseCompression(s string) (Compression, error) {
+	if s == "" {
+		return "", nil
+	}
+	for _, c := range Compressions {
+		if string(c) == s {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("不支持的压缩算法: %s (可选: none, gzip, zstd)", s)
+}
+
+// CompressionFromPath 根据文件扩展名推断压缩算法
+func CompressionFromPath(path string) Compression {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".gz"), strings.HasSuffix(lower, ".tgz"):
+		return CompressionGzip
+	case strings.HasSuffix(lower, ".zst"), strings.HasSuffix(lower, ".tzst"):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// Extension 返回压缩算法追加的文件扩展名
+func (c Compression) Extension() string {
+	switch c {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// AppendCompressionExtension 为自动生成的输出路径追加压缩扩展名（已有时不重复追加）
+func AppendCompressionExtension(path string, c Compression) string {
+	ext := c.Extension()
+	if ext == "" || strings.HasSuffix(strings.ToLower(path), ext) {
+		return path
+	}
+	return path + ext
+}
+
+// ValidateCompressionLevel 检查压缩级别，0 表示使用默认级别
+// gzip 为 1-9，zstd 为 1-22
+func ValidateCompressionLevel(c Compression, level int) error {
+	if level == 0 {
+		return nil
+	}
+	switch c {
+	case CompressionGzip:
+		if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return fmt.Errorf("gzip 压缩级别应为 1-9，当前为 %d", level)
+		}
+	case CompressionZstd:
+		if level < 1 || level > 22 {
+			return fmt.Errorf("zstd 压缩级别应为 1-22，当前为 %d", level)
+		}
+	default:
+		return fmt.Errorf("未启用压缩时不能指定压缩级别")
+	}
+	return nil
+}
+
+// createOutput 创建输出文件，按压缩算法包装写入流，splitSize 大于 0 时按该大小分卷写入
+// 数据边写边压缩，不会在磁盘上留下未压缩的副本；onWritten 报告已写入磁盘的字节数
+// 写入的同时计算落盘数据的 SHA-256（分卷时由分卷清单记录各分卷的校验和）
+func createOutput(path string, c Compression, level int, splitSize int64, onWritten func(int64)) (*outputFile, error) {
+	out := &outputFile{hash: sha256.New()}
+	if splitSize > 0 {
+		sw, err := split.NewWriter(path, splitSize)
+		if err != nil {
+			return nil, err
+		}
+		out.sink, out.abort = sw, sw.Abort
+	} else {
+		f, err := os.Create(partialPath(path))
+		if err != nil {
+			return nil, fmt.Errorf("创建输出文件失败: %v", err)
+		}
+		out.sink = &renameOnClose{File: f, target: path}
+		out.abort = func() {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+	out.counter = &countingWriter{w: io.MultiWriter(out.sink, out.hash), onWrite: onWritten}
+
+	switch c {
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gw, err := gzip.NewWriterLevel(out.counter, level)
+		if err != nil {
+			out.abort()
+			return nil, err
+		}
+		out.w, out.compressor = gw, gw
+	case CompressionZstd:
+		eopts := []zstd.EOption{}
+		if level != 0 {
+			eopts = append(eopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		zw, err := zstd.NewWriter(out.counter, eopts...)
+		if err != nil {
+			out.abort()
+			return nil, err
+		}
+		out.w, out.compressor = zw, zw
+	default:
+		out.w = out.counter
+	}
+	return out, nil
+}
+
+// partialPath 返回写入 path 时使用的临时文件路径
+// 输出先写入临时文件，完整写完后才替换 path，写入失败或取消时不会破坏已有的同名文件
+func partialPath(path string) string {
+	return path + ".partial"
+}
+
+// renameOnClose 关闭临时文件后将其重命名为目标文件
+type renameOnClose struct {
+	*os.File
+	target string
+}
+
+func (r *renameOnClose) Close() error {
+	if err := r.File.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(r.File.Name(), r.target); err != nil {
+		return fmt.Errorf("写入输出文件失败: %v", err)
+	}
+	return nil
+}
+
+// outputFile 输出文件写入流，Close 时依次关闭压缩器与文件（或分卷）
+type outputFile struct {
+	w          io.Writer
+	compressor io.Closer
+	counter    *countingWriter
+	sink       io.WriteCloser
+	hash       hash.Hash
+	abort      func() // 删除已写入的不完整输出
+}
+
+// sum 返回已写入磁盘数据的 SHA-256
+func (o *outputFile) sum() string {
+	return hex.EncodeToString(o.hash.Sum(nil))
+}
+
+func (o *outputFile) Write(p []byte) (int, error) {
+	return o.w.Write(p)
+}
+
+func (o *outputFile) Close() error {
+	if o.compressor != nil {
+		if err := o.compressor.Close(); err != nil {
+			o.sink.Close()
+			return fmt.Errorf("写入压缩数据失败: %v", err)
+		}
+	}
+	return o.sink.Close()
+}
+
+// countingWriter 统计写入字节数
+type countingWriter struct {
+	w       io.Writer
+	n       int64
+	onWrite func(int64)
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if c.onWrite != nil && n > 0 {
+		c.onWrite(c.n)
+	}
+	return n, err
+}
@@ -0,0 +1,242 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"dipt/internal/errors"
+	"dipt/internal/retry"
+	"dipt/internal/types"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// CopyOptions 仓库间复制选项
+type CopyOptions struct {
+	Source      string // 源镜像引用
+	Destination string // 目标引用，未写 tag 或 digest 时沿用源镜像的 tag
+	Insecure    bool   // 使用 HTTP 访问目标仓库
+
+	Config     types.Config
+	OnProgress ProgressCallback        // 上传进度
+	OnLog      func(level, msg string) // 日志回调
+
+	// Context 由调用方控制取消，为 nil 时不可取消；超时只作用于读取清单，不限制 blob 的传输时间
+	Context context.Context
+}
+
+// context 返回复制使用的 context
+func (o *CopyOptions) context() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// logMsg 发送日志消息
+func (o *CopyOptions) logMsg(level, format string, args ...interface{}) {
+	if o.OnLog != nil {
+		o.OnLog(level, fmt.Sprintf(format, args...))
+	}
+}
+
+// CopyResult 一次复制的结果
+type CopyResult struct {
+	Source          string           `json:"source"`
+	Destination     string           `json:"destination"`
+	Mirror          string           `json:"mirror,omitempty"` // 源端实际使用的镜像加速器，直连时为空
+	Digest          string           `json:"digest"`
+	MediaType       string           `json:"media_type"`
+	Platforms       []types.Platform `json:"platforms,omitempty"`  // 复制多平台索引时包含的平台
+	UpToDate        bool             `json:"up_to_date,omitempty"` // 目标已是相同 digest，未上传任何内容
+	Retries         int              `json:"retries"`
+	Duration        time.Duration    `json:"-"`
+	DurationSeconds float64          `json:"duration_seconds"`
+}
+
+// CopyImage 将镜像从源仓库直接复制到目标仓库，数据不落盘
+// 多平台索引按原样复制；目标仓库已存在的 blob 不会重复上传；
+// 源为 Docker Hub 镜像时与拉取一样优先使用配置的镜像加速器
+func CopyImage(opts CopyOptions) (*CopyResult, error) {
+	start := time.Now()
+	result := &CopyResult{Source: opts.Source}
+	defer func() {
+		result.Duration = time.Since(start)
+		result.DurationSeconds = result.Duration.Round(time.Millisecond).Seconds()
+	}()
+
+	src, err := name.ParseReference(opts.Source)
+	if err != nil {
+		return nil, errors.NewImageNotFoundError(opts.Source, err)
+	}
+	dst, err := CopyDestination(src, opts.Destination, opts.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	result.Destination = dst.String()
+
+	ctx := opts.context()
+	config := withCustomMirror(opts.Config, opts.OnLog)
+	copyFrom := func(from name.Reference, auth remote.Option) error {
+		return retry.WithRetryContext(ctx, func() error {
+			return copyOnce(ctx, from, dst, auth, opts, result)
+		}, retry.DefaultConfig(), fmt.Sprintf("复制镜像 [%s]", from))
+	}
+
+	if len(config.Registry.Mirrors) > 0 && IsDockerHubImage(src) {
+		attempts := 0
+		err = NewMirrorManager(config.Registry.Mirrors).TryPullWithMirrors(src, nil, func(level, msg string) {
+			opts.logMsg(level, "%s", msg)
+		}, func(from name.Reference, mirrorURL string) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if attempts > 0 {
+				result.Retries++
+			}
+			attempts++
+			result.Mirror = mirrorURL
+			if mirrorURL == "" {
+				return copyFrom(from, copySourceAuth(opts.Config, from.Context().Registry))
+			}
+			// 镜像加速器使用匿名认证，不传原始仓库的凭据
+			return copyFrom(from, remote.WithAuth(authn.Anonymous))
+		})
+	} else {
+		err = copyFrom(src, copySourceAuth(opts.Config, src.Context().Registry))
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.NewCanceledError(err)
+		} else if errors.IsManifestUnknownError(err) {
+			return nil, errors.NewImageNotFoundError(opts.Source, err)
+		} else if errors.IsUnauthorizedError(err) {
+			return nil, errors.NewUnauthorizedError(src.Context().RegistryStr()+" 或 "+dst.Context().RegistryStr(), err)
+		} else if errors.IsNetworkError(err) {
+			return nil, errors.NewNetworkError(err)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// copySourceAuth 返回源仓库的认证：配置的用户名和密码只发给凭据所属的仓库，
+// 其他源仓库使用 docker login 保存的凭据，没有时匿名访问
+func copySourceAuth(cfg types.Config, reg name.Registry) remote.Option {
+	if auth, ok := configAuthFor(cfg, reg); ok {
+		return remote.WithAuth(auth)
+	}
+	return remote.WithAuthFromKeychain(authn.DefaultKeychain)
+}
+
+// copyOnce 读取源镜像描述并写入目标仓库
+// 镜像索引用 remote.WriteIndex 写入，保留所有平台与索引 digest
+func copyOnce(ctx context.Context, src, dst name.Reference, auth remote.Option, opts CopyOptions, result *CopyResult) error {
+	// 源镜像的 blob 沿用 remote.Get 的 context 读取，因此这里不能用 WithTimeout，
+	// 而是在读取清单超时时取消，读取完成后停止计时
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := time.AfterFunc(requestTimeout(), cancel)
+	desc, err := remote.Get(src, auth, remote.WithContext(ctx))
+	if !timer.Stop() && err == nil {
+		err = context.DeadlineExceeded
+	}
+	if err != nil {
+		return err
+	}
+	result.Digest = desc.Digest.String()
+	result.MediaType = string(desc.MediaType)
+
+	// 目标仓库使用 docker login 保存的凭据
+	dstOpts := []remote.Option{remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain)}
+	headCtx, headCancel := context.WithTimeout(ctx, requestTimeout())
+	head, err := remote.Head(dst, remote.WithContext(headCtx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	headCancel()
+	if err == nil && head.Digest == desc.Digest {
+		result.UpToDate = true
+		opts.logMsg("info", "%s 已是 %s，无需复制", dst, desc.Digest)
+		return nil
+	}
+
+	updates := make(chan v1.Update, 16)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for u := range updates {
+			if opts.OnProgress != nil && u.Total > 0 {
+				opts.OnProgress(u.Complete, u.Total)
+			}
+		}
+	}()
+	dstOpts = append(dstOpts, remote.WithProgress(updates))
+
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			close(updates)
+			<-done
+			return err
+		}
+		result.Platforms = indexPlatforms(idx)
+		opts.logMsg("info", "复制多平台索引 %s（%d 个平台）-> %s", src, len(result.Platforms), dst)
+		err = remote.WriteIndex(dst, idx, dstOpts...)
+		<-done
+		return err
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		close(updates)
+		<-done
+		return err
+	}
+	opts.logMsg("info", "复制 %s -> %s", src, dst)
+	err = remote.Write(dst, img, dstOpts...)
+	<-done
+	return err
+}
+
+// indexPlatforms 返回镜像索引中各镜像的平台
+func indexPlatforms(idx v1.ImageIndex) []types.Platform {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil
+	}
+	var platforms []types.Platform
+	for _, m := range im.Manifests {
+		if m.Platform != nil && m.Platform.OS != "unknown" {
+			platforms = append(platforms, types.Platform{OS: m.Platform.OS, Arch: m.Platform.Architecture})
+		}
+	}
+	return platforms
+}
+
+// CopyDestination 解析复制的目标引用，未写 tag 或 digest 时沿用源镜像的 tag（或 digest）
+func CopyDestination(src name.Reference, dst string, insecure bool) (name.Reference, error) {
+	var opts []name.Option
+	if insecure {
+		opts = append(opts, name.Insecure)
+	}
+	dst = strings.TrimSpace(dst)
+	last := dst[strings.LastIndex(dst, "/")+1:]
+	if !strings.Contains(last, ":") && !strings.Contains(dst, "@") {
+		repo, err := name.NewRepository(dst, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("目标引用 %s 无效: %v", dst, err)
+		}
+		if d, ok := src.(name.Digest); ok {
+			return repo.Digest(d.DigestStr()), nil
+		}
+		return repo.Tag(src.Identifier()), nil
+	}
+	ref, err := name.ParseReference(dst, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("目标引用 %s 无效: %v", dst, err)
+	}
+	return ref, nil
+}
@@ -0,0 +1,188 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dipt/internal/errors"
+	"dipt/internal/types"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestCopyImagePreservesIndex(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := random.Index(512, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := name.NewTag(u.Host + "/src/app:7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(src, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := CopyImage(CopyOptions{Source: src.String(), Destination: u.Host + "/mirror/app"})
+	if err != nil {
+		t.Fatalf("CopyImage() error = %v", err)
+	}
+	if want := u.Host + "/mirror/app:7"; result.Destination != want {
+		t.Errorf("destination = %s, want %s", result.Destination, want)
+	}
+
+	dst, err := name.ParseReference(result.Destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Get(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := idx.Digest()
+	if desc.Digest != want || !desc.MediaType.IsIndex() {
+		t.Errorf("copied %s (%s), want index %s", desc.Digest, desc.MediaType, want)
+	}
+
+	again, err := CopyImage(CopyOptions{Source: src.String(), Destination: result.Destination})
+	if err != nil {
+		t.Fatalf("second CopyImage() error = %v", err)
+	}
+	if !again.UpToDate {
+		t.Error("second copy should find the destination up to date")
+	}
+}
+
+func TestCopyImageDestinationAuth(t *testing.T) {
+	dockerConfig := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dockerConfig)
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, seen := basicAuthRegistry(t, "pusher", "push-secret")
+
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := name.NewTag(u.Host + "/src/app:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(src, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// 配置的凭据属于源仓库，不能发送给目标仓库
+	opts := CopyOptions{
+		Source:      src.String(),
+		Destination: host + "/mirror/app",
+		Config:      types.Config{Registry: types.Registry{Username: "puller", Password: "pull-secret", Server: host}},
+	}
+	if _, err := CopyImage(opts); err == nil {
+		t.Fatal("copy without docker login credentials for the destination should fail")
+	}
+	if got := seen(); len(got) > 0 {
+		t.Fatalf("source credentials sent to the destination: %v", got)
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte("pusher:push-secret"))
+	config := fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, host, auth)
+	if err := os.WriteFile(filepath.Join(dockerConfig, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CopyImage(opts); err != nil {
+		t.Fatalf("CopyImage() with docker login credentials error = %v", err)
+	}
+}
+
+func TestCopyImageSourceAuth(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	host, seen := basicAuthRegistry(t, "puller", "pull-secret")
+	dstSrv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer dstSrv.Close()
+	u, err := url.Parse(dstSrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := name.NewTag(host + "/src/app:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(src, img, remote.WithAuth(authn.FromConfig(authn.AuthConfig{Username: "puller", Password: "pull-secret"}))); err != nil {
+		t.Fatal(err)
+	}
+	before := len(seen())
+
+	// 配置的凭据属于其他仓库，不能发送给源仓库
+	opts := CopyOptions{
+		Source:      src.String(),
+		Destination: u.Host + "/mirror/app",
+		Config:      types.Config{Registry: types.Registry{Username: "puller", Password: "pull-secret", Server: "registry.example.com"}},
+	}
+	if _, err := CopyImage(opts); err == nil {
+		t.Fatal("copy with credentials for another registry should fail")
+	}
+	if got := seen()[before:]; len(got) > 0 {
+		t.Fatalf("credentials for another registry sent to the source: %v", got)
+	}
+
+	opts.Config.Registry.Server = "http://" + host + "/"
+	if _, err := CopyImage(opts); err != nil {
+		t.Fatalf("CopyImage() with credentials for the source error = %v", err)
+	}
+}
+
+func TestCopyImageCanceled(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := name.NewTag(u.Host + "/src/app:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(src, img); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = CopyImage(CopyOptions{Source: src.String(), Destination: u.Host + "/mirror/app", Context: ctx})
+	if !errors.IsCanceledError(err) {
+		t.Fatalf("CopyImage() with canceled context error = %v, want canceled", err)
+	}
+}
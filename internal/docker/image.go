@@ -3,8 +3,8 @@ package docker
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -16,9 +16,8 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
-	v1types "github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	v1types "github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 // PullOptions 拉取选项
@@ -26,9 +25,58 @@ type PullOptions struct {
 	ImageName  string
 	OutputFile string
 	Platform   types.Platform
+	Format     OutputFormat // 输出格式，默认 docker
+
+	OutputOptions // 压缩与分卷
+
+	// 多平台拉取：AllPlatforms 保存镜像索引中的全部平台，Platforms 仅保存列出的平台
+	// 两者任一设置时忽略 Platform，结果保存为一个镜像索引，仅支持 oci/oci-archive 格式
+	AllPlatforms bool
+	Platforms    []types.Platform
+
+	// SkipMetadata 为 true 时不在输出旁写出 <输出>.dipt.json 元数据文件（如打包时的中间产物）
+	SkipMetadata bool
+
+	// Context 由调用方控制拉取的取消（如 TUI 中按 esc、命令行中按 ctrl+c），为 nil 时不可取消；
+	// 取消后返回 errors.IsCanceledError 可识别的错误，并删除本次拉取新建的不完整输出
+	Context context.Context
+
 	Config     types.Config
-	OnProgress ProgressCallback          // 进度回调
-	OnLog      func(level, msg string)   // 日志回调
+	OnProgress ProgressCallback        // 进度回调
+	OnLayers   LayerProgressCallback   // 各层的下载状态
+	OnLog      func(level, msg string) // 日志回调
+	OnWritten  func(written int64)     // 已写入输出文件的字节数（压缩后）
+	OnMirror   func(mirror string)     // 开始使用某个镜像加速器，空字符串表示改用原始地址
+}
+
+// format 返回输出格式，未设置时为 docker
+func (o *PullOptions) format() OutputFormat {
+	if o.Format == "" {
+		return FormatDocker
+	}
+	return o.Format
+}
+
+// canceled 调用方是否已取消本次拉取
+func (o *PullOptions) canceled() bool {
+	return o.Context != nil && o.Context.Err() != nil
+}
+
+// pullContext 返回拉取使用的上下文：调用方的上下文加上与仓库交互的超时
+func (o *PullOptions) pullContext() (context.Context, context.CancelFunc) {
+	parent := o.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(parent, requestTimeout())
+}
+
+// noteMirror 记录并通知本次拉取使用的镜像加速器
+func (o *PullOptions) noteMirror(result *PullResult, mirror string) {
+	result.Mirror = mirror
+	if o.OnMirror != nil {
+		o.OnMirror(mirror)
+	}
 }
 
 // logMsg 发送日志消息
@@ -39,40 +87,61 @@ func (o *PullOptions) logMsg(level, format string, args ...interface{}) {
 }
 
 // PullAndSave 拉取镜像并保存为 tar 文件（新接口）
-func PullAndSave(opts PullOptions) error {
+func PullAndSave(opts PullOptions) (res *PullResult, err error) {
+	start := time.Now()
+	result := &PullResult{
+		Reference:  opts.ImageName,
+		Platform:   opts.Platform,
+		OutputFile: opts.OutputFile,
+	}
+	defer result.finish(start)
+
 	// 检查是否为演练模式
 	if os.Getenv("DIPT_DRY_RUN") == "1" {
 		opts.logMsg("info", "[演练模式] 将拉取镜像 %s 并保存到 %s", opts.ImageName, opts.OutputFile)
-		opts.logMsg("info", "[演练模式] 平台: %s/%s", opts.Platform.OS, opts.Platform.Arch)
+		opts.logMsg("info", "[演练模式] 平台: %s，格式: %s", opts.platformLabel(), opts.format())
 		opts.logMsg("success", "[演练模式] 检测完成，未执行实际操作")
-		return nil
+		result.DryRun = true
+		return result, nil
 	}
 
-	ref, err := name.ParseReference(opts.ImageName)
-	if err != nil {
-		return errors.NewImageNotFoundError(opts.ImageName, err)
+	if err := opts.output().validate(); err != nil {
+		return nil, err
 	}
-
-	var auth authn.Authenticator
-	if opts.Config.Registry.Username != "" && opts.Config.Registry.Password != "" {
-		auth = authn.FromConfig(authn.AuthConfig{
-			Username: opts.Config.Registry.Username,
-			Password: opts.Config.Registry.Password,
-		})
-	} else {
-		auth = authn.Anonymous
+	if opts.multiPlatform() {
+		if f := opts.format(); f != FormatOCI && f != FormatOCIArchive {
+			return nil, fmt.Errorf("多平台拉取仅支持 oci 或 oci-archive 格式，当前为 %s", f)
+		}
+		result.Platform = types.Platform{}
 	}
 
-	options := []remote.Option{remote.WithAuth(auth)}
+	ref, err := name.ParseReference(opts.ImageName)
+	if err != nil {
+		return nil, errors.NewImageNotFoundError(opts.ImageName, err)
+	}
 
-	// 读取超时配置
-	timeout := 120 * time.Second
-	if t := os.Getenv("DIPT_TIMEOUT"); t != "" {
-		if sec, perr := strconv.Atoi(t); perr == nil && sec > 0 {
-			timeout = time.Duration(sec) * time.Second
+	// 被取消时删除本次新建的输出（文件输出写入失败时已删除，目录输出需要在这里清理）
+	_, statErr := os.Stat(opts.OutputFile)
+	existed := statErr == nil
+	defer func() {
+		if err == nil || !opts.canceled() {
+			return
 		}
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if !existed {
+			os.RemoveAll(opts.OutputFile)
+		}
+		opts.logMsg("warning", "拉取已取消")
+		res, err = nil, errors.NewCanceledError(err)
+	}()
+
+	// 清单、config 与层都经由同一个 Transport 获取：解析一次的描述符直接用于统计大小、进度与写入，
+	// 缓存中已有的层与续传前已下载的部分都计入进度
+	cache, cleanup := opts.pullCache()
+	defer cleanup()
+	rt := opts.transport(cache)
+	options := []remote.Option{remote.WithAuth(sourceAuth(opts.Config)), remote.WithTransport(rt)}
+
+	ctx, cancel := opts.pullContext()
 	defer cancel()
 	options = append(options, remote.WithContext(ctx))
 
@@ -83,11 +152,7 @@ func PullAndSave(opts PullOptions) error {
 	}))
 
 	// 处理自定义镜像源
-	config := opts.Config
-	if customMirror := os.Getenv("DIPT_CUSTOM_MIRROR"); customMirror != "" {
-		config.Registry.Mirrors = append([]string{customMirror}, config.Registry.Mirrors...)
-		opts.logMsg("info", "使用自定义镜像源: %s", customMirror)
-	}
+	config := withCustomMirror(opts.Config, opts.OnLog)
 
 	// 尝试使用镜像加速器
 	if len(config.Registry.Mirrors) > 0 && IsDockerHubImage(ref) {
@@ -109,31 +174,54 @@ func PullAndSave(opts PullOptions) error {
 		// 替换 auth 为匿名认证
 		mirrorOptions = append(mirrorOptions, remote.WithAuth(authn.Anonymous))
 
+		mirrorAttempts := 0
 		err = mirrorManager.TryPullWithMirrors(ref, mirrorOptions, mirrorLogFunc, func(mirrorRef name.Reference, mirrorURL string) error {
-			return retry.WithRetry(func() error {
-				desc, err := remote.Get(mirrorRef, mirrorOptions...)
-				if err != nil {
-					return err
+			if mirrorAttempts > 0 {
+				result.Retries++
+			}
+			mirrorAttempts++
+			opts.noteMirror(result, mirrorURL)
+			// 下载失败重试时沿用已获取的描述符，不再重新请求清单
+			var desc *remote.Descriptor
+			err := retry.WithRetryContext(ctx, countRetries(result, func() error {
+				if desc == nil {
+					d, err := remote.Get(mirrorRef, mirrorOptions...)
+					if err != nil {
+						return err
+					}
+					desc = d
 				}
-				return downloadAndSave(mirrorRef, opts.OutputFile, desc, mirrorOptions, &opts)
-			}, retryConfig, fmt.Sprintf("拉取镜像 [%s]", mirrorURL))
+				return downloadAndSave(mirrorRef, ref, desc, cache, rt, &opts, result)
+			}), retryConfig, fmt.Sprintf("拉取镜像 [%s]", mirrorURL))
+			if err != nil && opts.canceled() {
+				return context.Canceled
+			}
+			return err
 		})
 
 		if err == nil {
-			return nil
+			return result, nil
 		}
+		if opts.canceled() {
+			return nil, err
+		}
+		result.Retries++
+		opts.noteMirror(result, "")
 		opts.logMsg("warning", "镜像加速器失败，尝试使用原始地址")
 	}
 
 	// 使用原始地址
 	retryConfig := retry.DefaultConfig()
 	var desc *remote.Descriptor
-	err = retry.WithRetry(func() error {
+	err = retry.WithRetryContext(ctx, countRetries(result, func() error {
 		var getErr error
 		desc, getErr = remote.Get(ref, options...)
 		return getErr
-	}, retryConfig, fmt.Sprintf("获取镜像元数据 [%s]", opts.ImageName))
+	}), retryConfig, fmt.Sprintf("获取镜像元数据 [%s]", opts.ImageName))
 
+	if err != nil && opts.canceled() {
+		return nil, err
+	}
 	if err != nil {
 		// 特殊处理 docker.dragonflydb.io
 		if strings.Contains(err.Error(), "ghcr.io") && strings.Contains(opts.ImageName, "docker.dragonflydb.io") {
@@ -141,16 +229,19 @@ func PullAndSave(opts PullOptions) error {
 			newImageName := strings.Replace(opts.ImageName, "docker.dragonflydb.io", "ghcr.io", 1)
 			newRef, parseErr := name.ParseReference(newImageName)
 			if parseErr == nil {
-				err = retry.WithRetry(func() error {
+				err = retry.WithRetryContext(ctx, countRetries(result, func() error {
 					var getErr error
 					desc, getErr = remote.Get(newRef, options...)
 					return getErr
-				}, retryConfig, "获取镜像元数据 [ghcr.io]")
+				}), retryConfig, "获取镜像元数据 [ghcr.io]")
 
 				if err == nil {
 					ref = newRef
 					opts.logMsg("success", "成功使用 ghcr.io 地址: %s", newImageName)
-					return downloadAndSave(ref, opts.OutputFile, desc, options, &opts)
+					if err := downloadAndSave(ref, ref, desc, cache, rt, &opts, result); err != nil {
+						return nil, err
+					}
+					return result, nil
 				}
 			}
 		}
@@ -158,7 +249,7 @@ func PullAndSave(opts PullOptions) error {
 		// GitHub Container Registry 认证问题
 		registry := ref.Context().Registry.Name()
 		if (registry == "ghcr.io" || strings.Contains(opts.ImageName, "docker.dragonflydb.io")) && strings.Contains(err.Error(), "DENIED") {
-			return &errors.DiptError{
+			return nil, &errors.DiptError{
 				Type: errors.ErrorUnauthorized,
 				Message: fmt.Sprintf("访问 GitHub Container Registry 需要认证\n建议：\n"+
 					"1. 对于 DragonflyDB，请使用正确的镜像地址: ghcr.io/dragonflydb/dragonfly:latest\n"+
@@ -170,31 +261,97 @@ func PullAndSave(opts PullOptions) error {
 		}
 
 		if errors.IsManifestUnknownError(err) {
-			return errors.NewPlatformNotSupportedError(opts.ImageName, opts.Platform.OS, opts.Platform.Arch, err)
+			return nil, errors.NewPlatformNotSupportedError(opts.ImageName, opts.Platform.OS, opts.Platform.Arch, err)
 		} else if errors.IsUnauthorizedError(err) {
-			return errors.NewUnauthorizedError(ref.Context().RegistryStr(), err)
+			return nil, errors.NewUnauthorizedError(ref.Context().RegistryStr(), err)
 		} else if errors.IsNetworkError(err) {
-			return errors.NewNetworkError(err)
+			return nil, errors.NewNetworkError(err)
+		}
+		return nil, errors.NewImageNotFoundError(opts.ImageName, err)
+	}
+
+	if err := downloadAndSave(ref, ref, desc, cache, rt, &opts, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// sourceAuth 返回拉取使用的认证：配置了仓库用户名和密码时使用配置，否则匿名访问
+func sourceAuth(cfg types.Config) authn.Authenticator {
+	if cfg.Registry.Username != "" && cfg.Registry.Password != "" {
+		return authn.FromConfig(authn.AuthConfig{
+			Username: cfg.Registry.Username,
+			Password: cfg.Registry.Password,
+		})
+	}
+	return authn.Anonymous
+}
+
+// withCustomMirror 将环境变量 DIPT_CUSTOM_MIRROR 指定的镜像源放到镜像源列表最前面
+func withCustomMirror(cfg types.Config, onLog func(level, msg string)) types.Config {
+	customMirror := os.Getenv("DIPT_CUSTOM_MIRROR")
+	if customMirror == "" {
+		return cfg
+	}
+	cfg.Registry.Mirrors = append([]string{customMirror}, cfg.Registry.Mirrors...)
+	if onLog != nil {
+		onLog("info", "使用自定义镜像源: "+customMirror)
+	}
+	return cfg
+}
+
+// requestTimeout 返回与仓库交互的超时时间，可通过 DIPT_TIMEOUT（秒）设置，默认 120 秒
+func requestTimeout() time.Duration {
+	if t := os.Getenv("DIPT_TIMEOUT"); t != "" {
+		if sec, err := strconv.Atoi(t); err == nil && sec > 0 {
+			return time.Duration(sec) * time.Second
 		}
-		return errors.NewImageNotFoundError(opts.ImageName, err)
 	}
+	return 120 * time.Second
+}
 
-	return downloadAndSave(ref, opts.OutputFile, desc, options, &opts)
+// countRetries 包装可重试函数，每次失败时累加结果中的重试计数
+func countRetries(result *PullResult, fn retry.RetryableFunc) retry.RetryableFunc {
+	attempts := 0
+	return func() error {
+		if attempts > 0 {
+			result.Retries++
+		}
+		attempts++
+		return fn()
+	}
 }
 
 // downloadAndSave 下载并保存镜像
-func downloadAndSave(ref name.Reference, outputFile string, desc *remote.Descriptor, options []remote.Option, opts *PullOptions) error {
-	metaImg, err := desc.Image()
+// ref 为实际拉取的引用（可能指向镜像加速器），origRef 为写入 tar 的原始引用
+// desc 由 remote.Get 经 rt 获取，解析出的镜像直接用于写入，清单与 config 只请求一次
+func downloadAndSave(ref, origRef name.Reference, desc *remote.Descriptor, cache *BlobCache, rt *TotalTrackingRoundTripper, opts *PullOptions, result *PullResult) error {
+	if opts.multiPlatform() {
+		return downloadAndSaveIndex(ref, origRef, desc, cache, rt, opts, result)
+	}
+	outputFile := opts.OutputFile
+	img, err := desc.Image()
 	if err != nil {
 		if errors.IsPlatformNotSupportedError(err) {
-			return errors.NewPlatformNotSupportedError(ref.Name(), "", "", err)
+			return errors.NewPlatformNotSupportedError(ref.Name(), opts.Platform.OS, opts.Platform.Arch, err)
+		} else if errors.IsUnauthorizedError(err) {
+			return errors.NewUnauthorizedError(ref.Context().RegistryStr(), err)
+		} else if errors.IsNetworkError(err) {
+			return errors.NewNetworkError(err)
 		}
 		return fmt.Errorf("获取镜像元数据失败: %v", err)
 	}
-	m, err := metaImg.Manifest()
+	m, err := img.Manifest()
 	if err != nil {
 		return fmt.Errorf("获取镜像清单失败: %v", err)
 	}
+	digest, err := img.Digest()
+	if err != nil {
+		return fmt.Errorf("计算镜像 digest 失败: %v", err)
+	}
+	if desc.MediaType.IsIndex() {
+		result.IndexDigest = desc.Digest.String()
+	}
 
 	var totalSize int64
 	totalSize += m.Config.Size
@@ -205,38 +362,52 @@ func downloadAndSave(ref name.Reference, outputFile string, desc *remote.Descrip
 		totalSize += l.Size
 	}
 
-	opts.logMsg("info", "镜像总大小: %s", formatBytesStr(totalSize))
-
-	// 使用带总量追踪的 RoundTripper
-	rt := NewTotalTrackingRoundTripper(http.DefaultTransport, totalSize, opts.OnProgress)
-	dlOptions := append(options, remote.WithTransport(rt))
+	opts.logMsg("info", "镜像总大小: %s", FormatBytes(totalSize))
+	result.Source = ref.Name()
+	result.ResolvedReference = origRef.Context().Digest(digest.String()).String()
+	result.recordManifest(digest, m, totalSize)
+
+	rt.tracker.Reset(totalSize, append([]v1.Descriptor{m.Config}, m.Layers...))
+	opts.noteCached(cache, rt, result)
+	// config 随镜像缓存在内存中，写入时不再下载
+	if cf, err := img.ConfigFile(); err == nil {
+		result.Labels = cf.Config.Labels
+		if !cf.Created.IsZero() {
+			created := cf.Created.UTC()
+			result.Created = &created
+		}
+	}
 
-	img, err := remote.Image(ref, dlOptions...)
+	layers, err := img.Layers()
+	if err == nil {
+		err = opts.prefetchLayers(cache, layers)
+	}
 	if err != nil {
-		if errors.IsPlatformNotSupportedError(err) {
-			return errors.NewPlatformNotSupportedError(ref.Name(), "", "", err)
-		} else if errors.IsUnauthorizedError(err) {
-			return errors.NewUnauthorizedError(ref.Context().RegistryStr(), err)
-		} else if errors.IsNetworkError(err) {
+		if errors.IsNetworkError(err) {
 			return errors.NewNetworkError(err)
 		}
-		return fmt.Errorf("拉取镜像失败: %v", err)
+		return fmt.Errorf("下载镜像层失败: %v", err)
 	}
 
-	err = tarball.WriteToFile(outputFile, ref, img)
+	out := opts.output()
+	err = writeImage(out, origRef, withCache(img, cache))
 	if err != nil {
-		return fmt.Errorf("保存镜像到 tar 文件失败: %v", err)
+		return fmt.Errorf("保存镜像失败 (%s): %v", opts.format(), err)
 	}
+	out.record(result)
+	opts.saveMetadata(out, result)
 
-	// 报告 100% 进度
-	if opts.OnProgress != nil {
-		opts.OnProgress(totalSize, totalSize)
+	rt.tracker.Complete()
+	if opts.format().IsRootfs() {
+		opts.logMsg("success", "根文件系统已导出到 %s%s", outputFile, out.sizeNote())
+	} else {
+		opts.logMsg("success", "镜像已保存到 %s%s", outputFile, out.sizeNote())
 	}
-	opts.logMsg("success", "镜像已保存到 %s", outputFile)
 	return nil
 }
 
-func formatBytesStr(b int64) string {
+// FormatBytes 将字节数格式化为可读字符串
+func FormatBytes(b int64) string {
 	const unit = 1024
 	if b < unit {
 		return fmt.Sprintf("%d B", b)
@@ -250,23 +421,43 @@ func formatBytesStr(b int64) string {
 }
 
 // ParseImageName 从镜像名称中提取软件名和版本
+// 支持带端口的仓库地址（registry:5000/app:tag）以及 digest 引用（app@sha256:...）
 func ParseImageName(imageName string) (software, version string) {
-	parts := strings.Split(imageName, ":")
-	if len(parts) < 2 {
-		return CleanSoftwareName(parts[0]), "latest"
+	repo, version := imageName, "latest"
+	if i := strings.Index(repo, "@"); i >= 0 {
+		repo, version = repo[:i], strings.TrimPrefix(repo[i+1:], "sha256:")
+		if len(version) > 12 {
+			version = version[:12]
+		}
+	} else if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo, version = repo[:i], repo[i+1:]
 	}
-	software = CleanSoftwareName(parts[0])
-	return software, parts[1]
+	return CleanSoftwareName(repo), version
 }
 
-// CleanSoftwareName 处理软件名称，替换斜杠为下划线
+// CleanSoftwareName 处理软件名称，替换斜杠和冒号为下划线
 func CleanSoftwareName(name string) string {
 	name = strings.ReplaceAll(name, "/", "_")
+	name = strings.ReplaceAll(name, ":", "_")
 	return name
 }
 
 // GenerateOutputFileName 生成输出文件名
 func GenerateOutputFileName(imageName string, platform types.Platform) string {
+	return GenerateOutputName(imageName, platform, FormatDocker)
+}
+
+// GenerateOutputName 按输出格式生成输出文件（或目录）名
+func GenerateOutputName(imageName string, platform types.Platform, format OutputFormat) string {
 	software, version := ParseImageName(imageName)
-	return fmt.Sprintf("%s_%s_%s_%s.tar", software, version, platform.OS, platform.Arch)
+	return fmt.Sprintf("%s_%s_%s_%s%s", software, version, platform.OS, platform.Arch, format.Extension())
+}
+
+// DefaultOutputPath 计算默认输出路径（saveDir 为空时使用当前目录）
+func DefaultOutputPath(imageName string, platform types.Platform, format OutputFormat, saveDir string) string {
+	fileName := GenerateOutputName(imageName, platform, format)
+	if saveDir == "" {
+		return fileName
+	}
+	return filepath.Join(saveDir, fileName)
 }
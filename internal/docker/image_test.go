@@ -0,0 +1,165 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"dipt/internal/errors"
+	"dipt/internal/types"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// TestPullRequestCount 拉取时清单、config 与每个层都只向仓库请求一次
+func TestPullRequestCount(t *testing.T) {
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	var (
+		mu       sync.Mutex
+		requests = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var idx v1.ImageIndex = empty.Index
+	for _, arch := range []string{"amd64", "arm64"} {
+		img, err := random.Image(2048, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: arch}},
+		})
+	}
+	ref, err := name.NewTag(u.Host + "/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts PullOptions
+		// 索引与所选平台的清单、config 与层
+		want int
+	}{
+		{"single platform", PullOptions{Platform: types.Platform{OS: "linux", Arch: "arm64"}}, 1 + 1 + 1 + 2},
+		{"all platforms", PullOptions{AllPlatforms: true, Format: FormatOCIArchive}, 1 + 2*(1+1+2)},
+		{"platform subset", PullOptions{Platforms: []types.Platform{{OS: "linux", Arch: "amd64"}}, Format: FormatOCI}, 1 + 1 + 1 + 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("DIPT_CACHE_DIR", t.TempDir())
+			mu.Lock()
+			clear(requests)
+			mu.Unlock()
+
+			opts := tc.opts
+			opts.ImageName = ref.String()
+			opts.OutputFile = filepath.Join(t.TempDir(), "app.tar")
+			var downloaded, total int64
+			opts.OnProgress = func(d, t int64) { downloaded, total = d, t }
+			result, err := PullAndSave(opts)
+			if err != nil {
+				t.Fatalf("PullAndSave() error = %v", err)
+			}
+			if total == 0 || downloaded != total {
+				t.Errorf("progress ended at %d/%d", downloaded, total)
+			}
+			// 多平台拉取记录的是仓库中的索引 digest，即使只保存了部分平台
+			if opts.multiPlatform() {
+				if want, _ := idx.Digest(); result.IndexDigest != want.String() || result.ResolvedReference != ref.Context().Digest(want.String()).String() {
+					t.Errorf("index digest = %s, resolved = %s, want %s", result.IndexDigest, result.ResolvedReference, want)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			fetched := 0
+			for req, n := range requests {
+				if req == "GET /v2/" {
+					continue
+				}
+				if n > 1 {
+					t.Errorf("%s requested %d times", req, n)
+				}
+				fetched++
+			}
+			if fetched != tc.want {
+				t.Errorf("pull made %d distinct requests, want %d: %v", fetched, tc.want, requests)
+			}
+		})
+	}
+}
+
+// TestPullCanceled 取消拉取后返回取消错误，并删除不完整的输出
+func TestPullCanceled(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1<<20, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(u.Host + "/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []OutputFormat{FormatDocker, FormatOCI} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Setenv("DIPT_CACHE_DIR", t.TempDir())
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			output := filepath.Join(t.TempDir(), "app")
+			_, err := PullAndSave(PullOptions{
+				ImageName:  ref.String(),
+				OutputFile: output,
+				Format:     format,
+				Context:    ctx,
+				// 开始下载后立即取消
+				OnProgress: func(downloaded, total int64) {
+					if downloaded > 0 {
+						cancel()
+					}
+				},
+			})
+			if !errors.IsCanceledError(err) {
+				t.Fatalf("PullAndSave() error = %v, want canceled", err)
+			}
+			if _, err := os.Stat(output); !os.IsNotExist(err) {
+				t.Errorf("partial output %s left behind", output)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"dipt/internal/split"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// NamedImage 从保存的文件中读出的一个镜像（v1.Image 或 v1.ImageIndex）及其名称
+type NamedImage struct {
+	Ref  name.Reference
+	Item mutate.Appendable
+}
+
+// ImageSources 展开镜像来源：文件或 OCI layout 目录本身，或目录中的全部镜像文件
+// 分卷输出以输出文件路径表示；rootfs 导出不是镜像，会被跳过
+func ImageSources(source string) ([]string, error) {
+	source = strings.TrimSuffix(source, ".parts.json")
+	fi, err := os.Stat(source)
+	if err != nil {
+		if _, merr := os.Stat(split.ManifestPath(source)); merr == nil {
+			return []string{source}, nil
+		}
+		return nil, fmt.Errorf("文件不存在: %s", source)
+	}
+	if !fi.IsDir() || isOCILayout(source) {
+		return []string{source}, nil
+	}
+
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var sources []string
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			sources = append(sources, p)
+		}
+	}
+	for _, e := range entries {
+		p := filepath.Join(source, e.Name())
+		switch {
+		case e.IsDir():
+			if isOCILayout(p) {
+				add(p)
+			}
+		case strings.HasSuffix(e.Name(), ".parts.json"):
+			add(strings.TrimSuffix(p, ".parts.json"))
+		case isImageArchiveName(e.Name()):
+			add(p)
+		}
+	}
+	sort.Strings(sources)
+	return sources, nil
+}
+
+// isImageArchiveName 按扩展名判断是否为保存的镜像 tar（不含 rootfs 导出）
+func isImageArchiveName(base string) bool {
+	if strings.HasSuffix(base, ".tgz") {
+		return true
+	}
+	base = strings.TrimSuffix(strings.TrimSuffix(base, ".gz"), ".zst")
+	return strings.HasSuffix(base, ".tar") && !strings.HasSuffix(base, FormatRootfs.Extension())
+}
+
+// isOCILayout 判断目录是否为 OCI layout
+func isOCILayout(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "oci-layout"))
+	return err == nil
+}
+
+// LoadImages 读取文件中的全部镜像
+// docker save 格式的 tar 中每个 RepoTag 为一个镜像；oci-archive 解压到临时目录后按 OCI layout 读取
+// 返回的 cleanup 用于清理临时目录，使用完镜像后调用
+func LoadImages(src string) ([]NamedImage, func(), error) {
+	noop := func() {}
+	if isOCILayout(src) {
+		items, err := layoutItems(src, src)
+		return items, noop, err
+	}
+
+	opener, _, raw, err := archiveOpener(src)
+	if err != nil {
+		return nil, noop, err
+	}
+	tmp, err := os.MkdirTemp("", "dipt-push-")
+	if err != nil {
+		return nil, noop, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	if !raw {
+		// tarball.Image 每读一个层都会重新打开 tar，压缩或分卷的输出先解压合并到临时文件，只读一遍
+		if opener, err = spoolArchive(opener, filepath.Join(tmp, "image.tar")); err != nil {
+			cleanup()
+			return nil, noop, err
+		}
+	}
+	manifest, err := tarball.LoadManifest(opener)
+	if err == nil {
+		items, err := tarballItems(src, opener, manifest)
+		if err != nil {
+			cleanup()
+			return nil, noop, err
+		}
+		return items, cleanup, nil
+	}
+
+	// 不是 docker save 格式时按 oci-archive 解压
+	rc, err := opener()
+	if err != nil {
+		cleanup()
+		return nil, noop, err
+	}
+	defer rc.Close()
+	dir := filepath.Join(tmp, "layout")
+	if _, err := extractTar(rc, dir); err != nil || !isOCILayout(dir) {
+		cleanup()
+		return nil, noop, fmt.Errorf("不是 docker save 格式的 tar，也不是 oci-archive")
+	}
+	items, err := layoutItems(dir, src)
+	if err != nil {
+		cleanup()
+		return nil, noop, err
+	}
+	return items, cleanup, nil
+}
+
+// spoolArchive 把 opener 读出的 tar 完整写入 path，返回直接打开该文件的 Opener
+func spoolArchive(opener tarball.Opener, path string) (tarball.Opener, error) {
+	rc, err := opener()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("读取镜像文件失败: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("写入临时文件失败: %v", err)
+	}
+	return func() (io.ReadCloser, error) { return os.Open(path) }, nil
+}
+
+// tarballItems 读取 docker save 格式 tar 中的镜像
+func tarballItems(src string, opener tarball.Opener, manifest tarball.Manifest) ([]NamedImage, error) {
+	var items []NamedImage
+	for _, desc := range manifest {
+		tags := desc.RepoTags
+		if len(tags) == 0 {
+			ref := referenceFromMetadata(src, "")
+			if ref == "" {
+				return nil, fmt.Errorf("tar 中的镜像 %s 没有记录镜像名称", desc.Config)
+			}
+			tags = []string{ref}
+		}
+		for _, t := range tags {
+			tag, err := name.NewTag(t)
+			if err != nil {
+				return nil, fmt.Errorf("tar 中的镜像名称 %s 无效: %v", t, err)
+			}
+			var tagArg *name.Tag
+			if len(manifest) > 1 {
+				tagArg = &tag
+			}
+			img, err := tarball.Image(opener, tagArg)
+			if err != nil {
+				return nil, fmt.Errorf("读取镜像 %s 失败: %v", t, err)
+			}
+			items = append(items, NamedImage{Ref: tag, Item: img})
+		}
+	}
+	return items, nil
+}
+
+// layoutItems 读取 OCI layout 中的镜像，名称取自 index.json 中的注解
+// 注解中只有 tag 时，仓库名取自元数据文件中记录的镜像引用
+func layoutItems(dir, src string) ([]NamedImage, error) {
+	lp, err := layout.FromPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("打开 OCI layout 失败: %v", err)
+	}
+	idx, err := lp.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []NamedImage
+	for _, desc := range im.Manifests {
+		refStr := desc.Annotations[annotationContainerdRef]
+		if refStr == "" {
+			refStr = referenceFromMetadata(src, desc.Annotations[annotationRefName])
+		}
+		if refStr == "" {
+			return nil, fmt.Errorf("OCI layout 中的 %s 没有记录镜像名称", desc.Digest)
+		}
+		ref, err := name.ParseReference(refStr)
+		if err != nil {
+			return nil, fmt.Errorf("OCI layout 中的镜像名称 %s 无效: %v", refStr, err)
+		}
+
+		var item mutate.Appendable
+		if desc.MediaType.IsIndex() {
+			item, err = idx.ImageIndex(desc.Digest)
+		} else {
+			item, err = idx.Image(desc.Digest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %v", ref, err)
+		}
+		items = append(items, NamedImage{Ref: ref, Item: item})
+	}
+	return items, nil
+}
+
+// referenceFromMetadata 从元数据文件中取镜像引用，tag 不为空时替换为该 tag
+func referenceFromMetadata(src, tag string) string {
+	md, err := ReadMetadata(src)
+	if err != nil || md.Reference == "" {
+		return ""
+	}
+	if tag == "" {
+		return md.Reference
+	}
+	ref, err := name.ParseReference(md.Reference)
+	if err != nil {
+		return ""
+	}
+	return ref.Context().Tag(tag).String()
+}
@@ -0,0 +1,211 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"dipt/internal/split"
+	"dipt/internal/types"
+	"dipt/internal/version"
+)
+
+// metadataSuffix 元数据文件后缀，元数据文件与输出文件（或目录）位于同一目录
+const metadataSuffix = ".dipt.json"
+
+// Metadata 记录保存下来的镜像来自哪里，写在输出文件旁的 <输出>.dipt.json 中
+type Metadata struct {
+	Output            string            `json:"output"` // 输出文件（或目录）名，相对元数据文件所在目录
+	Format            OutputFormat      `json:"format"`
+	Compression       Compression       `json:"compression,omitempty"`
+	PartsManifest     string            `json:"parts_manifest,omitempty"` // 分卷写入时的校验清单文件名
+	SHA256            string            `json:"sha256,omitempty"`         // 输出文件的 SHA-256
+	Reference         string            `json:"reference"`
+	ResolvedReference string            `json:"resolved_reference,omitempty"`
+	Source            string            `json:"source,omitempty"`
+	Mirror            string            `json:"mirror,omitempty"`
+	ManifestDigest    string            `json:"manifest_digest,omitempty"`
+	IndexDigest       string            `json:"index_digest,omitempty"`       // 从多平台索引中选出镜像时索引的 digest
+	SavedIndexDigest  string            `json:"saved_index_digest,omitempty"` // 只保存部分平台时输出中索引的 digest
+	ConfigDigest      string            `json:"config_digest,omitempty"`
+	MediaType         string            `json:"media_type,omitempty"`
+	Platform          *types.Platform   `json:"platform,omitempty"`
+	Platforms         []types.Platform  `json:"platforms,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Created           *time.Time        `json:"created,omitempty"` // 镜像配置中的创建时间
+	Layers            []LayerInfo       `json:"layers,omitempty"`
+	TotalSize         int64             `json:"total_size"`
+	Images            []*Metadata       `json:"images,omitempty"` // 打包文件中的各个镜像
+	DiptVersion       string            `json:"dipt_version"`
+	PulledAt          time.Time         `json:"pulled_at"`
+
+	// Path 读取时解析出的输出文件完整路径
+	Path string `json:"-"`
+}
+
+// MetadataPath 返回输出文件对应的元数据文件路径
+func MetadataPath(output string) string {
+	return output + metadataSuffix
+}
+
+// NewMetadata 根据拉取结果生成元数据
+func NewMetadata(result *PullResult, format OutputFormat, c Compression) *Metadata {
+	md := &Metadata{
+		Output:            filepath.Base(result.OutputFile),
+		Format:            format,
+		SHA256:            result.SHA256,
+		Reference:         result.Reference,
+		ResolvedReference: result.ResolvedReference,
+		Source:            result.Source,
+		Mirror:            result.Mirror,
+		ManifestDigest:    result.ManifestDigest,
+		IndexDigest:       result.IndexDigest,
+		SavedIndexDigest:  result.SavedIndexDigest,
+		ConfigDigest:      result.ConfigDigest,
+		MediaType:         result.MediaType,
+		Platforms:         result.Platforms,
+		Labels:            result.Labels,
+		Created:           result.Created,
+		Layers:            result.Layers,
+		TotalSize:         result.TotalSize,
+		DiptVersion:       version.Version,
+		PulledAt:          time.Now().UTC().Truncate(time.Second),
+	}
+	if c != CompressionNone {
+		md.Compression = c
+	}
+	if result.PartsManifest != "" {
+		md.PartsManifest = filepath.Base(result.PartsManifest)
+	}
+	if len(result.Platforms) == 0 {
+		p := result.Platform
+		md.Platform = &p
+	}
+	return md
+}
+
+// WriteMetadata 将元数据写到 output 旁的元数据文件
+func WriteMetadata(output string, md *Metadata) error {
+	data, err := json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(MetadataPath(output), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("写入元数据文件失败: %v", err)
+	}
+	return nil
+}
+
+// ReadMetadata 读取元数据，path 可以是输出文件（或目录）本身，也可以是元数据文件
+func ReadMetadata(path string) (*Metadata, error) {
+	if !strings.HasSuffix(path, metadataSuffix) {
+		path = MetadataPath(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("未找到元数据文件 %s", path)
+		}
+		return nil, fmt.Errorf("读取元数据文件失败: %v", err)
+	}
+	var md Metadata
+	if err := json.Unmarshal(data, &md); err != nil {
+		return nil, fmt.Errorf("解析元数据文件 %s 失败: %v", path, err)
+	}
+	md.Path = filepath.Join(filepath.Dir(path), md.Output)
+	return &md, nil
+}
+
+// ListMetadata 读取目录下的所有元数据文件，按拉取时间从新到旧排列
+// 无法解析的元数据文件会被跳过
+func ListMetadata(dir string) ([]*Metadata, error) {
+	matches, err := filepath.Glob(filepath.Join(split.GlobEscape(dir), "*"+metadataSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var list []*Metadata
+	for _, p := range matches {
+		md, err := ReadMetadata(p)
+		if err != nil {
+			continue
+		}
+		list = append(list, md)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].PulledAt.After(list[j].PulledAt)
+	})
+	return list, nil
+}
+
+// PlatformLabel 返回元数据中的平台描述
+func (md *Metadata) PlatformLabel() string {
+	if len(md.Platforms) > 0 {
+		return PlatformsLabel(false, md.Platforms)
+	}
+	if md.Platform != nil && md.Platform.OS != "" {
+		return md.Platform.OS + "/" + md.Platform.Arch
+	}
+	return ""
+}
+
+// Fields 返回用于展示的字段名与值，空值的字段会被省略
+func (md *Metadata) Fields() [][2]string {
+	var fields [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, [2]string{key, value})
+		}
+	}
+	add("文件", md.Path)
+	format := string(md.Format)
+	if md.Compression != "" {
+		format += " (" + string(md.Compression) + ")"
+	}
+	add("格式", format)
+	add("分卷清单", md.PartsManifest)
+	add("镜像", md.Reference)
+	add("解析引用", md.ResolvedReference)
+	add("拉取来源", md.Source)
+	add("镜像加速器", md.Mirror)
+	add("Manifest", md.ManifestDigest)
+	if md.IndexDigest != md.ManifestDigest {
+		add("索引", md.IndexDigest)
+	}
+	add("保存的索引", md.SavedIndexDigest)
+	add("Config", md.ConfigDigest)
+	add("平台", md.PlatformLabel())
+	if md.Created != nil {
+		add("创建时间", md.Created.Local().Format("2006-01-02 15:04:05"))
+	}
+	add("大小", FormatBytes(md.TotalSize))
+	if len(md.Layers) > 0 {
+		add("层数", fmt.Sprintf("%d", len(md.Layers)))
+	}
+	add("拉取时间", md.PulledAt.Local().Format("2006-01-02 15:04:05"))
+	add("dipt 版本", md.DiptVersion)
+	return fields
+}
+
+// SortedLabels 返回按键排序的 key=value 标签列表
+func (md *Metadata) SortedLabels() []string {
+	labels := make([]string, 0, len(md.Labels))
+	for k, v := range md.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// saveMetadata 在输出文件旁写出元数据文件，失败只记录警告
+func (o *PullOptions) saveMetadata(out outputSpec, result *PullResult) {
+	if o.SkipMetadata {
+		return
+	}
+	if err := WriteMetadata(out.path, NewMetadata(result, out.format, out.compression)); err != nil {
+		o.logMsg("warning", "%v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package docker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dipt/internal/types"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "nginx.tar.zst")
+	created := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	result := &PullResult{
+		Reference:      "nginx:1.27",
+		ManifestDigest: "sha256:aaa",
+		IndexDigest:    "sha256:bbb",
+		Platform:       types.Platform{OS: "linux", Arch: "arm64"},
+		Labels:         map[string]string{"maintainer": "nginx"},
+		Created:        &created,
+		OutputFile:     output,
+	}
+	if err := WriteMetadata(output, NewMetadata(result, FormatDocker, CompressionZstd)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 既可以传输出文件，也可以传元数据文件
+	for _, p := range []string{output, MetadataPath(output)} {
+		md, err := ReadMetadata(p)
+		if err != nil {
+			t.Fatalf("ReadMetadata(%s) error = %v", p, err)
+		}
+		if md.Path != output || md.Output != "nginx.tar.zst" {
+			t.Errorf("Path = %s, Output = %s", md.Path, md.Output)
+		}
+		if md.IndexDigest != "sha256:bbb" || md.Compression != CompressionZstd || md.PlatformLabel() != "linux/arm64" {
+			t.Errorf("metadata = %+v", md)
+		}
+		if md.Created == nil || !md.Created.Equal(created) || md.Labels["maintainer"] != "nginx" {
+			t.Errorf("created/labels = %v %v", md.Created, md.Labels)
+		}
+	}
+
+	list, err := ListMetadata(dir)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListMetadata() = %d items, %v", len(list), err)
+	}
+}
@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -211,6 +213,11 @@ func (m *MirrorManager) TryPullWithMirrors(
 			return nil
 		}
 
+		// 拉取被取消时不再尝试其他镜像源，也不标记该镜像源不可用
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+
 		lastErr = err
 		logFunc("warning", fmt.Sprintf("镜像源 %s 拉取失败: %v", mirror.URL, err))
 
@@ -0,0 +1,257 @@
+package docker
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"dipt/internal/errors"
+	"dipt/internal/types"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	v1types "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// AllPlatformsLabel 表示全部平台的标签，用于命令行参数、列表文件与输出文件名
+const AllPlatformsLabel = "all"
+
+// multiPlatform 是否按多平台镜像索引拉取
+func (o *PullOptions) multiPlatform() bool {
+	return o.AllPlatforms || len(o.Platforms) > 0
+}
+
+// platformLabel 返回日志与错误信息中使用的平台描述
+func (o *PullOptions) platformLabel() string {
+	if o.multiPlatform() {
+		return PlatformsLabel(o.AllPlatforms, o.Platforms)
+	}
+	return o.Platform.OS + "/" + o.Platform.Arch
+}
+
+// PlatformsLabel 返回多平台选择的描述，如 all 或 linux/amd64,linux/arm64
+func PlatformsLabel(all bool, platforms []types.Platform) string {
+	if all {
+		return AllPlatformsLabel
+	}
+	parts := make([]string, len(platforms))
+	for i, p := range platforms {
+		parts[i] = p.OS + "/" + p.Arch
+	}
+	return strings.Join(parts, ",")
+}
+
+// GenerateMultiOutputName 生成多平台镜像的输出文件（或目录）名
+func GenerateMultiOutputName(imageName string, all bool, platforms []types.Platform, format OutputFormat) string {
+	software, version := ParseImageName(imageName)
+	label := AllPlatformsLabel
+	if !all {
+		parts := make([]string, len(platforms))
+		for i, p := range platforms {
+			parts[i] = p.OS + "-" + p.Arch
+		}
+		label = strings.Join(parts, "_")
+	}
+	return fmt.Sprintf("%s_%s_%s%s", software, version, label, format.Extension())
+}
+
+// DefaultMultiOutputPath 计算多平台镜像的默认输出路径（saveDir 为空时使用当前目录）
+func DefaultMultiOutputPath(imageName string, all bool, platforms []types.Platform, format OutputFormat, saveDir string) string {
+	fileName := GenerateMultiOutputName(imageName, all, platforms, format)
+	if saveDir == "" {
+		return fileName
+	}
+	return filepath.Join(saveDir, fileName)
+}
+
+// filterPlatforms 从镜像索引中移除未选中的平台，AllPlatforms 时原样返回以保留索引 digest
+func filterPlatforms(idx v1.ImageIndex, opts *PullOptions) v1.ImageIndex {
+	if opts.AllPlatforms {
+		return idx
+	}
+	return mutate.RemoveManifests(idx, func(desc v1.Descriptor) bool {
+		if desc.Platform == nil {
+			return true
+		}
+		for _, p := range opts.Platforms {
+			if desc.Platform.OS == p.OS && desc.Platform.Architecture == p.Arch {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// resolvedIndex 记住已解析的子镜像与子索引：统计大小、预取与写入输出共用同一份清单，
+// 子清单只向仓库请求一次（remote 的镜像索引每次调用 Image 都会重新请求）
+type resolvedIndex struct {
+	inner v1.ImageIndex
+
+	mu      sync.Mutex
+	images  map[v1.Hash]v1.Image
+	indexes map[v1.Hash]v1.ImageIndex
+}
+
+func resolveOnce(idx v1.ImageIndex) *resolvedIndex {
+	return &resolvedIndex{
+		inner:   idx,
+		images:  make(map[v1.Hash]v1.Image),
+		indexes: make(map[v1.Hash]v1.ImageIndex),
+	}
+}
+
+func (i *resolvedIndex) MediaType() (v1types.MediaType, error)     { return i.inner.MediaType() }
+func (i *resolvedIndex) Digest() (v1.Hash, error)                  { return i.inner.Digest() }
+func (i *resolvedIndex) Size() (int64, error)                      { return i.inner.Size() }
+func (i *resolvedIndex) IndexManifest() (*v1.IndexManifest, error) { return i.inner.IndexManifest() }
+func (i *resolvedIndex) RawManifest() ([]byte, error)              { return i.inner.RawManifest() }
+
+func (i *resolvedIndex) Image(h v1.Hash) (v1.Image, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if img, ok := i.images[h]; ok {
+		return img, nil
+	}
+	img, err := i.inner.Image(h)
+	if err != nil {
+		return nil, err
+	}
+	i.images[h] = img
+	return img, nil
+}
+
+func (i *resolvedIndex) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if idx, ok := i.indexes[h]; ok {
+		return idx, nil
+	}
+	idx, err := i.inner.ImageIndex(h)
+	if err != nil {
+		return nil, err
+	}
+	child := resolveOnce(idx)
+	i.indexes[h] = child
+	return child, nil
+}
+
+// downloadAndSaveIndex 下载多平台镜像索引及其包含的所有镜像，保存为一个 OCI 产物
+// 远端不是多平台镜像时退化为保存单一镜像
+func downloadAndSaveIndex(ref, origRef name.Reference, desc *remote.Descriptor, cache *BlobCache, rt *TotalTrackingRoundTripper, opts *PullOptions, result *PullResult) error {
+	if !desc.MediaType.IsIndex() {
+		opts.logMsg("warning", "%s 不是多平台镜像，仅保存单一平台", ref.Name())
+		single := *opts
+		single.AllPlatforms, single.Platforms = false, nil
+		return downloadAndSave(ref, origRef, desc, cache, rt, &single, result)
+	}
+
+	remoteIdx, err := desc.ImageIndex()
+	if err != nil {
+		return fmt.Errorf("获取镜像索引失败: %v", err)
+	}
+	metaIdx := resolveOnce(filterPlatforms(remoteIdx, opts))
+	im, err := metaIdx.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("获取镜像索引失败: %v", err)
+	}
+	if len(im.Manifests) == 0 {
+		return &errors.DiptError{
+			Type:    errors.ErrorPlatformNotSupported,
+			Message: fmt.Sprintf("镜像 %s 不包含所选平台 %s", ref.Name(), opts.platformLabel()),
+		}
+	}
+	if !opts.AllPlatforms && len(im.Manifests) < len(opts.Platforms) {
+		opts.logMsg("warning", "镜像仅包含 %d/%d 个所选平台", len(im.Manifests), len(opts.Platforms))
+	}
+	digest, err := metaIdx.Digest()
+	if err != nil {
+		return fmt.Errorf("计算镜像索引 digest 失败: %v", err)
+	}
+
+	// 汇总各平台清单的大小与层信息，相同的层只计算一次
+	var totalSize int64
+	seen := make(map[v1.Hash]bool)
+	var tracked []v1.Descriptor
+	result.Layers = nil
+	result.Platforms = nil
+	for _, child := range im.Manifests {
+		if child.Platform != nil {
+			result.Platforms = append(result.Platforms, types.Platform{OS: child.Platform.OS, Arch: child.Platform.Architecture})
+		}
+		totalSize += child.Size
+		if !child.MediaType.IsImage() {
+			continue
+		}
+		img, err := metaIdx.Image(child.Digest)
+		if err != nil {
+			return fmt.Errorf("获取镜像元数据失败 (%s): %v", child.Digest, err)
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			return fmt.Errorf("获取镜像清单失败 (%s): %v", child.Digest, err)
+		}
+		blobs := append([]v1.Descriptor{m.Config}, m.Layers...)
+		for i, b := range blobs {
+			if seen[b.Digest] || !b.MediaType.IsDistributable() {
+				continue
+			}
+			seen[b.Digest] = true
+			tracked = append(tracked, b)
+			totalSize += b.Size
+			if i > 0 {
+				result.Layers = append(result.Layers, LayerInfo{
+					Digest:    b.Digest.String(),
+					Size:      b.Size,
+					MediaType: string(b.MediaType),
+				})
+			}
+		}
+	}
+
+	opts.logMsg("info", "多平台镜像，包含 %d 个平台: %s", len(result.Platforms), PlatformsLabel(false, result.Platforms))
+	opts.logMsg("info", "镜像总大小: %s", FormatBytes(totalSize))
+	result.Source = ref.Name()
+	// 记录仓库中的索引 digest，按平台筛选后的索引只存在于本地输出中，单独记录
+	result.ResolvedReference = origRef.Context().Digest(desc.Digest.String()).String()
+	result.ManifestDigest = desc.Digest.String()
+	result.IndexDigest = desc.Digest.String()
+	result.SavedIndexDigest = ""
+	if digest != desc.Digest {
+		result.SavedIndexDigest = digest.String()
+	}
+	result.ConfigDigest = ""
+	result.MediaType = string(im.MediaType)
+	if result.MediaType == "" {
+		result.MediaType = string(v1types.OCIImageIndex)
+	}
+	result.TotalSize = totalSize
+
+	// 进度只统计需要下载的 blob，总大小中的清单不经过 tracker，不计入进度总量
+	rt.tracker.Reset(0, tracked)
+	opts.noteCached(cache, rt, result)
+
+	layers, err := indexLayers(metaIdx)
+	if err == nil {
+		err = opts.prefetchLayers(cache, layers)
+	}
+	if err != nil {
+		if errors.IsNetworkError(err) {
+			return errors.NewNetworkError(err)
+		}
+		return fmt.Errorf("下载镜像层失败: %v", err)
+	}
+
+	out := opts.output()
+	if err := writeIndex(out, origRef, withCacheIndex(metaIdx, cache)); err != nil {
+		return fmt.Errorf("保存镜像失败 (%s): %v", opts.format(), err)
+	}
+	out.record(result)
+	opts.saveMetadata(out, result)
+
+	rt.tracker.Complete()
+	opts.logMsg("success", "镜像已保存到 %s%s", opts.OutputFile, out.sizeNote())
+	return nil
+}
@@ -0,0 +1,499 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dipt/internal/split"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	v1types "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// OutputFormat 镜像输出格式
+type OutputFormat string
+
+const (
+	FormatDocker     OutputFormat = "docker"      // docker save 兼容的 tar 文件
+	FormatOCI        OutputFormat = "oci"         // OCI image layout 目录
+	FormatOCIArchive OutputFormat = "oci-archive" // 打包为 tar 的 OCI image layout
+	FormatRootfs     OutputFormat = "rootfs"      // 合并所有层后的根文件系统 tar
+	FormatRootfsDir  OutputFormat = "rootfs-dir"  // 合并所有层后解压到目录的根文件系统
+)
+
+// OutputFormats 所有支持的输出格式
+var OutputFormats = []OutputFormat{FormatDocker, FormatOCI, FormatOCIArchive, FormatRootfs, FormatRootfsDir}
+
+// OCI layout 中标记镜像名称的注解
+const (
+	annotationRefName       = "org.opencontainers.image.ref.name"
+	annotationContainerdRef = "io.containerd.image.name"
+)
+
+// ParseOutputFormat 解析输出格式，空字符串视为 docker
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	if s == "" {
+		return FormatDocker, nil
+	}
+	for _, f := range OutputFormats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	names := make([]string, len(OutputFormats))
+	for i, f := range OutputFormats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("不支持的输出格式: %s (可选: %s)", s, strings.Join(names, ", "))
+}
+
+// Extension 返回该格式默认的文件扩展名，oci 目录返回空字符串
+func (f OutputFormat) Extension() string {
+	switch f {
+	case FormatOCI:
+		return ""
+	case FormatOCIArchive:
+		return ".oci.tar"
+	case FormatRootfs:
+		return ".rootfs.tar"
+	case FormatRootfsDir:
+		return ".rootfs"
+	default:
+		return ".tar"
+	}
+}
+
+// IsDir 判断该格式是否输出为目录（目录无法压缩或分卷）
+func (f OutputFormat) IsDir() bool {
+	return f == FormatOCI || f == FormatRootfsDir
+}
+
+// IsRootfs 判断该格式是否导出合并后的根文件系统而非可加载的镜像
+func (f OutputFormat) IsRootfs() bool {
+	return f == FormatRootfs || f == FormatRootfsDir
+}
+
+// archive 返回目录格式对应的 tar 流格式，用于分卷输出
+func (f OutputFormat) archive() OutputFormat {
+	switch f {
+	case FormatOCI:
+		return FormatOCIArchive
+	case FormatRootfsDir:
+		return FormatRootfs
+	default:
+		return f
+	}
+}
+
+// OutputOptions 输出文件的压缩与分卷设置
+type OutputOptions struct {
+	// Compression 为空时按输出文件扩展名（.gz/.tgz/.zst/.tzst）决定，CompressionLevel 为 0 时使用默认级别
+	Compression      Compression
+	CompressionLevel int
+	// SplitSize 大于 0 时按该大小写成 <输出>.part001、.part002… 分卷，并生成 <输出>.parts.json 校验清单
+	// 目录格式分卷时改为写出对应的 tar 流（oci 为 oci-archive，rootfs-dir 为 rootfs）
+	SplitSize int64
+}
+
+// outputSpec 输出文件的写入参数
+type outputSpec struct {
+	path        string
+	format      OutputFormat
+	compression Compression
+	level       int
+	splitSize   int64
+	onWritten   func(int64)
+	onLog       func(level, msg string)
+	ctx         context.Context // 不为 nil 时被取消后停止写入
+}
+
+// newOutputSpec 按输出选项生成写入参数，未指定压缩算法时按输出文件扩展名决定
+func newOutputSpec(path string, format OutputFormat, opts OutputOptions, onWritten func(int64)) outputSpec {
+	if opts.SplitSize > 0 {
+		format = format.archive()
+	}
+	c := opts.Compression
+	if c == "" {
+		c = CompressionNone
+		if !format.IsDir() {
+			c = CompressionFromPath(path)
+		}
+	}
+	return outputSpec{
+		path:        path,
+		format:      format,
+		compression: c,
+		level:       opts.CompressionLevel,
+		splitSize:   opts.SplitSize,
+		onWritten:   onWritten,
+	}
+}
+
+// output 返回本次拉取的输出参数
+func (o *PullOptions) output() outputSpec {
+	out := newOutputSpec(o.OutputFile, o.format(), o.OutputOptions, o.OnWritten)
+	out.onLog = o.OnLog
+	out.ctx = o.Context
+	return out
+}
+
+// validate 检查格式与压缩设置是否兼容
+func (s outputSpec) validate() error {
+	if s.format.IsDir() && s.compression != CompressionNone {
+		return fmt.Errorf("%s 格式输出为目录，无法压缩，请使用 %s", s.format, s.format.archive())
+	}
+	return ValidateCompressionLevel(s.compression, s.level)
+}
+
+// write 创建输出文件并以流的方式写入，完成后写出校验和文件，失败时删除不完整的文件或分卷
+func (s outputSpec) write(fn func(w io.Writer) error) error {
+	w, err := createOutput(s.path, s.compression, s.level, s.splitSize, s.onWritten)
+	if err != nil {
+		return err
+	}
+	var dst io.Writer = w
+	if s.ctx != nil {
+		dst = &ctxWriter{ctx: s.ctx, w: w}
+	}
+	if err := fn(dst); err != nil {
+		w.abort()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		w.abort()
+		return err
+	}
+	return s.writeChecksum(w)
+}
+
+// ctxWriter 在 ctx 被取消后拒绝写入，使读取本地缓存、不经过网络的写入也能及时停止
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c *ctxWriter) Write(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
+
+// writeChecksum 写出 sha256sum 格式的校验和文件，分卷时逐个列出分卷
+func (s outputSpec) writeChecksum(w *outputFile) error {
+	sums := []fileSum{{Name: filepath.Base(s.path), SHA256: w.sum()}}
+	if s.splitSize > 0 {
+		m, err := split.ReadManifest(split.ManifestPath(s.path))
+		if err != nil {
+			return err
+		}
+		sums = sums[:0]
+		for _, p := range m.Parts {
+			sums = append(sums, fileSum{Name: p.Name, SHA256: p.SHA256})
+		}
+	}
+	return writeChecksumFile(s.path, sums)
+}
+
+// record 将校验和与分卷信息写入拉取结果
+func (s outputSpec) record(result *PullResult) {
+	if s.format.IsDir() {
+		return
+	}
+	result.Checksums = ChecksumPath(s.path)
+	if s.splitSize <= 0 {
+		if sums, err := readChecksumFile(result.Checksums); err == nil && len(sums) == 1 {
+			result.SHA256 = sums[0].SHA256
+		}
+		return
+	}
+	result.PartsManifest = split.ManifestPath(s.path)
+	if m, err := split.ReadManifest(result.PartsManifest); err == nil {
+		result.Parts = make([]string, len(m.Parts))
+		for i, p := range m.Parts {
+			result.Parts[i] = filepath.Join(filepath.Dir(s.path), p.Name)
+		}
+	}
+}
+
+// sizeNote 压缩输出时返回压缩算法与文件大小说明，用于保存成功的日志
+func (s outputSpec) sizeNote() string {
+	if s.splitSize > 0 {
+		m, err := split.ReadManifest(split.ManifestPath(s.path))
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("（%d 个分卷，共 %s，清单 %s）", len(m.Parts), FormatBytes(m.Size), split.ManifestPath(s.path))
+	}
+	if s.compression == CompressionNone {
+		return ""
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("（%s 压缩，%s）", s.compression, FormatBytes(info.Size()))
+}
+
+// writeImage 按指定格式将镜像写入输出文件
+func writeImage(out outputSpec, ref name.Reference, img v1.Image) error {
+	switch out.format {
+	case FormatOCI:
+		return writeOCILayout(out.path, ref, img)
+	case FormatOCIArchive:
+		return out.write(func(w io.Writer) error { return writeOCIArchive(w, ref, img) })
+	case FormatRootfs:
+		return out.write(func(w io.Writer) error { return writeRootfs(w, img) })
+	case FormatRootfsDir:
+		skipped, err := extractRootfs(img, out.path)
+		if skipped > 0 && out.onLog != nil {
+			out.onLog("warning", fmt.Sprintf("已跳过 %d 个设备文件或 FIFO（需要 root 权限才能创建）", skipped))
+		}
+		return err
+	default:
+		return out.write(func(w io.Writer) error { return tarball.Write(ref, img, w) })
+	}
+}
+
+// writeIndex 按指定格式将多平台镜像索引写入输出文件，仅支持 OCI 格式
+func writeIndex(out outputSpec, ref name.Reference, idx v1.ImageIndex) error {
+	switch out.format {
+	case FormatOCI:
+		return writeOCILayout(out.path, ref, idx)
+	case FormatOCIArchive:
+		return out.write(func(w io.Writer) error { return writeOCIArchive(w, ref, idx) })
+	default:
+		return fmt.Errorf("%s 格式无法保存多平台镜像索引，请使用 oci 或 oci-archive", out.format)
+	}
+}
+
+// refAnnotations 返回 OCI layout 中标记镜像名称的注解
+func refAnnotations(ref name.Reference) map[string]string {
+	annotations := map[string]string{annotationContainerdRef: ref.Name()}
+	if tag, ok := ref.(name.Tag); ok {
+		annotations[annotationRefName] = tag.TagStr()
+	}
+	return annotations
+}
+
+// writeOCILayout 将镜像（v1.Image）或镜像索引（v1.ImageIndex）写入 OCI layout 目录
+// 目录已是 OCI layout 时追加进去，多个镜像共享相同的 blob；同名镜像会被替换
+func writeOCILayout(dir string, ref name.Reference, item mutate.Appendable) error {
+	lp, err := layout.FromPath(dir)
+	if err != nil {
+		if _, statErr := os.Stat(filepath.Join(dir, "index.json")); statErr == nil {
+			return fmt.Errorf("打开 OCI layout 失败: %v", err)
+		}
+		if lp, err = layout.Write(dir, empty.Index); err != nil {
+			return fmt.Errorf("创建 OCI layout 失败: %v", err)
+		}
+	}
+
+	annotations := refAnnotations(ref)
+	matcher := match.Annotation(annotationContainerdRef, ref.Name())
+	switch v := item.(type) {
+	case v1.ImageIndex:
+		return lp.ReplaceIndex(v, matcher, layout.WithAnnotations(annotations))
+	case v1.Image:
+		return lp.ReplaceImage(v, matcher, layout.WithAnnotations(annotations))
+	default:
+		return fmt.Errorf("不支持写入 OCI layout 的类型: %T", item)
+	}
+}
+
+// writeOCIArchive 将镜像或镜像索引以 OCI layout 的目录结构直接写成 tar 流
+// 层数据边下载边写入，不经过临时目录
+func writeOCIArchive(w io.Writer, ref name.Reference, item mutate.Appendable) error {
+	aw := &archiveWriter{tw: tar.NewWriter(w), seen: make(map[v1.Hash]bool)}
+	if err := aw.writeDir("blobs/"); err != nil {
+		return err
+	}
+	if err := aw.writeDir("blobs/sha256/"); err != nil {
+		return err
+	}
+	if err := aw.writeFile("oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return err
+	}
+
+	switch v := item.(type) {
+	case v1.ImageIndex:
+		if err := aw.writeIndex(v); err != nil {
+			return err
+		}
+	case v1.Image:
+		if err := aw.writeImage(v); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("不支持写入 OCI layout 的类型: %T", item)
+	}
+
+	desc, err := partial.Descriptor(item)
+	if err != nil {
+		return fmt.Errorf("读取镜像描述失败: %v", err)
+	}
+	desc.Annotations = refAnnotations(ref)
+	index, err := json.MarshalIndent(v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     v1types.OCIImageIndex,
+		Manifests:     []v1.Descriptor{*desc},
+	}, "", "   ")
+	if err != nil {
+		return err
+	}
+	if err := aw.writeFile("index.json", index); err != nil {
+		return err
+	}
+	if err := aw.tw.Close(); err != nil {
+		return fmt.Errorf("打包 OCI layout 失败: %v", err)
+	}
+	return nil
+}
+
+// archiveWriter 向 tar 流写入 OCI layout 的 blob，相同 digest 只写入一次
+type archiveWriter struct {
+	tw   *tar.Writer
+	seen map[v1.Hash]bool
+}
+
+func (a *archiveWriter) writeDir(name string) error {
+	return a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755})
+}
+
+func (a *archiveWriter) writeFile(name string, data []byte) error {
+	if err := a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return fmt.Errorf("打包 OCI layout 失败: %v", err)
+	}
+	_, err := a.tw.Write(data)
+	return err
+}
+
+func (a *archiveWriter) writeBlob(digest v1.Hash, data []byte) error {
+	if a.seen[digest] {
+		return nil
+	}
+	a.seen[digest] = true
+	return a.writeFile(blobPath(digest), data)
+}
+
+func blobPath(digest v1.Hash) string {
+	return "blobs/" + digest.Algorithm + "/" + digest.Hex
+}
+
+func (a *archiveWriter) writeIndex(idx v1.ImageIndex) error {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("读取镜像索引失败: %v", err)
+	}
+	for _, desc := range im.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := a.writeIndex(child); err != nil {
+				return err
+			}
+		case desc.MediaType.IsImage():
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := a.writeImage(img); err != nil {
+				return err
+			}
+		}
+	}
+	raw, err := idx.RawManifest()
+	if err != nil {
+		return err
+	}
+	digest, err := idx.Digest()
+	if err != nil {
+		return err
+	}
+	return a.writeBlob(digest, raw)
+}
+
+func (a *archiveWriter) writeImage(img v1.Image) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("读取镜像层失败: %v", err)
+	}
+	for _, layer := range layers {
+		if err := a.writeLayer(layer); err != nil {
+			return err
+		}
+	}
+
+	cfgName, err := img.ConfigName()
+	if err != nil {
+		return err
+	}
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	if err := a.writeBlob(cfgName, cfg); err != nil {
+		return err
+	}
+
+	raw, err := img.RawManifest()
+	if err != nil {
+		return err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return err
+	}
+	return a.writeBlob(digest, raw)
+}
+
+func (a *archiveWriter) writeLayer(layer v1.Layer) error {
+	mt, err := layer.MediaType()
+	if err != nil {
+		return err
+	}
+	if !mt.IsDistributable() {
+		return nil
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		return err
+	}
+	if a.seen[digest] {
+		return nil
+	}
+	size, err := layer.Size()
+	if err != nil {
+		return err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return fmt.Errorf("读取镜像层 %s 失败: %v", digest, err)
+	}
+	defer rc.Close()
+
+	if err := a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: blobPath(digest), Mode: 0644, Size: size}); err != nil {
+		return fmt.Errorf("打包 OCI layout 失败: %v", err)
+	}
+	if _, err := io.Copy(a.tw, rc); err != nil {
+		return fmt.Errorf("写入镜像层 %s 失败: %v", digest, err)
+	}
+	a.seen[digest] = true
+	return nil
+}
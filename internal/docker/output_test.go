@@ -0,0 +1,146 @@
+package docker
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestWriteOCIArchiveCompressed(t *testing.T) {
+	dir := t.TempDir()
+	img, err := random.Image(2048, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag("example.com/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var written int64
+	out := outputSpec{
+		path:        filepath.Join(dir, "app.oci.tar.gz"),
+		format:      FormatOCIArchive,
+		compression: CompressionGzip,
+		onWritten:   func(n int64) { written = n },
+	}
+	if err := writeImage(out, ref, img); err != nil {
+		t.Fatalf("writeImage() error = %v", err)
+	}
+	info, err := os.Stat(out.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != info.Size() {
+		t.Errorf("reported %d bytes written, file is %d bytes", written, info.Size())
+	}
+
+	f, err := os.Open(out.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("output is not gzip: %v", err)
+	}
+	layoutDir := filepath.Join(dir, "layout")
+	if _, err := extractTar(gr, layoutDir); err != nil {
+		t.Fatal(err)
+	}
+
+	lp, err := layout.FromPath(layoutDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := lp.ImageIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != 1 || im.Manifests[0].Annotations[annotationRefName] != "1.0" {
+		t.Fatalf("index.json manifests = %+v", im.Manifests)
+	}
+	got, err := idx.Image(im.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Errorf("archived image is invalid: %v", err)
+	}
+}
+
+func TestCompressionFromPath(t *testing.T) {
+	tests := map[string]Compression{
+		"a.tar":         CompressionNone,
+		"a.tar.gz":      CompressionGzip,
+		"a.TGZ":         CompressionGzip,
+		"a.oci.tar.zst": CompressionZstd,
+	}
+	for path, want := range tests {
+		if got := CompressionFromPath(path); got != want {
+			t.Errorf("CompressionFromPath(%q) = %s, want %s", path, got, want)
+		}
+	}
+	if got := AppendCompressionExtension("a.tar.gz", CompressionGzip); got != "a.tar.gz" {
+		t.Errorf("AppendCompressionExtension duplicated extension: %s", got)
+	}
+}
+
+// TestWriteFailureKeepsExistingOutput 写入失败（如网络错误或取消）时保留已有的同名输出
+func TestWriteFailureKeepsExistingOutput(t *testing.T) {
+	for _, splitSize := range []int64{0, 4} {
+		t.Run(fmt.Sprintf("split=%d", splitSize), func(t *testing.T) {
+			dir := t.TempDir()
+			out := outputSpec{path: filepath.Join(dir, "app.tar"), format: FormatDocker, compression: CompressionNone, splitSize: splitSize}
+			if err := out.write(func(w io.Writer) error {
+				_, err := io.WriteString(w, "good output")
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+			before := snapshotDir(t, dir)
+
+			err := out.write(func(w io.Writer) error {
+				io.WriteString(w, "partial")
+				return fmt.Errorf("network error")
+			})
+			if err == nil {
+				t.Fatal("write() should fail")
+			}
+			if after := snapshotDir(t, dir); after != before {
+				t.Errorf("existing output changed:\nbefore %s\nafter  %s", before, after)
+			}
+		})
+	}
+}
+
+// snapshotDir 返回目录中全部文件的名称与内容
+func snapshotDir(t *testing.T, dir string) string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&b, "%s=%q ", e.Name(), data)
+	}
+	return b.String()
+}
@@ -4,6 +4,9 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 // ProgressCallback 进度回调函数
@@ -64,58 +67,65 @@ func (cr *callbackReader) Close() error {
 	return nil
 }
 
-// TotalTrackingRoundTripper 带总量追踪的 RoundTripper
+// TotalTrackingRoundTripper 带总量追踪的 RoundTripper，按 digest 把每个 blob 的读取记录到 ProgressTracker
+// 续传的层由下层 Transport 先读出本地已有的部分，这部分同样计入进度
 type TotalTrackingRoundTripper struct {
-	rt         http.RoundTripper
-	callback   ProgressCallback
-	totalSize  int64
-	downloaded int64
+	rt      http.RoundTripper
+	tracker *ProgressTracker
 }
 
-// NewTotalTrackingRoundTripper 创建带总量追踪的 RoundTripper
-func NewTotalTrackingRoundTripper(rt http.RoundTripper, totalSize int64, cb ProgressCallback) *TotalTrackingRoundTripper {
-	return &TotalTrackingRoundTripper{
-		rt:        rt,
-		callback:  cb,
-		totalSize: totalSize,
-	}
+// Tracker 返回记录各 blob 进度的 ProgressTracker
+func (t *TotalTrackingRoundTripper) Tracker() *ProgressTracker {
+	return t.tracker
 }
 
 func (t *TotalTrackingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// 按 digest 识别 blob 请求，重定向到对象存储的下载同样计入进度
+	d, ok := blobDigest(req)
+	ok = ok && req.Method == http.MethodGet
 	resp, err := t.rt.RoundTrip(req)
 	if err != nil {
+		if ok {
+			t.tracker.finish(d, t.tracker.start(d, -1), false)
+		}
 		return nil, err
 	}
-	if t.callback != nil && strings.Contains(req.URL.Path, "/blobs/") {
+	if ok && resp.StatusCode == http.StatusOK {
 		resp.Body = &totalTrackingReader{
-			reader:   resp.Body,
-			closer:   resp.Body,
-			parent:   t,
+			body:    resp.Body,
+			tracker: t.tracker,
+			digest:  d,
+			attempt: t.tracker.start(d, resp.ContentLength),
 		}
 	}
 	return resp, nil
 }
 
+// totalTrackingReader 记录一次 blob 请求读到的字节；读到 EOF 为完成，出错或未读完就关闭为失败
 type totalTrackingReader struct {
-	reader io.Reader
-	closer io.Closer
-	parent *TotalTrackingRoundTripper
+	body    io.ReadCloser
+	tracker *ProgressTracker
+	digest  v1.Hash
+	attempt int
+	once    sync.Once
 }
 
 func (r *totalTrackingReader) Read(p []byte) (n int, err error) {
-	n, err = r.reader.Read(p)
+	n, err = r.body.Read(p)
 	if n > 0 {
-		r.parent.downloaded += int64(n)
-		if r.parent.callback != nil {
-			r.parent.callback(r.parent.downloaded, r.parent.totalSize)
-		}
+		r.tracker.add(r.digest, r.attempt, int64(n))
+	}
+	if err != nil {
+		r.end(err == io.EOF)
 	}
 	return
 }
 
+func (r *totalTrackingReader) end(ok bool) {
+	r.once.Do(func() { r.tracker.finish(r.digest, r.attempt, ok) })
+}
+
 func (r *totalTrackingReader) Close() error {
-	if r.closer != nil {
-		return r.closer.Close()
-	}
-	return nil
+	r.end(false)
+	return r.body.Close()
 }
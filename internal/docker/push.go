@@ -0,0 +1,265 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"dipt/internal/errors"
+	"dipt/internal/retry"
+	"dipt/internal/types"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// PushOptions 推送选项
+type PushOptions struct {
+	// Source 为 docker save 格式的 tar（可压缩或分卷）、oci-archive 文件或 OCI layout 目录，
+	// 也可以是包含这些文件的目录，此时逐个推送其中的全部镜像
+	Source string
+	// Prefix 重新打标签的目标前缀，如 harbor.example.com/mirror
+	// 镜像推送到 <Prefix>/<原仓库路径>:<原 tag>，为空时推送到原镜像引用
+	Prefix string
+	// Insecure 为 true 时使用 HTTP 访问目标仓库
+	Insecure bool
+
+	// Context 由调用方控制取消，为 nil 时不可取消；上传不设整体超时，大镜像可以长时间传输
+	Context context.Context
+
+	Config     types.Config
+	OnProgress ProgressCallback        // 单个镜像的上传进度
+	OnLog      func(level, msg string) // 日志回调
+}
+
+// logMsg 发送日志消息
+func (o *PushOptions) logMsg(level, format string, args ...interface{}) {
+	if o.OnLog != nil {
+		o.OnLog(level, fmt.Sprintf(format, args...))
+	}
+}
+
+// context 返回调用方的上下文，未设置时为 context.Background()
+func (o *PushOptions) context() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// PushedImage 推送成功的镜像
+type PushedImage struct {
+	File      string `json:"file"`      // 来源文件或目录
+	Reference string `json:"reference"` // 文件中记录的镜像引用
+	Target    string `json:"target"`    // 推送到的引用
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type,omitempty"`
+	Retries   int    `json:"retries"`
+}
+
+// PushFailure 推送失败的文件或镜像
+type PushFailure struct {
+	File      string `json:"file"`
+	Reference string `json:"reference,omitempty"`
+	Error     string `json:"error"`
+}
+
+// PushResult 一次推送的结果
+type PushResult struct {
+	Images          []PushedImage `json:"images"`
+	Failed          []PushFailure `json:"failed,omitempty"`
+	Duration        time.Duration `json:"-"`
+	DurationSeconds float64       `json:"duration_seconds"`
+}
+
+// PushArchive 将保存的镜像推送到仓库
+// 单个镜像失败不会中断其余镜像的推送，失败记录在结果的 Failed 中
+func PushArchive(opts PushOptions) (*PushResult, error) {
+	start := time.Now()
+	result := &PushResult{}
+	defer func() {
+		result.Duration = time.Since(start)
+		result.DurationSeconds = result.Duration.Round(time.Millisecond).Seconds()
+	}()
+
+	sources, err := ImageSources(opts.Source)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("%s 中没有可推送的镜像文件", opts.Source)
+	}
+
+	var nameOpts []name.Option
+	if opts.Insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	ctx := opts.context()
+	for _, src := range sources {
+		if ctx.Err() != nil {
+			return result, errors.NewCanceledError(ctx.Err())
+		}
+		items, cleanup, err := LoadImages(src)
+		if err != nil {
+			opts.logMsg("error", "读取 %s 失败: %v", src, err)
+			result.Failed = append(result.Failed, PushFailure{File: src, Error: err.Error()})
+			continue
+		}
+		for _, it := range items {
+			pushed, err := pushItemWithRetry(it, opts, nameOpts)
+			if errors.IsCanceledError(err) {
+				cleanup()
+				opts.logMsg("warning", "推送已取消")
+				return result, err
+			}
+			if err != nil {
+				opts.logMsg("error", "推送 %s 失败: %v", it.Ref, err)
+				result.Failed = append(result.Failed, PushFailure{File: src, Reference: it.Ref.String(), Error: err.Error()})
+				continue
+			}
+			pushed.File = src
+			opts.logMsg("success", "已推送 %s (%s)", pushed.Target, pushed.Digest)
+			result.Images = append(result.Images, *pushed)
+		}
+		cleanup()
+	}
+	return result, nil
+}
+
+// pushItemWithRetry 推送单个镜像，失败时按默认重试配置重试
+func pushItemWithRetry(it NamedImage, opts PushOptions, nameOpts []name.Option) (*PushedImage, error) {
+	target, err := RetagReference(it.Ref, opts.Prefix, nameOpts...)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := describe(it.Item)
+	if err != nil {
+		return nil, err
+	}
+	pushed := &PushedImage{Reference: it.Ref.String(), Target: target.String(), Digest: desc.Digest.String(), MediaType: string(desc.MediaType)}
+	opts.logMsg("info", "推送 %s -> %s", it.Ref, target)
+
+	ctx := opts.context()
+	attempts := 0
+	err = retry.WithRetryContext(ctx, func() error {
+		if attempts > 0 {
+			pushed.Retries++
+		}
+		attempts++
+		return pushOnce(ctx, target, it.Item, opts)
+	}, retry.DefaultConfig(), fmt.Sprintf("推送镜像 [%s]", target))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.NewCanceledError(err)
+		} else if errors.IsUnauthorizedError(err) {
+			return nil, errors.NewUnauthorizedError(target.Context().RegistryStr(), err)
+		} else if errors.IsNetworkError(err) {
+			return nil, errors.NewNetworkError(err)
+		}
+		return nil, err
+	}
+	return pushed, nil
+}
+
+// describe 返回镜像或镜像索引的类型与 digest
+func describe(item mutate.Appendable) (*v1.Descriptor, error) {
+	mt, err := item.MediaType()
+	if err != nil {
+		return nil, fmt.Errorf("读取镜像类型失败: %v", err)
+	}
+	digest, err := item.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("计算镜像 digest 失败: %v", err)
+	}
+	return &v1.Descriptor{MediaType: mt, Digest: digest}, nil
+}
+
+// pushOnce 推送一次镜像或镜像索引，上传进度通过 OnProgress 报告
+// 上传 blob 的时间取决于镜像大小，不套用 requestTimeout，ctx 被取消时中断
+func pushOnce(ctx context.Context, target name.Reference, item mutate.Appendable, opts PushOptions) error {
+	updates := make(chan v1.Update, 16)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for u := range updates {
+			if opts.OnProgress != nil && u.Total > 0 {
+				opts.OnProgress(u.Complete, u.Total)
+			}
+		}
+	}()
+
+	options := []remote.Option{remote.WithContext(ctx), pushAuth(opts.Config, target.Context().Registry), remote.WithProgress(updates)}
+	var err error
+	switch v := item.(type) {
+	case v1.ImageIndex:
+		err = remote.WriteIndex(target, v, options...)
+	case v1.Image:
+		err = remote.Write(target, v, options...)
+	default:
+		err = fmt.Errorf("不支持推送的类型: %T", item)
+		close(updates) // remote.Write 完成时会关闭 updates，此处未调用需自行关闭
+	}
+	<-done
+	return err
+}
+
+// pushAuth 返回推送到 reg 使用的认证：读取 docker login 保存的凭据，
+// 目标正是配置凭据所属的仓库且 docker login 中没有该仓库的凭据时使用配置的用户名和密码
+func pushAuth(cfg types.Config, reg name.Registry) remote.Option {
+	if auth, ok := configAuthFor(cfg, reg); ok {
+		if kc, err := authn.DefaultKeychain.Resolve(reg); err != nil || kc == authn.Anonymous {
+			return remote.WithAuth(auth)
+		}
+	}
+	return remote.WithAuthFromKeychain(authn.DefaultKeychain)
+}
+
+// configAuthFor 配置了用户名和密码且 reg 是凭据所属的仓库（server，为空时为 Docker Hub）时返回这组凭据
+func configAuthFor(cfg types.Config, reg name.Registry) (authn.Authenticator, bool) {
+	if cfg.Registry.Username == "" || cfg.Registry.Password == "" {
+		return nil, false
+	}
+	server := strings.TrimSpace(cfg.Registry.Server)
+	server = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://"), "/")
+	if server == "" {
+		server = name.DefaultRegistry
+	}
+	configured, err := name.NewRegistry(server)
+	if err != nil || configured.RegistryStr() != reg.RegistryStr() {
+		return nil, false
+	}
+	return authn.FromConfig(authn.AuthConfig{
+		Username: cfg.Registry.Username,
+		Password: cfg.Registry.Password,
+	}), true
+}
+
+// RetagReference 将镜像引用改写到新的仓库前缀下，保留原仓库路径与 tag（或 digest）
+// 如 prefix 为 harbor.example.com/mirror 时，docker.io/library/nginx:1.27
+// 改写为 harbor.example.com/mirror/library/nginx:1.27；prefix 为空时返回原引用
+func RetagReference(ref name.Reference, prefix string, opts ...name.Option) (name.Reference, error) {
+	if prefix == "" {
+		return name.ParseReference(ref.String(), opts...)
+	}
+	repo := strings.TrimSuffix(prefix, "/") + "/" + ref.Context().RepositoryStr()
+	if d, ok := ref.(name.Digest); ok {
+		return name.NewDigest(repo+"@"+d.DigestStr(), opts...)
+	}
+	t, err := name.NewTag(repo+":"+ref.Identifier(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("目标前缀 %s 无效: %v", prefix, err)
+	}
+	return t, nil
+}
+
+// ValidatePushPrefix 检查目标仓库前缀是否有效
+func ValidatePushPrefix(prefix string) error {
+	if _, err := name.NewRepository(strings.TrimSuffix(prefix, "/") + "/image"); err != nil {
+		return fmt.Errorf("目标前缀 %s 无效: %v", prefix, err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dipt/internal/errors"
+	"dipt/internal/types"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestPushArchiveRetag(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag("docker.io/library/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	out := outputSpec{path: filepath.Join(dir, "app.tar.gz"), format: FormatDocker, compression: CompressionGzip}
+	if err := writeImage(out, ref, img); err != nil {
+		t.Fatalf("writeImage() error = %v", err)
+	}
+
+	result, err := PushArchive(PushOptions{Source: dir, Prefix: u.Host + "/mirror"})
+	if err != nil {
+		t.Fatalf("PushArchive() error = %v", err)
+	}
+	if len(result.Failed) > 0 || len(result.Images) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	want := u.Host + "/mirror/library/app:1.0"
+	if result.Images[0].Target != want {
+		t.Errorf("target = %s, want %s", result.Images[0].Target, want)
+	}
+
+	target, err := name.ParseReference(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed, err := remote.Image(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := pushed.ConfigName()
+	wantConfig, _ := img.ConfigName()
+	if got != wantConfig {
+		t.Errorf("pushed config = %s, want %s", got, wantConfig)
+	}
+}
+
+// basicAuthRegistry 启动要求 Basic 认证的测试仓库，返回仓库地址与收到的全部用户名
+func basicAuthRegistry(t *testing.T, user, pass string) (string, func() []string) {
+	t.Helper()
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if ok {
+			mu.Lock()
+			seen = append(seen, u)
+			mu.Unlock()
+		}
+		if !ok || u != user || p != pass {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), seen...)
+	}
+}
+
+func TestPushAuthOnlyForConfiguredRegistry(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	host, seen := basicAuthRegistry(t, "admin", "secret")
+
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag("docker.io/library/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := writeImage(outputSpec{path: filepath.Join(dir, "app.tar"), format: FormatDocker}, ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// 凭据属于 Docker Hub，不能发送给其他仓库
+	cfg := types.Config{Registry: types.Registry{Username: "admin", Password: "secret"}}
+	result, err := PushArchive(PushOptions{Source: dir, Prefix: host + "/mirror", Config: cfg})
+	if err == nil && len(result.Failed) == 0 {
+		t.Fatal("push without credentials for the target should fail")
+	}
+	if got := seen(); len(got) > 0 {
+		t.Fatalf("configured credentials sent to %s: %v", host, got)
+	}
+
+	cfg.Registry.Server = "http://" + host + "/"
+	result, err = PushArchive(PushOptions{Source: dir, Prefix: host + "/mirror", Config: cfg})
+	if err != nil || len(result.Failed) > 0 {
+		t.Fatalf("PushArchive() = %+v, %v", result, err)
+	}
+}
+
+// TestPushTimeoutAndCancel 上传不受 DIPT_TIMEOUT 限制，调用方取消时停止推送
+func TestPushTimeoutAndCancel(t *testing.T) {
+	t.Setenv("DIPT_TIMEOUT", "1")
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 模拟较慢的 blob 上传，单次上传超过 DIPT_TIMEOUT
+		if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/blobs/uploads/") {
+			time.Sleep(1500 * time.Millisecond)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag("docker.io/library/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := writeImage(outputSpec{path: filepath.Join(dir, "app.tar"), format: FormatDocker}, ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := PushArchive(PushOptions{Source: dir, Prefix: u.Host + "/mirror"})
+	if err != nil || len(result.Failed) > 0 {
+		t.Fatalf("PushArchive() = %+v, %v", result, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := PushArchive(PushOptions{Source: dir, Prefix: u.Host + "/canceled", Context: ctx}); !errors.IsCanceledError(err) {
+		t.Errorf("PushArchive() with canceled context error = %v, want canceled", err)
+	}
+}
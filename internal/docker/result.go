@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"time"
+
+	"dipt/internal/types"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// LayerInfo 镜像层信息
+type LayerInfo struct {
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	MediaType string `json:"media_type"`
+}
+
+// PullResult 一次拉取的结果，记录实际保存的内容
+type PullResult struct {
+	Reference         string            `json:"reference"`                    // 用户请求的镜像引用
+	ResolvedReference string            `json:"resolved_reference,omitempty"` // 固定到 digest 的完整引用
+	Source            string            `json:"source,omitempty"`             // 实际拉取使用的引用（可能是镜像加速器地址）
+	Mirror            string            `json:"mirror,omitempty"`             // 实际使用的镜像加速器，直连时为空
+	ManifestDigest    string            `json:"manifest_digest,omitempty"`
+	IndexDigest       string            `json:"index_digest,omitempty"`       // 引用指向多平台索引时索引的 digest
+	SavedIndexDigest  string            `json:"saved_index_digest,omitempty"` // 只保存部分平台时输出中索引的 digest（仓库中不存在）
+	ConfigDigest      string            `json:"config_digest,omitempty"`
+	MediaType         string            `json:"media_type,omitempty"`
+	Platform          types.Platform    `json:"platform"`
+	Platforms         []types.Platform  `json:"platforms,omitempty"` // 多平台拉取时实际保存的平台
+	Labels            map[string]string `json:"labels,omitempty"`    // 镜像配置中的标签
+	Created           *time.Time        `json:"created,omitempty"`   // 镜像配置中的创建时间
+	Layers            []LayerInfo       `json:"layers,omitempty"`
+	TotalSize         int64             `json:"total_size"`
+	Retries           int               `json:"retries"`                 // 失败后重试的次数（含镜像加速器之间的切换）
+	CachedLayers      int               `json:"cached_layers,omitempty"` // 从本地缓存读取、未重新下载的层数
+	CachedBytes       int64             `json:"cached_bytes,omitempty"`
+	Duration          time.Duration     `json:"-"`
+	DurationSeconds   float64           `json:"duration_seconds"`
+	OutputFile        string            `json:"output"`
+	SHA256            string            `json:"sha256,omitempty"`         // 输出文件的 SHA-256（分卷时见校验和文件）
+	Checksums         string            `json:"checksums,omitempty"`      // sha256sum 格式的校验和文件
+	PartsManifest     string            `json:"parts_manifest,omitempty"` // 分卷写入时的校验清单
+	Parts             []string          `json:"parts,omitempty"`
+	DryRun            bool              `json:"dry_run,omitempty"`
+}
+
+// finish 记录拉取耗时
+func (r *PullResult) finish(start time.Time) {
+	r.Duration = time.Since(start)
+	r.DurationSeconds = r.Duration.Round(time.Millisecond).Seconds()
+}
+
+// recordManifest 从镜像清单中填充 digest 与层信息
+func (r *PullResult) recordManifest(digest v1.Hash, m *v1.Manifest, totalSize int64) {
+	r.ManifestDigest = digest.String()
+	r.ConfigDigest = m.Config.Digest.String()
+	r.MediaType = string(m.MediaType)
+	r.TotalSize = totalSize
+	r.Layers = make([]LayerInfo, 0, len(m.Layers))
+	for _, l := range m.Layers {
+		r.Layers = append(r.Layers, LayerInfo{
+			Digest:    l.Digest.String(),
+			Size:      l.Size,
+			MediaType: string(l.MediaType),
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// activeBlobs 正在下载的 blob（按 .partial 文件路径），同一个 blob 同时只有一个下载写入缓存
+var activeBlobs sync.Map
+
+// partialPath 未下载完的 blob 所在的文件
+func (c *BlobCache) partialPath(h v1.Hash) string {
+	return filepath.Join(c.tmpDir(), h.Hex+".partial")
+}
+
+// Transport 返回在 rt 之上填充缓存的 RoundTripper：
+// 层下载时同时写入 .partial 文件，读完并校验 digest 后移入缓存；
+// 下载中断后再次请求同一层时用 Range 请求续传，仓库或镜像加速器不支持 Range 时从头下载
+// c 为 nil 时原样返回 rt
+func (c *BlobCache) Transport(rt http.RoundTripper, onLog func(level, msg string)) http.RoundTripper {
+	if c == nil {
+		return rt
+	}
+	return &resumeTransport{rt: rt, cache: c, onLog: onLog}
+}
+
+type resumeTransport struct {
+	rt    http.RoundTripper
+	cache *BlobCache
+	onLog func(level, msg string)
+}
+
+func (t *resumeTransport) logf(level, format string, args ...interface{}) {
+	if t.onLog != nil {
+		t.onLog(level, fmt.Sprintf(format, args...))
+	}
+}
+
+// blobDigest 返回请求下载的 blob 的 digest
+// 仓库常把 blob 请求重定向到对象存储，此时沿重定向链找到最初的 /blobs/<digest> 请求
+func blobDigest(req *http.Request) (v1.Hash, bool) {
+	for r := req; r != nil; r = r.Response.Request {
+		if i := strings.LastIndex(r.URL.Path, "/blobs/"); i >= 0 {
+			h, err := v1.NewHash(r.URL.Path[i+len("/blobs/"):])
+			return h, err == nil && h.Algorithm == "sha256"
+		}
+		if r.Response == nil {
+			break
+		}
+	}
+	return v1.Hash{}, false
+}
+
+func (t *resumeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	d, ok := blobDigest(req)
+	if !ok || req.Method != http.MethodGet {
+		return t.rt.RoundTrip(req)
+	}
+	partial := t.cache.partialPath(d)
+	if _, busy := activeBlobs.LoadOrStore(partial, true); busy {
+		return t.rt.RoundTrip(req)
+	}
+	release := func() { activeBlobs.Delete(partial) }
+
+	var offset int64
+	if fi, err := os.Stat(partial); err == nil {
+		offset = fi.Size()
+	}
+	if offset > 0 {
+		req = req.Clone(req.Context())
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if ok && start == offset {
+			body, err := t.resume(resp, d, partial, offset, total, release)
+			if err != nil {
+				resp.Body.Close()
+				release()
+				return nil, err
+			}
+			t.logf("info", "续传 %s，已有 %s", d.Hex[:12], FormatBytes(offset))
+			return body, nil
+		}
+		fallthrough
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// 已有的数据与仓库中的 blob 对不上，删除后重新下载
+		resp.Body.Close()
+		os.Remove(partial)
+		release()
+		req.Header.Del("Range")
+		return t.RoundTrip(req)
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			t.logf("warning", "%s 不支持 Range 请求，%s 从头下载", req.URL.Host, d.Hex[:12])
+		}
+		body, err := t.fresh(resp, d, partial, release)
+		if err != nil {
+			// 无法写入缓存时仍然正常下载
+			release()
+			return resp, nil
+		}
+		return body, nil
+	default:
+		release()
+		return resp, nil
+	}
+}
+
+// fresh 从头下载，把响应内容写入新的 .partial 文件
+func (t *resumeTransport) fresh(resp *http.Response, d v1.Hash, partial string, release func()) (*http.Response, error) {
+	if err := os.MkdirAll(t.cache.blobDir(), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(t.cache.tmpDir(), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &partialBody{
+		cache: t.cache, digest: d, partial: partial, release: release,
+		body: resp.Body, f: f, hasher: sha256.New(), total: resp.ContentLength,
+	}
+	return resp, nil
+}
+
+// resume 续传：先读出 .partial 中已有的数据，再接上 206 响应的内容，对调用方表现为完整的 200 响应
+func (t *resumeTransport) resume(resp *http.Response, d v1.Hash, partial string, offset, total int64, release func()) (*http.Response, error) {
+	prefix, err := os.Open(partial)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(partial, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		prefix.Close()
+		return nil, err
+	}
+	if total < 0 && resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	resp.Body = &partialBody{
+		cache: t.cache, digest: d, partial: partial, release: release,
+		prefix: io.NewSectionReader(prefix, 0, offset), prefixFile: prefix,
+		body: resp.Body, f: f, hasher: sha256.New(), total: total,
+	}
+	resp.StatusCode = http.StatusOK
+	resp.Status = "200 OK"
+	resp.ContentLength = total
+	resp.Header.Del("Content-Range")
+	if total >= 0 {
+		resp.Header.Set("Content-Length", strconv.FormatInt(total, 10))
+	} else {
+		resp.Header.Del("Content-Length")
+	}
+	return resp, nil
+}
+
+// parseContentRange 解析 "bytes start-end/total"，total 未知（*）时为 -1
+func parseContentRange(s string) (start, total int64, ok bool) {
+	spec, found := strings.CutPrefix(s, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	from, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(from, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, total, true
+}
+
+// partialBody 依次读出 .partial 中已有的数据与网络上的剩余数据，网络数据同时追加到 .partial；
+// 读满 total 字节（或读到 EOF）且 digest 一致时移入缓存。调用方可能按已知大小读取而不读到 EOF，
+// 甚至不调用 Close（如 tarball.Write），因此读满时立即完成
+type partialBody struct {
+	cache   *BlobCache
+	digest  v1.Hash
+	partial string
+	release func()
+
+	prefix     io.Reader // .partial 中已有的数据，读完后为 nil
+	prefixFile *os.File
+	body       io.ReadCloser
+	f          *os.File
+	hasher     hash.Hash
+	n          int64
+	total      int64 // 完整大小，未知时为 -1
+	failed     bool
+	finished   bool
+}
+
+func (b *partialBody) Read(p []byte) (int, error) {
+	if b.prefix != nil {
+		n, err := b.prefix.Read(p)
+		b.hasher.Write(p[:n])
+		b.n += int64(n)
+		if err != io.EOF {
+			return n, err
+		}
+		b.prefix = nil
+		b.prefixFile.Close()
+		if n > 0 {
+			return n, nil
+		}
+	}
+
+	n, err := b.body.Read(p)
+	if n > 0 && !b.finished {
+		if !b.failed {
+			if _, werr := b.f.Write(p[:n]); werr != nil {
+				b.failed = true
+			}
+		}
+		b.hasher.Write(p[:n])
+		b.n += int64(n)
+		if b.total >= 0 && b.n >= b.total {
+			b.finish()
+		}
+	}
+	if err == io.EOF && !b.finished {
+		b.finish()
+	} else if err != nil && !b.finished {
+		// 连接中断：调用方不一定会 Close，这里就保留已下载的部分并结束
+		b.interrupt()
+	}
+	return n, err
+}
+
+// finish 数据读完：digest 一致时移入缓存，否则删除 .partial
+func (b *partialBody) finish() {
+	b.finished = true
+	b.f.Close()
+	defer b.release()
+	if !b.failed && hex.EncodeToString(b.hasher.Sum(nil)) == b.digest.Hex {
+		if os.Rename(b.partial, b.cache.path(b.digest)) == nil {
+			b.cache.verified.Store(b.digest, true)
+			return
+		}
+	}
+	os.Remove(b.partial)
+}
+
+// interrupt 未读完就结束：保留 .partial 供下次续传
+func (b *partialBody) interrupt() {
+	b.finished = true
+	b.f.Close()
+	if b.failed {
+		os.Remove(b.partial)
+	}
+	b.release()
+}
+
+func (b *partialBody) Close() error {
+	if b.prefixFile != nil {
+		b.prefixFile.Close()
+	}
+	if !b.finished {
+		b.interrupt()
+	}
+	return b.body.Close()
+}
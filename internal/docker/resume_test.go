@@ -0,0 +1,117 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dipt/internal/types"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestPullResumesPartialLayer(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		honourRange bool
+	}{
+		{"range", true},
+		{"mirror ignores range", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := random.Image(64<<10, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			layers, _ := img.Layers()
+			layerDigest, _ := layers[0].Digest()
+			rc, _ := layers[0].Compressed()
+			data, _ := io.ReadAll(rc)
+
+			reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+			var (
+				mu     sync.Mutex
+				ranges []string
+				cut    = true
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, "/blobs/"+layerDigest.String()) {
+					reg.ServeHTTP(w, r)
+					return
+				}
+				mu.Lock()
+				ranges = append(ranges, r.Header.Get("Range"))
+				first := cut
+				cut = false
+				mu.Unlock()
+				if first {
+					// 只发送一半数据后断开连接
+					w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+					w.Write(data[:len(data)/2])
+					panic(http.ErrAbortHandler)
+				}
+				if !tc.honourRange {
+					r.Header.Del("Range")
+				}
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+			}))
+			defer srv.Close()
+			u, _ := url.Parse(srv.URL)
+
+			ref, err := name.NewTag(u.Host + "/app:1.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := remote.Write(ref, img); err != nil {
+				t.Fatal(err)
+			}
+			mu.Lock()
+			ranges, cut = nil, true
+			mu.Unlock()
+
+			t.Setenv("DIPT_CACHE_DIR", t.TempDir())
+			opts := PullOptions{
+				ImageName:  ref.String(),
+				OutputFile: filepath.Join(t.TempDir(), "app.tar"),
+				Platform:   types.Platform{OS: "linux", Arch: "amd64"},
+			}
+			if _, err := PullAndSave(opts); err == nil {
+				t.Fatal("first pull should fail when the connection drops")
+			}
+			var progress int64
+			opts.OnProgress = func(downloaded, total int64) { progress = downloaded }
+			result, err := PullAndSave(opts)
+			if err != nil {
+				t.Fatalf("resumed pull error = %v", err)
+			}
+			if progress != result.TotalSize {
+				t.Errorf("progress ended at %d, want %d", progress, result.TotalSize)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(ranges) != 2 {
+				t.Fatalf("layer requested %d times, want 2", len(ranges))
+			}
+			if want := fmt.Sprintf("bytes=%d-", len(data)/2); ranges[1] != want {
+				t.Errorf("second request Range = %q, want %q", ranges[1], want)
+			}
+			if !DefaultBlobCache().has(layerDigest, int64(len(data))) {
+				t.Error("resumed layer was not added to the cache")
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package docker
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// OCI 层中的 whiteout 标记
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// writeRootfs 按顺序应用镜像的所有层，将合并后的根文件系统写成单个 tar 流
+// 从最上层往下处理：上层已出现的路径、被 whiteout 删除的路径、
+// 上层为非目录的路径下的内容以及上层 opaque 目录中的内容都会被跳过
+func writeRootfs(w io.Writer, img v1.Image) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("读取镜像层失败: %v", err)
+	}
+
+	tw := tar.NewWriter(w)
+	// seen 记录上层已处理的路径，值为 true 表示该路径下的内容对下层不可见（非目录或已删除）
+	seen := map[string]bool{}
+	opaque := map[string]bool{}
+	for i := len(layers) - 1; i >= 0; i-- {
+		if err := flattenLayer(tw, layers[i], seen, opaque); err != nil {
+			return fmt.Errorf("处理第 %d 层失败: %v", i+1, err)
+		}
+	}
+	return tw.Close()
+}
+
+// flattenLayer 将单个层中可见的条目写入 tw
+// 本层的 whiteout 与 opaque 标记只作用于下层，处理完本层后才合并到 seen 和 opaque
+func flattenLayer(tw *tar.Writer, layer v1.Layer, seen, opaque map[string]bool) error {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var whiteouts, opaques []string
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := cleanEntryName(hdr.Name)
+		if name == "" {
+			continue
+		}
+		dir, base := path.Split(name)
+		dir = strings.TrimSuffix(dir, "/")
+		if base == whiteoutOpaque {
+			opaques = append(opaques, dir)
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			whiteouts = append(whiteouts, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			continue
+		}
+		if _, ok := seen[name]; ok || hiddenByUpper(name, seen, opaque) {
+			continue
+		}
+		seen[name] = hdr.Typeflag != tar.TypeDir
+
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = cleanEntryName(hdr.Linkname)
+		}
+		hdr.Format = tar.FormatPAX
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Size > 0 {
+			if _, err := io.CopyN(tw, tr, hdr.Size); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range whiteouts {
+		if _, ok := seen[name]; !ok {
+			seen[name] = true
+		}
+	}
+	for _, dir := range opaques {
+		opaque[dir] = true
+	}
+	return nil
+}
+
+// hiddenByUpper 判断路径的某个上级目录是否已被上层删除、替换为非目录或标记为 opaque
+func hiddenByUpper(name string, seen, opaque map[string]bool) bool {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if seen[dir] || opaque[dir] {
+			return true
+		}
+	}
+	return opaque[""]
+}
+
+// cleanEntryName 规范化 tar 条目名称，去掉开头的 "/" 与 "./"，根目录返回空字符串
+func cleanEntryName(name string) string {
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
+
+// extractRootfs 将合并后的根文件系统解压到目录，目录必须不存在或为空
+// 解压失败时删除本次创建的目录
+func extractRootfs(img v1.Image, dir string) (skipped int, err error) {
+	created, err := prepareExtractDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil && created {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeRootfs(pw, img))
+	}()
+	skipped, err = extractTar(pr, dir)
+	// 提前返回时让写入端退出
+	pr.CloseWithError(err)
+	return skipped, err
+}
+
+// prepareExtractDir 创建解压目录，返回目录是否为本次新建
+func prepareExtractDir(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return false, fmt.Errorf("创建输出目录失败: %v", err)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("读取输出目录失败: %v", err)
+	}
+	if len(entries) > 0 {
+		return false, fmt.Errorf("输出目录 %s 不为空", dir)
+	}
+	return false, nil
+}
+
+// extractTar 将 tar 流安全地解压到 dir
+// 拒绝越出目标目录的路径、经过符号链接的路径以及指向目录外的硬链接；
+// 设备文件与 FIFO 需要特权，直接跳过并计数；不恢复文件属主
+func extractTar(r io.Reader, dir string) (skipped int, err error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	type dirMeta struct {
+		path string
+		hdr  *tar.Header
+	}
+	var dirs []dirMeta
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return skipped, fmt.Errorf("读取 tar 失败: %v", err)
+		}
+		name, err := localEntryPath(root, hdr.Name)
+		if err != nil {
+			return skipped, err
+		}
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return skipped, err
+		}
+		if fi, err := os.Lstat(target); err == nil && !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+			return skipped, fmt.Errorf("tar 中存在重复条目: %s", hdr.Name)
+		}
+
+		mode := hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return skipped, err
+			}
+			// 目录权限在最后设置，避免只读目录阻止写入其中的文件
+			dirs = append(dirs, dirMeta{target, hdr})
+			continue
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+			if err != nil {
+				return skipped, err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return skipped, fmt.Errorf("写入 %s 失败: %v", name, err)
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return skipped, err
+			}
+		case tar.TypeSymlink:
+			// 链接目标原样保留，解压时不会经过符号链接写入，因此无需限制
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return skipped, err
+			}
+			continue
+		case tar.TypeLink:
+			link, err := localEntryPath(root, hdr.Linkname)
+			if err != nil || link == "" {
+				return skipped, fmt.Errorf("硬链接 %s 指向目标目录之外: %s", hdr.Name, hdr.Linkname)
+			}
+			if err := os.Link(filepath.Join(root, link), target); err != nil {
+				return skipped, err
+			}
+			continue
+		default:
+			skipped++
+			continue
+		}
+		os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		mode := d.hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if err := os.Chmod(d.path, mode); err != nil {
+			return skipped, err
+		}
+		os.Chtimes(d.path, d.hdr.ModTime, d.hdr.ModTime)
+	}
+	return skipped, nil
+}
+
+// localEntryPath 将 tar 条目名称转换为 root 下的相对路径
+// 路径越出 root 或其上级目录中存在符号链接时返回错误
+func localEntryPath(root, entry string) (string, error) {
+	clean := path.Clean(strings.TrimLeft(entry, "/"))
+	if clean == "." {
+		return "", nil
+	}
+	name := filepath.FromSlash(clean)
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("拒绝解压越出目标目录的路径: %s", entry)
+	}
+	p := root
+	parts := strings.Split(filepath.Dir(name), string(filepath.Separator))
+	for _, part := range parts {
+		if part == "." {
+			break
+		}
+		p = filepath.Join(p, part)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("拒绝经过符号链接解压: %s", entry)
+		}
+		if !fi.IsDir() {
+			return "", fmt.Errorf("路径 %s 的上级不是目录", entry)
+		}
+	}
+	return name, nil
+}
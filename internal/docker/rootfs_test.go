@@ -0,0 +1,144 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// tarEntry 测试用的 tar 条目，typ 为空时为普通文件
+type tarEntry struct {
+	name, body, link string
+	typ              byte
+}
+
+func buildTar(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: e.typ, Linkname: e.link}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func layerOf(t *testing.T, entries []tarEntry) v1.Layer {
+	t.Helper()
+	data := buildTar(t, entries)
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestWriteRootfsWhiteouts(t *testing.T) {
+	base := layerOf(t, []tarEntry{
+		{name: "etc/", typ: tar.TypeDir},
+		{name: "etc/a", body: "a1"},
+		{name: "etc/b", body: "b1"},
+		{name: "opt/", typ: tar.TypeDir},
+		{name: "opt/x", body: "x"},
+		{name: "opt/sub/y", body: "y"},
+		{name: "./keep/z", body: "z"},
+		{name: "lib", typ: tar.TypeSymlink, link: "usr/lib"},
+	})
+	top := layerOf(t, []tarEntry{
+		{name: "etc/.wh.b"},
+		{name: "etc/a", body: "a2"},
+		{name: "opt/.wh..wh..opq"},
+		{name: "opt/new", body: "n"},
+		{name: "keep/.wh.missing"},
+	})
+	img, err := mutate.AppendLayers(empty.Image, base, top)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeRootfs(&buf, img); err != nil {
+		t.Fatalf("writeRootfs() error = %v", err)
+	}
+	got := map[string]string{}
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(tr)
+		got[hdr.Name] = string(body)
+	}
+	// opaque 目录本身保留，只隐藏下层中的内容
+	want := map[string]string{"etc": "", "etc/a": "a2", "opt": "", "opt/new": "n", "keep/z": "z", "lib": ""}
+	var names []string
+	for n := range got {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if len(got) != len(want) {
+		t.Fatalf("rootfs entries = %v, want %d entries", names, len(want))
+	}
+	for n, body := range want {
+		if g, ok := got[n]; !ok || g != body {
+			t.Errorf("entry %s = %q (present %v), want %q", n, g, ok, body)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "rootfs")
+	if _, err := extractRootfs(img, dir); err != nil {
+		t.Fatalf("extractRootfs() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "etc", "a")); err != nil || string(data) != "a2" {
+		t.Errorf("etc/a = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "opt", "x")); !os.IsNotExist(err) {
+		t.Error("opt/x should be hidden by opaque directory")
+	}
+	if link, err := os.Readlink(filepath.Join(dir, "lib")); err != nil || link != "usr/lib" {
+		t.Errorf("lib symlink = %q, %v", link, err)
+	}
+}
+
+func TestExtractTarRejectsEscapes(t *testing.T) {
+	tests := map[string][]tarEntry{
+		"parent":  {{name: "../evil", body: "x"}},
+		"symlink": {{name: "out", typ: tar.TypeSymlink, link: "/tmp"}, {name: "out/evil", body: "x"}},
+		"link":    {{name: "evil", typ: tar.TypeLink, link: "../../etc/passwd"}},
+	}
+	for name, entries := range tests {
+		dir := t.TempDir()
+		if _, err := extractTar(bytes.NewReader(buildTar(t, entries)), dir); err == nil {
+			t.Errorf("%s: extractTar() expected error", name)
+		}
+	}
+}
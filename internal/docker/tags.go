@@ -0,0 +1,239 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dipt/internal/errors"
+	"dipt/internal/retry"
+	"dipt/internal/types"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// tagsPageSize 每次向仓库请求的标签数，标签很多的仓库按 Link 头分页取完
+const tagsPageSize = 1000
+
+// ListTagsOptions 列出仓库标签的选项
+type ListTagsOptions struct {
+	Repository string // 仓库名，带 tag 或 digest 时忽略，如 nginx、ghcr.io/org/app:1.0
+
+	// Context 由调用方控制取消，为 nil 时不可取消
+	Context context.Context
+
+	Config types.Config
+	OnLog  func(level, msg string) // 日志回调
+}
+
+// logMsg 发送日志消息
+func (o *ListTagsOptions) logMsg(level, format string, args ...interface{}) {
+	if o.OnLog != nil {
+		o.OnLog(level, fmt.Sprintf(format, args...))
+	}
+}
+
+// TagList 仓库的标签列表
+type TagList struct {
+	Repository string   `json:"repository"`
+	Mirror     string   `json:"mirror,omitempty"` // 实际使用的镜像加速器，直连时为空
+	Tags       []string `json:"tags"`             // 按 SortTags 排序
+}
+
+// ListTags 通过仓库的 tags/list 接口列出全部标签并按版本排序
+// Docker Hub 仓库与拉取一样优先使用配置的镜像加速器（匿名访问），失败时回退到原始地址并使用配置的凭据
+func ListTags(opts ListTagsOptions) (*TagList, error) {
+	ref, err := name.ParseReference(strings.TrimSpace(opts.Repository))
+	if err != nil {
+		return nil, errors.NewImageNotFoundError(opts.Repository, err)
+	}
+	result := &TagList{Repository: ref.Context().String()}
+
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	list := func(from name.Reference, auth authn.Authenticator) error {
+		return retry.WithRetryContext(parent, func() error {
+			ctx, cancel := context.WithTimeout(parent, requestTimeout())
+			defer cancel()
+			tags, err := remote.List(from.Context(), remote.WithAuth(auth), remote.WithContext(ctx), remote.WithPageSize(tagsPageSize))
+			if err != nil {
+				return err
+			}
+			result.Tags = tags
+			return nil
+		}, retry.DefaultConfig(), fmt.Sprintf("获取标签列表 [%s]", from.Context()))
+	}
+
+	config := withCustomMirror(opts.Config, opts.OnLog)
+	if len(config.Registry.Mirrors) > 0 && IsDockerHubImage(ref) {
+		err = NewMirrorManager(config.Registry.Mirrors).TryPullWithMirrors(ref, nil, func(level, msg string) {
+			opts.logMsg(level, "%s", msg)
+		}, func(from name.Reference, mirrorURL string) error {
+			result.Mirror = mirrorURL
+			if mirrorURL == "" {
+				return list(from, sourceAuth(opts.Config))
+			}
+			// 镜像加速器使用匿名认证，不传原始仓库的凭据
+			if err := list(from, authn.Anonymous); err != nil {
+				if parent.Err() != nil {
+					return context.Canceled
+				}
+				return err
+			}
+			return nil
+		})
+	} else {
+		err = list(ref, sourceAuth(opts.Config))
+	}
+	if err != nil {
+		switch {
+		case parent.Err() != nil:
+			return nil, errors.NewCanceledError(err)
+		case errors.IsManifestUnknownError(err):
+			return nil, errors.NewImageNotFoundError(opts.Repository, err)
+		case errors.IsUnauthorizedError(err):
+			return nil, errors.NewUnauthorizedError(ref.Context().RegistryStr(), err)
+		case errors.IsNetworkError(err):
+			return nil, errors.NewNetworkError(err)
+		}
+		return nil, err
+	}
+
+	SortTags(result.Tags)
+	return result, nil
+}
+
+// tagVersion 从标签中解析出的版本号，如 v1.27.3-alpine 解析为 [1 27 3] 与后缀 -alpine
+type tagVersion struct {
+	nums   []int
+	suffix string
+}
+
+// parseTagVersion 解析以数字（可带前缀 v）开头、点号分隔的版本号，不是版本号时返回 false
+func parseTagVersion(tag string) (tagVersion, bool) {
+	s := strings.TrimPrefix(strings.TrimPrefix(tag, "v"), "V")
+	var v tagVersion
+	for {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i > 9 {
+			return tagVersion{}, false
+		}
+		n, _ := strconv.Atoi(s[:i])
+		v.nums = append(v.nums, n)
+		s = s[i:]
+		// 点号后需要跟数字，否则作为后缀（如 1.0.rc1）
+		if len(s) < 2 || s[0] != '.' || s[1] < '0' || s[1] > '9' {
+			break
+		}
+		s = s[1:]
+	}
+	v.suffix = s
+	return v, true
+}
+
+// compareTagVersions 比较两个版本号，a 较新时返回正数
+// 数字部分逐段比较；前缀相同时段数少的（如 1.27，通常指向该系列最新版本）排在前面；
+// 数字完全相同时无后缀的正式版排在带后缀的变体或预发布版前面，后缀之间按 comparePrerelease 比较
+func compareTagVersions(a, b tagVersion) int {
+	for i := 0; i < len(a.nums) && i < len(b.nums); i++ {
+		if a.nums[i] != b.nums[i] {
+			return a.nums[i] - b.nums[i]
+		}
+	}
+	if len(a.nums) != len(b.nums) {
+		return len(b.nums) - len(a.nums)
+	}
+	switch {
+	case a.suffix == b.suffix:
+		return 0
+	case a.suffix == "":
+		return 1
+	case b.suffix == "":
+		return -1
+	}
+	return comparePrerelease(a.suffix, b.suffix)
+}
+
+// comparePrerelease 按 semver 的规则比较预发布后缀，a 较高时返回正数
+// 后缀按 . 与 - 分成标识符，标识符中字母与数字相接处再分开（rc10 分为 rc 与 10），
+// 使 rc10 高于 rc2；数字按数值比较且低于字母，字母按字典序比较，前面都相同时标识符多的较高
+func comparePrerelease(a, b string) int {
+	ia, ib := prereleaseIdentifiers(a), prereleaseIdentifiers(b)
+	for i := 0; i < len(ia) && i < len(ib); i++ {
+		na, errA := strconv.Atoi(ia[i])
+		nb, errB := strconv.Atoi(ib[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				return na - nb
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		case ia[i] != ib[i]:
+			return strings.Compare(ia[i], ib[i])
+		}
+	}
+	return len(ia) - len(ib)
+}
+
+// prereleaseIdentifiers 把后缀分成标识符，如 -rc.10 与 -rc10 都得到 [rc 10]
+func prereleaseIdentifiers(suffix string) []string {
+	var ids []string
+	for _, part := range strings.FieldsFunc(suffix, func(r rune) bool { return r == '.' || r == '-' }) {
+		start := 0
+		for i := 1; i < len(part); i++ {
+			if isDigit(part[i]) != isDigit(part[i-1]) {
+				ids = append(ids, part[start:i])
+				start = i
+			}
+		}
+		ids = append(ids, part[start:])
+	}
+	return ids
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// SortTags 按版本排序标签：latest 在最前，其次是版本号标签（新版本在前），最后是其他标签按字母顺序
+func SortTags(tags []string) {
+	versions := make(map[string]tagVersion, len(tags))
+	for _, t := range tags {
+		if v, ok := parseTagVersion(t); ok {
+			versions[t] = v
+		}
+	}
+	rank := func(t string) int {
+		if t == "latest" {
+			return 0
+		}
+		if _, ok := versions[t]; ok {
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		a, b := tags[i], tags[j]
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		if va, ok := versions[a]; ok {
+			if c := compareTagVersions(va, versions[b]); c != 0 {
+				return c > 0
+			}
+		}
+		return a < b
+	})
+}
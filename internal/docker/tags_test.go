@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestSortTags(t *testing.T) {
+	tags := []string{"1.9", "edge", "1.27.0-alpine", "latest", "v1.10.0", "1.27.0", "1.27", "mainline", "1.27.0-rc1", "2"}
+	SortTags(tags)
+	want := []string{"latest", "2", "1.27", "1.27.0", "1.27.0-rc1", "1.27.0-alpine", "v1.10.0", "1.9", "edge", "mainline"}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("SortTags() = %v\nwant %v", tags, want)
+	}
+
+	// 预发布版本按 semver 比较，数字标识符按数值比较，较高的在前
+	tags = []string{"1.0.0-rc1", "1.0.0-beta.11", "1.0.0-rc10", "1.0.0", "1.0.0-rc2", "1.0.0-beta.2", "1.0.0-rc.3", "1.0.0-beta"}
+	SortTags(tags)
+	want = []string{"1.0.0", "1.0.0-rc10", "1.0.0-rc.3", "1.0.0-rc2", "1.0.0-rc1", "1.0.0-beta.11", "1.0.0-beta.2", "1.0.0-beta"}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("SortTags() = %v\nwant %v", tags, want)
+	}
+}
+
+func TestListTags(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"1.0", "1.10", "1.2", "latest"} {
+		ref, err := name.NewTag(u.Host + "/app:" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 带 tag 的引用只取其仓库
+	list, err := ListTags(ListTagsOptions{Repository: u.Host + "/app:1.0"})
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	if want := []string{"latest", "1.10", "1.2", "1.0"}; !reflect.DeepEqual(list.Tags, want) {
+		t.Errorf("tags = %v, want %v", list.Tags, want)
+	}
+	if list.Repository != u.Host+"/app" || list.Mirror != "" {
+		t.Errorf("repository = %s, mirror = %s", list.Repository, list.Mirror)
+	}
+}
@@ -0,0 +1,234 @@
+package docker
+
+import (
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// LayerState 层的下载状态
+type LayerState string
+
+const (
+	LayerQueued      LayerState = "queued"      // 等待下载
+	LayerDownloading LayerState = "downloading" // 下载中
+	LayerVerifying   LayerState = "verifying"   // 数据已收齐，等待校验 digest
+	LayerDone        LayerState = "done"        // 已下载并校验
+	LayerCached      LayerState = "cached"      // 本地缓存中已有，无需下载
+	LayerFailed      LayerState = "failed"      // 下载失败（重试时重新开始）
+)
+
+// LayerProgress 单个 blob（层或 config）的下载进度
+type LayerProgress struct {
+	Digest     string     `json:"digest"`
+	Config     bool       `json:"config,omitempty"`
+	State      LayerState `json:"state"`
+	Downloaded int64      `json:"downloaded"`
+	Total      int64      `json:"total"`
+}
+
+// LayerProgressCallback 接收所有 blob 的进度快照，按清单顺序排列
+type LayerProgressCallback func(layers []LayerProgress)
+
+// layerReportInterval 层进度快照的最短上报间隔，状态变化时立即上报
+const layerReportInterval = 100 * time.Millisecond
+
+// ProgressTracker 并发安全的拉取进度：按 digest 记录每个 blob 的状态与字节数，
+// 汇总的进度由各 blob 的进度相加得出，因此重试不会重复计数，也不会超过总量
+type ProgressTracker struct {
+	onProgress ProgressCallback
+	onLayers   LayerProgressCallback
+
+	mu         sync.Mutex
+	total      int64
+	blobs      []*trackedBlob
+	byDigest   map[v1.Hash]*trackedBlob
+	lastLayers time.Time
+
+	// emitMu 保证回调按计算顺序依次调用
+	emitMu sync.Mutex
+}
+
+type trackedBlob struct {
+	LayerProgress
+	attempt int // 每次重新请求加一，过期请求的读取不再计数
+}
+
+// NewProgressTracker 创建进度追踪器，两个回调都可以为 nil
+func NewProgressTracker(onProgress ProgressCallback, onLayers LayerProgressCallback) *ProgressTracker {
+	return &ProgressTracker{
+		onProgress: onProgress,
+		onLayers:   onLayers,
+		byDigest:   make(map[v1.Hash]*trackedBlob),
+	}
+}
+
+// Reset 开始新一次下载（包括重试或换用镜像加速器）：登记要下载的 blob 与总量，所有 blob 回到等待状态
+func (t *ProgressTracker) Reset(total int64, blobs []v1.Descriptor) {
+	t.mu.Lock()
+	t.total = total
+	t.blobs = t.blobs[:0]
+	clear(t.byDigest)
+	for _, d := range blobs {
+		if _, ok := t.byDigest[d.Digest]; ok || !d.MediaType.IsDistributable() {
+			continue
+		}
+		b := &trackedBlob{LayerProgress: LayerProgress{
+			Digest: d.Digest.String(),
+			Config: d.MediaType.IsConfig(),
+			State:  LayerQueued,
+			Total:  d.Size,
+		}}
+		t.blobs = append(t.blobs, b)
+		t.byDigest[d.Digest] = b
+	}
+	t.mu.Unlock()
+	t.emit(true)
+}
+
+// MarkCached 将本地缓存中已有的 blob 标记为无需下载
+func (t *ProgressTracker) MarkCached(d v1.Hash) {
+	t.update(d, -1, func(b *trackedBlob) bool {
+		b.State = LayerCached
+		b.Downloaded = b.Total
+		return true
+	})
+}
+
+// Complete 拉取成功结束：未明确结束的 blob（如按大小读取、未读到 EOF 的层）都记为完成
+func (t *ProgressTracker) Complete() {
+	t.mu.Lock()
+	for _, b := range t.blobs {
+		if b.State != LayerCached {
+			b.State = LayerDone
+			b.Downloaded = b.Total
+		}
+	}
+	t.mu.Unlock()
+	t.emit(true)
+}
+
+// Layers 返回所有 blob 当前进度的快照
+func (t *ProgressTracker) Layers() []LayerProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshot()
+}
+
+// Progress 返回汇总的已下载字节数与总量
+func (t *ProgressTracker) Progress() (downloaded, total int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.aggregate()
+}
+
+// start 开始（或重新开始）下载一个 blob，返回本次请求的编号；未登记的 blob 返回 0
+func (t *ProgressTracker) start(d v1.Hash, size int64) int {
+	attempt := 0
+	t.update(d, -1, func(b *trackedBlob) bool {
+		b.attempt++
+		attempt = b.attempt
+		b.State = LayerDownloading
+		b.Downloaded = 0
+		if b.Total <= 0 && size > 0 {
+			b.Total = size
+		}
+		return true
+	})
+	return attempt
+}
+
+// add 记录本次请求新读到的 n 个字节，数据收齐时进入校验状态
+func (t *ProgressTracker) add(d v1.Hash, attempt int, n int64) {
+	t.update(d, attempt, func(b *trackedBlob) bool {
+		b.Downloaded += n
+		if b.Total > 0 && b.Downloaded >= b.Total && b.State == LayerDownloading {
+			b.State = LayerVerifying
+			return true
+		}
+		return false
+	})
+}
+
+// finish 结束本次请求：ok 为 false 表示读取出错或未读完就关闭
+func (t *ProgressTracker) finish(d v1.Hash, attempt int, ok bool) {
+	t.update(d, attempt, func(b *trackedBlob) bool {
+		if b.State != LayerDownloading && b.State != LayerVerifying {
+			return false
+		}
+		if ok || (b.Total > 0 && b.Downloaded >= b.Total) {
+			b.State = LayerDone
+		} else {
+			b.State = LayerFailed
+		}
+		return true
+	})
+}
+
+// update 修改一个 blob 的进度，attempt 不为 -1 时只接受当前请求的修改；fn 返回状态是否变化
+func (t *ProgressTracker) update(d v1.Hash, attempt int, fn func(b *trackedBlob) bool) {
+	t.mu.Lock()
+	b, ok := t.byDigest[d]
+	if !ok || (attempt != -1 && attempt != b.attempt) {
+		t.mu.Unlock()
+		return
+	}
+	changed := fn(b)
+	t.mu.Unlock()
+	t.emit(changed)
+}
+
+// emit 调用回调；层快照只在状态变化或距上次上报足够久时发送
+func (t *ProgressTracker) emit(changed bool) {
+	if t.onProgress == nil && t.onLayers == nil {
+		return
+	}
+	t.emitMu.Lock()
+	defer t.emitMu.Unlock()
+
+	t.mu.Lock()
+	downloaded, total := t.aggregate()
+	var layers []LayerProgress
+	if t.onLayers != nil && (changed || time.Since(t.lastLayers) >= layerReportInterval) {
+		layers = t.snapshot()
+		t.lastLayers = time.Now()
+	}
+	t.mu.Unlock()
+
+	if t.onProgress != nil {
+		t.onProgress(downloaded, total)
+	}
+	if layers != nil {
+		t.onLayers(layers)
+	}
+}
+
+// aggregate 汇总进度，每个 blob 最多计入其大小，总和不超过总量；调用方需持有 mu
+func (t *ProgressTracker) aggregate() (downloaded, total int64) {
+	var sum int64
+	for _, b := range t.blobs {
+		n := b.Downloaded
+		if b.Total > 0 && n > b.Total {
+			n = b.Total
+		}
+		downloaded += n
+		sum += b.Total
+	}
+	total = t.total
+	if total <= 0 {
+		total = sum
+	}
+	if downloaded > total {
+		downloaded = total
+	}
+	return downloaded, total
+}
+
+func (t *ProgressTracker) snapshot() []LayerProgress {
+	out := make([]LayerProgress, len(t.blobs))
+	for i, b := range t.blobs {
+		out[i] = b.LayerProgress
+	}
+	return out
+}
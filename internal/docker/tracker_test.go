@@ -0,0 +1,94 @@
+package docker
+
+import (
+	"sync"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestProgressTracker(t *testing.T) {
+	blobs := make([]v1.Descriptor, 3)
+	for i := range blobs {
+		blobs[i] = v1.Descriptor{
+			MediaType: types.DockerLayer,
+			Size:      1000,
+			Digest:    v1.Hash{Algorithm: "sha256", Hex: sha256Hex(i)},
+		}
+	}
+	blobs[0].MediaType = types.DockerConfigJSON
+
+	var (
+		mu      sync.Mutex
+		maxSeen int64
+		last    []LayerProgress
+	)
+	tracker := NewProgressTracker(func(downloaded, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if downloaded > total {
+			t.Errorf("progress %d exceeds total %d", downloaded, total)
+		}
+		maxSeen = max(maxSeen, downloaded)
+	}, func(layers []LayerProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		last = layers
+	})
+	tracker.Reset(3000, blobs)
+	tracker.MarkCached(blobs[0].Digest)
+
+	// 第一次下载读到一半失败，重试后从头计数；过期请求的读取不计入
+	d := blobs[1].Digest
+	first := tracker.start(d, 1000)
+	tracker.add(d, first, 600)
+	tracker.finish(d, first, false)
+	if got := tracker.Layers()[1].State; got != LayerFailed {
+		t.Errorf("state after error = %s, want %s", got, LayerFailed)
+	}
+	retry := tracker.start(d, 1000)
+	tracker.add(d, first, 600)
+
+	// 两个层并发读取
+	var wg sync.WaitGroup
+	for _, b := range []struct {
+		digest  v1.Hash
+		attempt int
+	}{{d, retry}, {blobs[2].Digest, tracker.start(blobs[2].Digest, 1000)}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				tracker.add(b.digest, b.attempt, 10)
+			}
+			// 读满后再次读取（如重复的数据）不应超过层大小
+			tracker.add(b.digest, b.attempt, 10)
+		}()
+	}
+	wg.Wait()
+
+	if got := tracker.Layers()[1].State; got != LayerVerifying {
+		t.Errorf("state after reading all bytes = %s, want %s", got, LayerVerifying)
+	}
+	tracker.finish(d, retry, true)
+	if downloaded, total := tracker.Progress(); downloaded != 3000 || total != 3000 {
+		t.Errorf("progress = %d/%d, want 3000/3000", downloaded, total)
+	}
+	tracker.Complete()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxSeen != 3000 {
+		t.Errorf("max reported progress = %d, want 3000", maxSeen)
+	}
+	want := []LayerState{LayerCached, LayerDone, LayerDone}
+	for i, l := range last {
+		if l.State != want[i] || l.Downloaded != l.Total {
+			t.Errorf("layer %d = %s %d/%d, want %s", i, l.State, l.Downloaded, l.Total, want[i])
+		}
+	}
+	if !last[0].Config {
+		t.Error("config blob not marked as config")
+	}
+}
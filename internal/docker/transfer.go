@@ -0,0 +1,336 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"dipt/internal/types"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// DefaultConcurrency 未配置时每次拉取同时下载的层数，与 Docker 的 max-concurrent-downloads 默认值相同
+const DefaultConcurrency = 3
+
+// concurrency 返回每次拉取同时下载的层数
+func (o *PullOptions) concurrency() int {
+	if n := o.Config.Transfer.Concurrency; n > 0 {
+		return n
+	}
+	return DefaultConcurrency
+}
+
+// transport 返回拉取使用的 RoundTripper：最底层限制并发与带宽，
+// 其上是层缓存（续传时本地已有的部分不占用带宽），最外层统计进度
+// 要下载的 blob 在解析出清单后通过 tracker.Reset 登记
+func (o *PullOptions) transport(cache *BlobCache) *TotalTrackingRoundTripper {
+	limited := newLimitTransport(http.DefaultTransport, o.concurrency(), sharedBandwidth(o.Config.Transfer))
+	return &TotalTrackingRoundTripper{
+		rt:      cache.Transport(limited, o.OnLog),
+		tracker: NewProgressTracker(o.OnProgress, o.OnLayers),
+	}
+}
+
+// pullCache 返回拉取使用的层缓存，cleanup 在拉取结束后调用
+// 设置 DIPT_NO_CACHE=1 且并发数大于 1 时，并行下载的层需要暂存到磁盘才能按顺序写入输出，
+// 此时使用本次拉取专用的临时目录，拉取结束后删除；无法创建临时目录时按顺序下载
+func (o *PullOptions) pullCache() (*BlobCache, func()) {
+	if cache := DefaultBlobCache(); cache != nil || o.concurrency() < 2 {
+		return cache, func() {}
+	}
+	dir, err := os.MkdirTemp("", "dipt-pull-")
+	if err != nil {
+		o.logMsg("warning", "无法创建暂存并行下载层的临时目录，改为按顺序下载: %v", err)
+		return nil, func() {}
+	}
+	cache := NewBlobCache(dir)
+	cache.temporary = true
+	return cache, func() { os.RemoveAll(dir) }
+}
+
+// prefetchLayers 按配置的并发数把缓存中缺失的层并行下载到缓存中，
+// 之后按顺序写入输出（如 docker tar）时直接读取缓存；没有缓存或并发数为 1 时不预取
+func (o *PullOptions) prefetchLayers(cache *BlobCache, layers []v1.Layer) error {
+	n := o.concurrency()
+	if cache == nil || n < 2 {
+		return nil
+	}
+	seen := make(map[v1.Hash]bool)
+	var missing []v1.Layer
+	for _, l := range layers {
+		mt, err := l.MediaType()
+		if err != nil {
+			return err
+		}
+		if !mt.IsDistributable() {
+			continue
+		}
+		d, err := l.Digest()
+		if err != nil {
+			return err
+		}
+		size, err := l.Size()
+		if err != nil {
+			return err
+		}
+		if seen[d] || cache.has(d, size) {
+			continue
+		}
+		seen[d] = true
+		missing = append(missing, l)
+	}
+	// 只缺一层时由写入输出时顺带下载即可
+	if len(missing) < 2 {
+		return nil
+	}
+	if n > len(missing) {
+		n = len(missing)
+	}
+	o.logMsg("info", "并行下载 %d 层（同时 %d 层）", len(missing), n)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, n)
+	for _, l := range missing {
+		sem <- struct{}{}
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(l v1.Layer) {
+			defer func() { <-sem; wg.Done() }()
+			if err := fetchLayer(l); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(l)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// fetchLayer 读完层的压缩数据，由缓存 Transport 写入缓存
+func fetchLayer(l v1.Layer) error {
+	rc, err := l.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+// indexLayers 返回镜像索引（包括嵌套的索引）中所有镜像的层
+func indexLayers(idx v1.ImageIndex) ([]v1.Layer, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	var layers []v1.Layer
+	for _, desc := range im.Manifests {
+		switch {
+		case desc.MediaType.IsImage():
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			ls, err := img.Layers()
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, ls...)
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			ls, err := indexLayers(child)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, ls...)
+		}
+	}
+	return layers, nil
+}
+
+// limitTransport 限制同时进行的 blob 下载数，并让所有响应数据经过带宽令牌桶
+type limitTransport struct {
+	rt     http.RoundTripper
+	slots  chan struct{}
+	bucket *tokenBucket // 不限速时为 nil
+}
+
+func newLimitTransport(rt http.RoundTripper, concurrency int, bucket *tokenBucket) *limitTransport {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &limitTransport{rt: rt, slots: make(chan struct{}, concurrency), bucket: bucket}
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, blob := blobDigest(req)
+	blob = blob && req.Method == http.MethodGet
+	if blob {
+		select {
+		case t.slots <- struct{}{}:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		if blob {
+			<-t.slots
+		}
+		return nil, err
+	}
+	if !blob && t.bucket == nil {
+		return resp, nil
+	}
+	body := &limitedBody{body: resp.Body, ctx: req.Context(), bucket: t.bucket, size: resp.ContentLength}
+	if blob {
+		body.release = func() { <-t.slots }
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// limitedBody 读取时按令牌桶限速；读完、出错或关闭时释放下载名额。
+// 调用方可能按已知大小读取而不读到 EOF，因此读满 Content-Length 时同样释放
+type limitedBody struct {
+	body    io.ReadCloser
+	ctx     context.Context
+	bucket  *tokenBucket
+	size    int64 // 未知时为 -1
+	n       int64
+	release func()
+	once    sync.Once
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.bucket != nil && len(p) > b.bucket.chunk() {
+		p = p[:b.bucket.chunk()]
+	}
+	n, err := b.body.Read(p)
+	b.n += int64(n)
+	if n > 0 && b.bucket != nil {
+		if werr := b.bucket.wait(b.ctx, n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if err != nil || (b.size >= 0 && b.n >= b.size) {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *limitedBody) done() {
+	if b.release != nil {
+		b.once.Do(b.release)
+	}
+}
+
+func (b *limitedBody) Close() error {
+	b.done()
+	return b.body.Close()
+}
+
+var (
+	bandwidthMu sync.Mutex
+	bandwidth   *tokenBucket
+)
+
+// sharedBandwidth 返回进程内共享的令牌桶，同时进行的多个拉取（如批量拉取）共用同一个带宽上限；
+// 不限速时返回 nil
+func sharedBandwidth(cfg types.Transfer) *tokenBucket {
+	if cfg.BandwidthLimit <= 0 {
+		return nil
+	}
+	burst := cfg.BandwidthBurst
+	if burst <= 0 {
+		burst = defaultBurst(cfg.BandwidthLimit)
+	}
+	bandwidthMu.Lock()
+	defer bandwidthMu.Unlock()
+	if bandwidth == nil || bandwidth.rate != cfg.BandwidthLimit || bandwidth.burst != burst {
+		bandwidth = newTokenBucket(cfg.BandwidthLimit, burst)
+	}
+	return bandwidth
+}
+
+// defaultBurst 未配置突发量时允许 1/10 秒的流量，至少 32 KiB
+func defaultBurst(rate int64) int64 {
+	if burst := rate / 10; burst > 32<<10 {
+		return burst
+	}
+	return 32 << 10
+}
+
+// tokenBucket 令牌桶：每秒补充 rate 个令牌（字节），最多积攒 burst 个
+type tokenBucket struct {
+	rate  int64
+	burst int64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst int64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// chunk 单次读取的最大字节数，避免一次读取后长时间等待
+func (b *tokenBucket) chunk() int {
+	if b.burst < 32<<10 {
+		return int(max(b.burst, 1))
+	}
+	return 32 << 10
+}
+
+// take 取走 n 个令牌，返回需要等待的时间；令牌不足时记为欠账，之后的读取需等待欠账还清
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = math.Min(float64(b.burst), b.tokens+now.Sub(b.last).Seconds()*float64(b.rate))
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(b.rate) * float64(time.Second))
+}
+
+// wait 取走 n 个令牌，令牌不足时等待，ctx 结束时提前返回
+func (b *tokenBucket) wait(ctx context.Context, n int) error {
+	d := b.take(n)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待带宽时中断: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,150 @@
+package docker
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dipt/internal/types"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestLimitTransport(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 64<<10)
+	var inflight, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	t.Run("concurrency", func(t *testing.T) {
+		client := &http.Client{Transport: newLimitTransport(http.DefaultTransport, 2, nil)}
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Get(srv.URL + "/v2/app/blobs/sha256:" + sha256Hex(i))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+		if peak > 2 {
+			t.Errorf("%d blob downloads ran at once, want at most 2", peak)
+		}
+	})
+
+	t.Run("bandwidth", func(t *testing.T) {
+		// 256 KiB/s、突发 32 KiB 时读完 64 KiB 至少需要 (64-32)/256 秒
+		client := &http.Client{Transport: newLimitTransport(http.DefaultTransport, 1, newTokenBucket(256<<10, 32<<10))}
+		start := time.Now()
+		resp, err := client.Get(srv.URL + "/v2/app/blobs/sha256:" + sha256Hex(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if n != int64(len(data)) {
+			t.Fatalf("read %d bytes, want %d", n, len(data))
+		}
+		if elapsed := time.Since(start); elapsed < 120*time.Millisecond {
+			t.Errorf("download took %v, bandwidth limit not applied", elapsed)
+		}
+	})
+}
+
+// sha256Hex 返回测试用的 64 位十六进制 digest
+func sha256Hex(i int) string {
+	return string(bytes.Repeat([]byte{"0123456789abcdef"[i%16]}, 64))
+}
+
+func TestPrefetchWithoutCache(t *testing.T) {
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/blobs/") && r.Method == http.MethodGet {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(4096, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(u.Host + "/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	peak.Store(0)
+
+	// 未启用缓存时层暂存在本次拉取的临时目录中，拉取结束后删除
+	t.Setenv("DIPT_NO_CACHE", "1")
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	out := filepath.Join(t.TempDir(), "app.tar")
+	result, err := PullAndSave(PullOptions{
+		ImageName:  ref.String(),
+		OutputFile: out,
+		Platform:   types.Platform{OS: "linux", Arch: "amd64"},
+		Config:     types.Config{Transfer: types.Transfer{Concurrency: 3}},
+	})
+	if err != nil {
+		t.Fatalf("PullAndSave() error = %v", err)
+	}
+	if p := peak.Load(); p < 2 {
+		t.Errorf("peak concurrent layer downloads = %d, want at least 2", p)
+	}
+	if result.CachedLayers != 0 {
+		t.Errorf("cached layers = %d, want 0 without a cache", result.CachedLayers)
+	}
+	if entries, err := os.ReadDir(tmp); err != nil || len(entries) > 0 {
+		t.Errorf("temporary layer directory left behind: %v (%v)", entries, err)
+	}
+	if report, err := VerifyArchive(out, nil); err != nil || !report.OK() {
+		t.Errorf("VerifyArchive() = %+v, %v, want a valid archive", report, err)
+	}
+}
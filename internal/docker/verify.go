@@ -0,0 +1,477 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"dipt/internal/split"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 压缩数据的魔数
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// BlobProblem 校验失败的 config 或层
+type BlobProblem struct {
+	Image  string `json:"image"`            // 所属镜像
+	Blob   string `json:"blob"`             // config 或 "层 2/5"
+	Digest string `json:"digest,omitempty"` // 期望的 digest
+	Path   string `json:"path"`             // tar 中的文件
+	Reason string `json:"reason"`
+}
+
+func (p BlobProblem) String() string {
+	s := fmt.Sprintf("[%s] %s", p.Image, p.Blob)
+	if p.Digest != "" {
+		s += " (" + p.Digest + ")"
+	}
+	return s + " 已损坏: " + p.Reason
+}
+
+// VerifiedImage 校验通过的镜像
+type VerifiedImage struct {
+	Reference string `json:"reference"`
+	Layers    int    `json:"layers"`
+	Size      int64  `json:"size"` // 各层压缩后的大小之和
+}
+
+// VerifyReport 一次校验的结果
+type VerifyReport struct {
+	File      string             `json:"file"`
+	Checksums string             `json:"checksums,omitempty"` // 使用的校验和文件，不存在时为空
+	Parts     int                `json:"parts,omitempty"`     // 分卷数
+	Mismatch  []ChecksumMismatch `json:"checksum_mismatch,omitempty"`
+	Images    []VerifiedImage    `json:"images"`
+	Problems  []BlobProblem      `json:"problems,omitempty"`
+}
+
+// OK 判断校验是否全部通过
+func (r *VerifyReport) OK() bool {
+	return len(r.Mismatch) == 0 && len(r.Problems) == 0
+}
+
+// VerifyArchive 校验保存的 docker save 格式 tar（可压缩、可分卷）
+// 先按 <文件>.sha256 重新计算文件校验和，再用 tarball.Image 打开每个镜像，
+// 重新计算 config 与每一层的 digest，与 tar 中的清单、镜像配置中的 diff_id
+// 以及元数据文件中记录的仓库 manifest 比对，指出具体损坏的层
+// 返回的 error 表示无法完成校验（如文件不存在或不是 docker save 格式）
+func VerifyArchive(file string, onLog func(level, msg string)) (*VerifyReport, error) {
+	logf := func(level, format string, args ...interface{}) {
+		if onLog != nil {
+			onLog(level, fmt.Sprintf(format, args...))
+		}
+	}
+	file = strings.TrimSuffix(file, checksumSuffix)
+	file = strings.TrimSuffix(strings.TrimSuffix(file, ".parts.json"), metadataSuffix)
+	report := &VerifyReport{File: file}
+
+	opener, parts, _, err := archiveOpener(file)
+	if err != nil {
+		return nil, err
+	}
+	if parts > 0 {
+		report.Parts = parts
+		logf("info", "按分卷清单读取 %d 个分卷", parts)
+	}
+
+	if _, err := os.Stat(ChecksumPath(file)); err == nil {
+		report.Checksums = ChecksumPath(file)
+		report.Mismatch, err = verifyChecksumFile(report.Checksums)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range report.Mismatch {
+			logf("error", "%s 与校验和不符: %s", m.File, m.Reason)
+		}
+		if len(report.Mismatch) == 0 {
+			logf("info", "文件校验和一致")
+		}
+	} else if report.Parts > 0 {
+		// 没有校验和文件时按分卷清单中记录的校验和检查
+		if _, err := split.Verify(split.ManifestPath(file), nil); err != nil {
+			report.Mismatch = append(report.Mismatch, ChecksumMismatch{File: filepath.Base(split.ManifestPath(file)), Reason: err.Error()})
+			logf("error", "%v", err)
+		}
+	} else {
+		logf("warning", "未找到校验和文件 %s，跳过文件校验和检查", ChecksumPath(file))
+	}
+
+	manifest, err := tarball.LoadManifest(opener)
+	if err != nil {
+		if len(report.Mismatch) > 0 {
+			// 文件已损坏到无法读取清单，校验和不符已经说明了问题
+			report.Problems = append(report.Problems, BlobProblem{Image: "-", Blob: "manifest.json", Path: "manifest.json", Reason: err.Error()})
+			return report, nil
+		}
+		return nil, fmt.Errorf("无法读取 manifest.json（文件可能被截断，或不是 docker 格式的 tar）: %v", err)
+	}
+	md, _ := ReadMetadata(file)
+
+	// 一次顺序读取整个 tar，计算所有层的 digest
+	sums, readErr := hashArchiveLayers(opener, manifest)
+	if readErr != nil {
+		logf("error", "读取 tar 失败，文件可能被截断: %v", readErr)
+	}
+
+	for _, desc := range manifest {
+		ref := "<未命名>"
+		var tag *name.Tag
+		if len(desc.RepoTags) > 0 {
+			ref = desc.RepoTags[0]
+			if t, err := name.NewTag(ref); err == nil {
+				tag = &t
+			}
+		}
+		if len(manifest) == 1 {
+			tag = nil
+		}
+		logf("info", "校验 %s", ref)
+		problems, verified := verifyImage(opener, desc, tag, ref, sums, expectedFromMetadata(md, desc.RepoTags), readErr)
+		for _, p := range problems {
+			logf("error", "%s", p)
+		}
+		report.Problems = append(report.Problems, problems...)
+		if len(problems) == 0 {
+			report.Images = append(report.Images, verified)
+			logf("success", "%s 的 config 与 %d 个层全部校验通过", ref, verified.Layers)
+		}
+	}
+	return report, nil
+}
+
+// archiveOpener 返回读取（必要时合并分卷并解压）整个 tar 的 Opener
+// file 不存在但有分卷清单时按清单串联分卷读取，parts 为分卷数；
+// 未压缩的单个文件直接打开，raw 为 true，返回的 *os.File 可 Seek，tar.Reader 跳过条目时无需读出内容
+func archiveOpener(file string) (opener tarball.Opener, parts int, raw bool, err error) {
+	var open func() (io.ReadCloser, error)
+	if _, err := os.Stat(file); err == nil {
+		if compressed, err := isCompressedFile(file); err != nil {
+			return nil, 0, false, err
+		} else if !compressed {
+			return func() (io.ReadCloser, error) { return os.Open(file) }, 0, true, nil
+		}
+		open = func() (io.ReadCloser, error) { return os.Open(file) }
+	} else if m, merr := split.ReadManifest(split.ManifestPath(file)); merr == nil {
+		parts = len(m.Parts)
+		dir := filepath.Dir(file)
+		open = func() (io.ReadCloser, error) { return openParts(dir, m) }
+	} else {
+		return nil, 0, false, fmt.Errorf("文件不存在: %s", file)
+	}
+
+	return func() (io.ReadCloser, error) {
+		rc, err := open()
+		if err != nil {
+			return nil, err
+		}
+		r, err := decompress(rc)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		return r, nil
+	}, parts, false, nil
+}
+
+// isCompressedFile 按魔数判断文件是否经过 gzip 或 zstd 压缩
+func isCompressedFile(file string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	n, _ := io.ReadFull(f, magic)
+	magic = magic[:n]
+	return bytes.HasPrefix(magic, gzipMagic) || bytes.HasPrefix(magic, zstdMagic), nil
+}
+
+// openParts 按顺序串联所有分卷，缺失的分卷在读到时报错
+func openParts(dir string, m *split.Manifest) (io.ReadCloser, error) {
+	readers := make([]io.Reader, len(m.Parts))
+	var files []*os.File
+	for i, p := range m.Parts {
+		f, err := os.Open(filepath.Join(dir, p.Name))
+		if err != nil {
+			readers[i] = errReader{fmt.Errorf("分卷 %s 缺失", p.Name)}
+			continue
+		}
+		files = append(files, f)
+		readers[i] = f
+	}
+	return &multiReadCloser{Reader: io.MultiReader(readers...), files: files}, nil
+}
+
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) { return 0, e.err }
+
+type multiReadCloser struct {
+	io.Reader
+	files []*os.File
+}
+
+func (m *multiReadCloser) Close() error {
+	for _, f := range m.files {
+		f.Close()
+	}
+	return nil
+}
+
+// decompress 按魔数识别 gzip/zstd 并返回解压后的读取流，未压缩时原样返回
+func decompress(rc io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(rc)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{gr, func() error { gr.Close(); return rc.Close() }}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{zr, func() error { zr.Close(); return rc.Close() }}, nil
+	default:
+		return readCloser{br, rc.Close}, nil
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error { return r.close() }
+
+// layerSum tar 中一个层文件的计算结果
+type layerSum struct {
+	digest v1.Hash // 文件内容的 digest
+	diffID v1.Hash // 解压后内容的 digest（未压缩的层与 digest 相同）
+	size   int64
+	err    error
+}
+
+// hashArchiveLayers 顺序读取 tar，计算清单中引用的每个层文件的 digest 与 diff_id
+// 读取中途出错时返回已完成的结果以及该错误，出错的层记录在其 err 中
+func hashArchiveLayers(opener tarball.Opener, manifest tarball.Manifest) (map[string]*layerSum, error) {
+	wanted := make(map[string]bool)
+	for _, desc := range manifest {
+		for _, l := range desc.Layers {
+			wanted[path.Clean(l)] = true
+		}
+	}
+
+	rc, err := opener()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	sums := make(map[string]*layerSum)
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return sums, nil
+		}
+		if err != nil {
+			return sums, err
+		}
+		p := path.Clean(hdr.Name)
+		if !wanted[p] || sums[p] != nil {
+			continue
+		}
+		sum := hashLayer(tr)
+		sums[p] = sum
+		if sum.err != nil && sum.size < hdr.Size {
+			// 层数据不完整，tar 后续内容已无法读取
+			return sums, sum.err
+		}
+	}
+}
+
+// hashLayer 计算层文件内容的 digest，若为 gzip/zstd 压缩则同时计算解压后的 diff_id
+func hashLayer(r io.Reader) *layerSum {
+	raw := sha256.New()
+	counter := &countingWriter{w: raw}
+	br := bufio.NewReader(io.TeeReader(r, counter))
+	sum := &layerSum{}
+
+	diff := sha256.New()
+	magic, _ := br.Peek(4)
+	var err error
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		var gr *gzip.Reader
+		if gr, err = gzip.NewReader(br); err == nil {
+			_, err = io.Copy(diff, gr)
+		}
+	case bytes.HasPrefix(magic, zstdMagic):
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(br); err == nil {
+			_, err = io.Copy(diff, zr)
+			zr.Close()
+		}
+	default:
+		diff = nil
+	}
+	// 读完剩余数据（压缩流之后的填充，或未压缩层的全部内容）
+	if _, cerr := io.Copy(io.Discard, br); err == nil {
+		err = cerr
+	}
+	sum.size = counter.n
+	sum.err = err
+	sum.digest = v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(raw.Sum(nil))}
+	sum.diffID = sum.digest
+	if diff != nil {
+		sum.diffID = v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(diff.Sum(nil))}
+	}
+	return sum
+}
+
+// expectedDigests 元数据文件中记录的仓库 manifest 信息
+type expectedDigests struct {
+	config string
+	layers []LayerInfo
+}
+
+// expectedFromMetadata 从元数据中找出与这些 tag 对应的镜像记录
+func expectedFromMetadata(md *Metadata, tags []string) *expectedDigests {
+	if md == nil {
+		return nil
+	}
+	candidates := append([]*Metadata{md}, md.Images...)
+	for _, c := range candidates {
+		if c.ConfigDigest == "" {
+			continue
+		}
+		for _, t := range tags {
+			if sameReference(c.Reference, t) {
+				return &expectedDigests{config: c.ConfigDigest, layers: c.Layers}
+			}
+		}
+		if len(tags) == 0 && len(candidates) == 1 {
+			return &expectedDigests{config: c.ConfigDigest, layers: c.Layers}
+		}
+	}
+	return nil
+}
+
+// sameReference 判断两个镜像引用是否相同（忽略默认仓库与 latest 的写法差异）
+func sameReference(a, b string) bool {
+	ra, err1 := name.ParseReference(a)
+	rb, err2 := name.ParseReference(b)
+	if err1 != nil || err2 != nil {
+		return a == b
+	}
+	return ra.Name() == rb.Name()
+}
+
+// verifyImage 校验单个镜像的 config 与各层
+func verifyImage(opener tarball.Opener, desc tarball.Descriptor, tag *name.Tag, ref string,
+	sums map[string]*layerSum, expected *expectedDigests, readErr error) ([]BlobProblem, VerifiedImage) {
+	verified := VerifiedImage{Reference: ref, Layers: len(desc.Layers)}
+	var problems []BlobProblem
+
+	img, err := tarball.Image(opener, tag)
+	if err != nil {
+		return []BlobProblem{{Image: ref, Blob: "config", Path: desc.Config, Reason: err.Error()}}, verified
+	}
+	raw, err := img.RawConfigFile()
+	if err != nil {
+		return []BlobProblem{{Image: ref, Blob: "config", Path: desc.Config, Reason: err.Error()}}, verified
+	}
+	cfgDigest, _, _ := v1.SHA256(bytes.NewReader(raw))
+	for _, want := range []string{DigestFromPath(desc.Config), expectedConfig(expected)} {
+		if want != "" && want != cfgDigest.String() {
+			problems = append(problems, BlobProblem{Image: ref, Blob: "config", Digest: want, Path: desc.Config,
+				Reason: fmt.Sprintf("实际 digest 为 %s", cfgDigest)})
+			break
+		}
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return append(problems, BlobProblem{Image: ref, Blob: "config", Path: desc.Config, Reason: err.Error()}), verified
+	}
+	if len(cf.RootFS.DiffIDs) != len(desc.Layers) {
+		return append(problems, BlobProblem{Image: ref, Blob: "config", Path: desc.Config,
+			Reason: fmt.Sprintf("镜像配置中有 %d 个 diff_id，tar 中有 %d 个层", len(cf.RootFS.DiffIDs), len(desc.Layers))}), verified
+	}
+
+	total := len(desc.Layers)
+	for i, p := range desc.Layers {
+		blob := fmt.Sprintf("第 %d/%d 层", i+1, total)
+		want := DigestFromPath(p)
+		if expected != nil && len(expected.layers) == total {
+			want = expected.layers[i].Digest
+		}
+		problem := BlobProblem{Image: ref, Blob: blob, Digest: want, Path: p}
+		if want == "" {
+			problem.Digest = cf.RootFS.DiffIDs[i].String()
+		}
+
+		sum := sums[path.Clean(p)]
+		switch {
+		case sum == nil && readErr != nil:
+			problem.Reason = "tar 在该层之前已截断，层数据缺失"
+		case sum == nil:
+			problem.Reason = "tar 中缺少该层文件"
+		case sum.err != nil:
+			problem.Reason = fmt.Sprintf("读取失败（%d 字节后）: %v", sum.size, sum.err)
+		case want != "" && sum.digest.String() != want:
+			problem.Reason = fmt.Sprintf("实际 digest 为 %s", sum.digest)
+		case sum.diffID != cf.RootFS.DiffIDs[i]:
+			problem.Reason = fmt.Sprintf("解压后的 digest 为 %s，与镜像配置中的 diff_id %s 不符", sum.diffID, cf.RootFS.DiffIDs[i])
+		default:
+			verified.Size += sum.size
+			continue
+		}
+		problems = append(problems, problem)
+	}
+	return problems, verified
+}
+
+func expectedConfig(e *expectedDigests) string {
+	if e == nil {
+		return ""
+	}
+	return e.config
+}
+
+// DigestFromPath 从 tar 中的文件名推出 digest
+// 支持 sha256:<hex>、<hex>.tar.gz、<hex>.json 与 blobs/sha256/<hex> 等写法，无法识别时返回空字符串
+func DigestFromPath(p string) string {
+	base := path.Base(p)
+	base = strings.TrimPrefix(base, "sha256:")
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if len(base) != sha256.Size*2 {
+		return ""
+	}
+	if _, err := hex.DecodeString(base); err != nil {
+		return ""
+	}
+	return "sha256:" + base
+}
@@ -0,0 +1,120 @@
+package docker
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestVerifyArchiveFindsCorruptLayer(t *testing.T) {
+	img, err := random.Image(4096, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag("example.com/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := outputSpec{path: filepath.Join(t.TempDir(), "app.tar"), format: FormatDocker}
+	if err := writeImage(out, ref, img); err != nil {
+		t.Fatalf("writeImage() error = %v", err)
+	}
+
+	report, err := VerifyArchive(out.path, nil)
+	if err != nil {
+		t.Fatalf("VerifyArchive() error = %v", err)
+	}
+	if !report.OK() || len(report.Images) != 1 || report.Checksums == "" {
+		t.Fatalf("intact archive report = %+v", report)
+	}
+
+	// 修改第 2 层中间的一个字节
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := layers[1].Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	off := bytes.Index(data, blob)
+	if off < 0 {
+		t.Fatal("layer 2 not found in archive")
+	}
+	data[off+len(blob)/2] ^= 0xff
+	if err := os.WriteFile(out.path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = VerifyArchive(out.path, nil)
+	if err != nil {
+		t.Fatalf("VerifyArchive() error = %v", err)
+	}
+	if len(report.Mismatch) != 1 {
+		t.Errorf("checksum mismatches = %+v, want 1", report.Mismatch)
+	}
+	if len(report.Problems) != 1 || report.Problems[0].Blob != "第 2/3 层" {
+		t.Fatalf("problems = %+v, want only layer 2", report.Problems)
+	}
+	want, _ := layers[1].Digest()
+	if report.Problems[0].Digest != want.String() {
+		t.Errorf("problem digest = %s, want %s", report.Problems[0].Digest, want)
+	}
+}
+
+// TestArchiveOpener 未压缩的 tar 直接打开（可 Seek），压缩的 tar 解压后读取
+func TestArchiveOpener(t *testing.T) {
+	img, err := random.Image(512, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag("example.com/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for path, wantRaw := range map[string]bool{"app.tar": true, "app.tar.zst": false} {
+		out := outputSpec{path: filepath.Join(dir, path), format: FormatDocker, compression: CompressionFromPath(path)}
+		if err := writeImage(out, ref, img); err != nil {
+			t.Fatal(err)
+		}
+		opener, _, raw, err := archiveOpener(out.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if raw != wantRaw {
+			t.Errorf("%s: raw = %v, want %v", path, raw, wantRaw)
+		}
+		rc, err := opener()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := rc.(io.Seeker); ok != wantRaw {
+			t.Errorf("%s: opener returned %T", path, rc)
+		}
+		rc.Close()
+
+		items, cleanup, err := LoadImages(out.path)
+		if err != nil {
+			t.Fatalf("%s: LoadImages() error = %v", path, err)
+		}
+		if len(items) != 1 {
+			t.Errorf("%s: got %d images", path, len(items))
+		}
+		cleanup()
+	}
+}
@@ -1,6 +1,7 @@
 package errors
 
 import (
+    "context"
     "errors"
     "fmt"
     "strings"
@@ -17,6 +18,8 @@ const (
 	ErrorImageNotFound
 	ErrorUnauthorized
 	ErrorNetwork
+	ErrorUsage
+	ErrorCanceled
 )
 
 // DiptError 自定义错误类型
@@ -83,6 +86,38 @@ func NewNetworkError(err error) *DiptError {
 	}
 }
 
+// NewUsageError 创建命令用法错误
+func NewUsageError(format string, args ...interface{}) *DiptError {
+	return &DiptError{
+		Type:    ErrorUsage,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// NewCanceledError 创建操作被取消错误（如用户中断拉取）
+func NewCanceledError(err error) *DiptError {
+	return &DiptError{
+		Type:    ErrorCanceled,
+		Message: "操作已取消",
+		Err:     err,
+	}
+}
+
+// IsCanceledError 检查是否是操作被取消错误
+func IsCanceledError(err error) bool {
+	var de *DiptError
+	if errors.As(err, &de) && de.Type == ErrorCanceled {
+		return true
+	}
+	return errors.Is(err, context.Canceled)
+}
+
+// IsUsageError 检查是否是命令用法错误
+func IsUsageError(err error) bool {
+	var de *DiptError
+	return errors.As(err, &de) && de.Type == ErrorUsage
+}
+
 // IsManifestUnknownError 检查是否是清单未找到错误
 func IsManifestUnknownError(err error) bool {
     if err == nil {
@@ -0,0 +1,510 @@
+// Package history 记录每次拉取的结果（镜像、平台、digest、输出、耗时等）与日志，
+// 供 TUI 中的历史记录页面查看、搜索与重新拉取，以及拉取表单的镜像名补全
+package history
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"dipt/internal/docker"
+	"dipt/internal/errors"
+	"dipt/internal/types"
+)
+
+// MaxEntries 最多保留的历史记录条数，超出时删除最早的记录及其日志
+const MaxEntries = 1000
+
+// Status 拉取结果
+type Status string
+
+const (
+	StatusSuccess  Status = "success"
+	StatusFailed   Status = "failed"
+	StatusCanceled Status = "canceled"
+)
+
+// Label 返回结果的中文描述
+func (s Status) Label() string {
+	switch s {
+	case StatusSuccess:
+		return "成功"
+	case StatusCanceled:
+		return "已取消"
+	default:
+		return "失败"
+	}
+}
+
+// Entry 一条拉取记录
+type Entry struct {
+	ID        string         `json:"id"`
+	Time      time.Time      `json:"time"`
+	Reference string         `json:"reference"`
+	Platform  types.Platform `json:"platform"`
+	// 多平台拉取的平台选择
+	AllPlatforms    bool                `json:"all_platforms,omitempty"`
+	Platforms       []types.Platform    `json:"platforms,omitempty"`
+	Format          docker.OutputFormat `json:"format,omitempty"`
+	Digest          string              `json:"digest,omitempty"` // 单平台为清单 digest，多平台为索引 digest
+	Output          string              `json:"output,omitempty"`
+	Bundle          string              `json:"bundle,omitempty"` // 合并打包时的打包文件，此时 Output 为空
+	Status          Status              `json:"status"`
+	Error           string              `json:"error,omitempty"`
+	DurationSeconds float64             `json:"duration_seconds"`
+	Mirror          string              `json:"mirror,omitempty"` // 实际使用的镜像加速器，直连时为空
+}
+
+// Multi 是否为多平台拉取
+func (e Entry) Multi() bool {
+	return e.AllPlatforms || len(e.Platforms) > 0
+}
+
+// PlatformLabel 返回平台描述
+func (e Entry) PlatformLabel() string {
+	if e.Multi() {
+		return docker.PlatformsLabel(e.AllPlatforms, e.Platforms)
+	}
+	if e.Platform.OS == "" {
+		return "-"
+	}
+	return e.Platform.OS + "/" + e.Platform.Arch
+}
+
+// Duration 返回拉取耗时
+func (e Entry) Duration() time.Duration {
+	return time.Duration(e.DurationSeconds * float64(time.Second))
+}
+
+// Matches 判断记录是否匹配搜索词：按空白分隔的每个词都需出现在镜像、平台、结果、输出、digest 或镜像加速器中（不区分大小写）
+func (e Entry) Matches(query string) bool {
+	fields := strings.ToLower(strings.Join([]string{
+		e.Reference, e.PlatformLabel(), string(e.Status), e.Status.Label(),
+		e.Output, e.Bundle, e.Digest, e.Mirror, string(e.Format),
+	}, "\n"))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(fields, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// LogLine 拉取日志中的一行，文件中保存为 "15:04:05 [info] 消息"
+type LogLine struct {
+	Time    string
+	Level   string
+	Message string
+}
+
+func (l LogLine) String() string {
+	return fmt.Sprintf("%s [%s] %s", l.Time, l.Level, l.Message)
+}
+
+// parseLogLine 解析日志文件中的一行，格式不符时整行作为消息返回
+func parseLogLine(text string) (LogLine, bool) {
+	t, rest, ok := strings.Cut(text, " [")
+	if !ok || len(t) != len("15:04:05") {
+		return LogLine{Message: text}, false
+	}
+	level, msg, ok := strings.Cut(rest, "] ")
+	if !ok {
+		return LogLine{Message: text}, false
+	}
+	return LogLine{Time: t, Level: level, Message: msg}, true
+}
+
+// Store 历史记录存储：dir 下的 history.jsonl 每行一条记录，logs/<id>.log 为对应的日志
+type Store struct {
+	dir string
+}
+
+const (
+	// lockTimeout 等待其他写入者释放锁的最长时间
+	lockTimeout = 10 * time.Second
+	// staleLock 锁文件超过该时间未释放时视为持有者已异常退出
+	staleLock = 30 * time.Second
+)
+
+// NewStore 创建位于 dir 的历史记录存储
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultStore 返回默认存储：位置可通过 DIPT_HISTORY_DIR 指定，默认为用户配置目录下的 dipt/history；
+// 设置 DIPT_NO_HISTORY=1 时不记录历史，返回 nil
+func DefaultStore() *Store {
+	if os.Getenv("DIPT_NO_HISTORY") == "1" {
+		return nil
+	}
+	return NewStore(DefaultDir())
+}
+
+// DefaultDir 返回默认历史记录目录
+func DefaultDir() string {
+	if dir := os.Getenv("DIPT_HISTORY_DIR"); dir != "" {
+		return dir
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		if base, err = os.UserHomeDir(); err != nil {
+			base = os.TempDir()
+		}
+	}
+	return filepath.Join(base, "dipt", "history")
+}
+
+// Dir 返回存储目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) file() string {
+	return filepath.Join(s.dir, "history.jsonl")
+}
+
+func (s *Store) logPath(id string) string {
+	return filepath.Join(s.dir, "logs", id+".log")
+}
+
+// Append 追加一条记录及其日志，ID 与时间为空时自动生成；记录超过 MaxEntries 条时删除最早的记录
+func (s *Store) Append(e Entry, log []LogLine) error {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.dir, "logs"), 0755); err != nil {
+		return fmt.Errorf("创建历史记录目录失败: %v", err)
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if len(log) > 0 {
+		var b strings.Builder
+		for _, line := range log {
+			b.WriteString(line.String() + "\n")
+		}
+		if err := os.WriteFile(s.logPath(e.ID), []byte(b.String()), 0644); err != nil {
+			return fmt.Errorf("写入拉取日志失败: %v", err)
+		}
+	}
+	f, err := os.OpenFile(s.file(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("写入历史记录失败: %v", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("写入历史记录失败: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("写入历史记录失败: %v", err)
+	}
+	return s.prune()
+}
+
+// lock 获取存储目录的写锁，返回释放函数
+// 写锁用 O_EXCL 创建锁文件实现，同时拉取的 CLI 与 TUI（以及 TUI 中同时结束的多个任务）
+// 依次追加与整理记录，避免整理时重写文件丢失其他进程刚追加的记录；
+// 锁文件超过 staleLock 未释放时视为持有者已异常退出，由 removeStale 删除后重新获取
+func (s *Store) lock() (func(), error) {
+	path := filepath.Join(s.dir, "history.lock")
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			mine, err := f.Stat()
+			f.Close()
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("锁定历史记录失败: %v", err)
+			}
+			// 持有过久被判定为过期时锁可能已属于其他进程，只删除自己创建的锁文件
+			return func() {
+				if info, err := os.Stat(path); err == nil && os.SameFile(info, mine) {
+					os.Remove(path)
+				}
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("锁定历史记录失败: %v", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			removeStale(path, info)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("锁定历史记录超时，如没有其他 dipt 在运行可删除 %s", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// removeStale 删除判定为过期的锁文件 stale
+// 先改名为本进程独有的文件名：同时发现过期的多个等待者只有一个能改名成功；
+// 改名后确认拿到的正是 stale，若锁在此期间已被释放并由其他进程重新获取，则放回原处
+func removeStale(path string, stale os.FileInfo) {
+	moved := fmt.Sprintf("%s.%d.%d", path, os.Getpid(), time.Now().UnixNano())
+	if os.Rename(path, moved) != nil {
+		return
+	}
+	if info, err := os.Stat(moved); err == nil && !os.SameFile(info, stale) {
+		// 原处已有新的锁文件时 Link 失败，不会覆盖
+		os.Link(moved, path)
+	}
+	os.Remove(moved)
+}
+
+// prune 只保留最近的 MaxEntries 条记录，调用方需持有写锁
+func (s *Store) prune() error {
+	entries, err := s.read()
+	if err != nil || len(entries) <= MaxEntries {
+		return err
+	}
+	drop, keep := entries[:len(entries)-MaxEntries], entries[len(entries)-MaxEntries:]
+
+	tmp := s.file() + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("整理历史记录失败: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range keep {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("整理历史记录失败: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("整理历史记录失败: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("整理历史记录失败: %v", err)
+	}
+	if err := os.Rename(tmp, s.file()); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("整理历史记录失败: %v", err)
+	}
+	for _, e := range drop {
+		os.Remove(s.logPath(e.ID))
+	}
+	return nil
+}
+
+// read 按写入顺序读取全部记录，跳过无法解析的行
+func (s *Store) read() ([]Entry, error) {
+	f, err := os.Open(s.file())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取历史记录失败: %v", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil && e.ID != "" {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取历史记录失败: %v", err)
+	}
+	return entries, nil
+}
+
+// Load 读取全部记录，最近的在前；s 为 nil（未启用历史记录）时返回空
+func (s *Store) Load() ([]Entry, error) {
+	if s == nil {
+		return nil, nil
+	}
+	entries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// ReadLog 读取记录的日志，没有日志时返回空
+func (s *Store) ReadLog(id string) ([]LogLine, error) {
+	if s == nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(s.logPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取拉取日志失败: %v", err)
+	}
+	var lines []LogLine
+	for _, text := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		line, ok := parseLogLine(text)
+		if !ok && len(lines) > 0 {
+			// 多行消息的后续行
+			lines[len(lines)-1].Message += "\n" + text
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// Filter 返回匹配搜索词的记录，搜索词为空时返回全部
+func Filter(entries []Entry, query string) []Entry {
+	if strings.TrimSpace(query) == "" {
+		return entries
+	}
+	var out []Entry
+	for _, e := range entries {
+		if e.Matches(query) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// References 返回记录中出现过的镜像引用（去重，最近的在前），用于输入补全
+func References(entries []Entry) []string {
+	seen := make(map[string]bool)
+	var refs []string
+	for _, e := range entries {
+		if e.Reference != "" && !seen[e.Reference] {
+			seen[e.Reference] = true
+			refs = append(refs, e.Reference)
+		}
+	}
+	return refs
+}
+
+// Recorder 记录一次拉取：收集日志与使用的镜像加速器，拉取结束后写入历史
+type Recorder struct {
+	store *Store
+	start time.Time
+	entry Entry
+
+	mu     sync.Mutex
+	log    []LogLine
+	mirror string
+}
+
+// Track 开始记录一次拉取，包装 opts 的 OnLog 与 OnMirror 回调；store 为 nil 时返回 nil（nil 的 Recorder 可安全使用）
+func Track(store *Store, opts *docker.PullOptions) *Recorder {
+	if store == nil {
+		return nil
+	}
+	r := &Recorder{
+		store: store,
+		start: time.Now(),
+		entry: Entry{
+			Reference:    opts.ImageName,
+			Platform:     opts.Platform,
+			AllPlatforms: opts.AllPlatforms,
+			Platforms:    opts.Platforms,
+			Format:       opts.Format,
+			Output:       opts.OutputFile,
+		},
+	}
+	if r.entry.Multi() {
+		r.entry.Platform = types.Platform{}
+	}
+
+	onLog := opts.OnLog
+	opts.OnLog = func(level, msg string) {
+		r.mu.Lock()
+		r.log = append(r.log, LogLine{Time: time.Now().Format("15:04:05"), Level: level, Message: msg})
+		r.mu.Unlock()
+		if onLog != nil {
+			onLog(level, msg)
+		}
+	}
+	onMirror := opts.OnMirror
+	opts.OnMirror = func(mirror string) {
+		r.mu.Lock()
+		r.mirror = mirror
+		r.mu.Unlock()
+		if onMirror != nil {
+			onMirror(mirror)
+		}
+	}
+	return r
+}
+
+// SetBundle 将记录标记为合并打包的一部分：输出记为打包文件，重新拉取时按默认格式保存
+func (r *Recorder) SetBundle(file string) {
+	if r == nil {
+		return
+	}
+	r.entry.Bundle = file
+	r.entry.Output = ""
+	r.entry.Format = ""
+}
+
+// Finish 写入本次拉取的记录，演练模式不记录
+func (r *Recorder) Finish(result *docker.PullResult, err error) error {
+	if r == nil || (result != nil && result.DryRun) {
+		return nil
+	}
+	r.mu.Lock()
+	e := r.entry
+	e.Mirror = r.mirror
+	log := r.log
+	r.mu.Unlock()
+
+	e.Time = r.start
+	e.DurationSeconds = time.Since(r.start).Round(time.Millisecond).Seconds()
+	switch {
+	case err == nil:
+		e.Status = StatusSuccess
+	case errors.IsCanceledError(err):
+		e.Status = StatusCanceled
+	default:
+		e.Status = StatusFailed
+		e.Error = err.Error()
+	}
+	if result != nil {
+		e.Mirror = result.Mirror
+		e.Digest = result.ManifestDigest
+		if (e.Multi() && result.IndexDigest != "") || e.Digest == "" {
+			e.Digest = result.IndexDigest
+		}
+		if result.OutputFile != "" && e.Bundle == "" {
+			e.Output = result.OutputFile
+		}
+	}
+	return r.store.Append(e, log)
+}
+
+// newID 生成记录 ID：时间戳加随机后缀，按字典序即为时间顺序
+func newID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
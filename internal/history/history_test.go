@@ -0,0 +1,200 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dipt/internal/docker"
+	"dipt/internal/errors"
+	"dipt/internal/types"
+)
+
+func TestRecorder(t *testing.T) {
+	store := NewStore(t.TempDir())
+	opts := docker.PullOptions{
+		ImageName:  "nginx:1.27",
+		OutputFile: "/tmp/nginx.tar",
+		Platform:   types.Platform{OS: "linux", Arch: "arm64"},
+		Format:     docker.FormatDocker,
+	}
+	var forwarded []string
+	opts.OnLog = func(level, msg string) { forwarded = append(forwarded, msg) }
+
+	rec := Track(store, &opts)
+	opts.OnLog("info", "开始拉取")
+	opts.OnMirror("mirror.example.com")
+	opts.OnLog("error", "第一行\n第二行")
+	if err := rec.Finish(nil, fmt.Errorf("拉取失败")); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = Track(store, &opts)
+	if err := rec.Finish(&docker.PullResult{ManifestDigest: "sha256:aaa", OutputFile: "/tmp/nginx.tar"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	rec = Track(store, &opts)
+	if err := rec.Finish(nil, errors.NewCanceledError(nil)); err != nil {
+		t.Fatal(err)
+	}
+	// 演练模式不记录
+	if err := Track(store, &opts).Finish(&docker.PullResult{DryRun: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(forwarded) != 2 {
+		t.Errorf("forwarded logs = %v", forwarded)
+	}
+	entries, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	if entries[0].Status != StatusCanceled || entries[1].Status != StatusSuccess || entries[1].Digest != "sha256:aaa" {
+		t.Errorf("entries = %+v", entries[:2])
+	}
+	failed := entries[2]
+	if failed.Status != StatusFailed || failed.Error != "拉取失败" || failed.Mirror != "mirror.example.com" ||
+		failed.PlatformLabel() != "linux/arm64" || failed.Output != "/tmp/nginx.tar" {
+		t.Errorf("failed entry = %+v", failed)
+	}
+
+	lines, err := store.ReadLog(failed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0].Level != "info" || lines[1].Message != "第一行\n第二行" {
+		t.Errorf("log = %+v", lines)
+	}
+	if lines, _ := store.ReadLog(entries[0].ID); len(lines) != 0 {
+		t.Errorf("log of entry without output = %+v", lines)
+	}
+}
+
+func TestFilterAndReferences(t *testing.T) {
+	entries := []Entry{
+		{ID: "3", Reference: "nginx:1.27", Platform: types.Platform{OS: "linux", Arch: "arm64"}, Status: StatusFailed},
+		{ID: "2", Reference: "redis:7", Platform: types.Platform{OS: "linux", Arch: "amd64"}, Status: StatusSuccess},
+		{ID: "1", Reference: "nginx:1.27", Platform: types.Platform{OS: "linux", Arch: "amd64"}, Status: StatusSuccess},
+	}
+	for query, want := range map[string]int{"": 3, "NGINX": 2, "nginx amd64": 1, "失败": 1, "busybox": 0} {
+		if got := len(Filter(entries, query)); got != want {
+			t.Errorf("Filter(%q) = %d entries, want %d", query, got, want)
+		}
+	}
+	refs := References(entries)
+	if len(refs) != 2 || refs[0] != "nginx:1.27" || refs[1] != "redis:7" {
+		t.Errorf("References() = %v", refs)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	store := NewStore(t.TempDir())
+	for i := 0; i < MaxEntries+2; i++ {
+		if err := store.Append(Entry{ID: fmt.Sprintf("%04d", i), Reference: "nginx"}, []LogLine{{Time: "00:00:00", Level: "info", Message: "ok"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != MaxEntries || entries[len(entries)-1].ID != "0002" {
+		t.Fatalf("got %d entries, oldest %s", len(entries), entries[len(entries)-1].ID)
+	}
+	if _, err := os.Stat(filepath.Join(store.Dir(), "logs", "0000.log")); !os.IsNotExist(err) {
+		t.Errorf("log of pruned entry still exists: %v", err)
+	}
+}
+
+// TestAppendConcurrent 多个写入者（如同时拉取的 CLI 与 TUI）在整理记录时不会丢失彼此追加的记录
+func TestAppendConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	var lines []byte
+	for i := 0; i < MaxEntries; i++ {
+		data, _ := json.Marshal(Entry{ID: fmt.Sprintf("old-%04d", i), Reference: "nginx"})
+		lines = append(append(lines, data...), '\n')
+	}
+	if err := os.WriteFile(filepath.Join(dir, "history.jsonl"), lines, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 每个写入者使用独立的 Store，与不同进程一样只靠锁文件互斥
+			if err := NewStore(dir).Append(Entry{ID: fmt.Sprintf("new-%d", i), Reference: "redis"}, nil); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := NewStore(dir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != MaxEntries {
+		t.Fatalf("got %d entries, want %d", len(entries), MaxEntries)
+	}
+	if got := len(Filter(entries, "redis")); got != writers {
+		t.Errorf("kept %d of %d concurrent entries", got, writers)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "history.lock")); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.lock")
+	for round := 0; round < 10; round++ {
+		// 异常退出的进程留下的锁文件
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-2 * staleLock)
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+
+		// 多个等待者同时发现锁过期时，仍然只能有一个持有锁
+		var holders atomic.Int32
+		var overlap atomic.Bool
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock, err := NewStore(dir).lock()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if holders.Add(1) > 1 {
+					overlap.Store(true)
+				}
+				time.Sleep(time.Millisecond)
+				holders.Add(-1)
+				unlock()
+			}()
+		}
+		wg.Wait()
+		if overlap.Load() {
+			t.Fatalf("round %d: more than one goroutine held the lock at once", round)
+		}
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+		t.Errorf("lock files left behind: %v (%v)", entries, err)
+	}
+}
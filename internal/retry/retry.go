@@ -1,6 +1,8 @@
 package retry
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -34,6 +36,11 @@ type RetryableFunc func() error
 
 // WithRetry 执行带重试的函数
 func WithRetry(fn RetryableFunc, config RetryConfig, operationName string) error {
+	return WithRetryContext(context.Background(), fn, config, operationName)
+}
+
+// WithRetryContext 执行带重试的函数，ctx 被取消后不再重试并立即结束等待
+func WithRetryContext(ctx context.Context, fn RetryableFunc, config RetryConfig, operationName string) error {
 	log := logger.GetLogger()
 	var lastErr error
 	
@@ -41,7 +48,13 @@ func WithRetry(fn RetryableFunc, config RetryConfig, operationName string) error
 		if attempt > 0 {
 			backoff := calculateBackoff(attempt, config)
 			log.Info("第 %d 次重试 %s，等待 %v...", attempt, operationName, backoff)
-			time.Sleep(backoff)
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return lastErr
+			}
 		}
 		
 		err := fn()
@@ -54,6 +67,11 @@ func WithRetry(fn RetryableFunc, config RetryConfig, operationName string) error
 		
 		lastErr = err
 		
+		// 被取消时重试没有意义
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+			return err
+		}
+		
 		if attempt < config.MaxRetries {
 			log.Warning("%s 失败: %v，准备重试...", operationName, err)
 		} else {
@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -62,6 +63,23 @@ func TestWithRetry(t *testing.T) {
 			t.Errorf("Expected 3 attempts, got %d", attempts)
 		}
 	})
+	
+	t.Run("stops when canceled", func(t *testing.T) {
+		attempts := 0
+		ctx, cancel := context.WithCancel(context.Background())
+		err := WithRetryContext(ctx, func() error {
+			attempts++
+			cancel()
+			return ctx.Err()
+		}, DefaultConfig(), "test operation")
+		
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		if attempts != 1 {
+			t.Errorf("Expected 1 attempt, got %d", attempts)
+		}
+	})
 }
 
 func TestCalculateBackoff(t *testing.T) {
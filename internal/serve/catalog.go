@@ -0,0 +1,426 @@
+package serve
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dipt/internal/docker"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// manifest 可按 tag 或 digest 获取的清单（镜像清单或镜像索引）
+type manifest struct {
+	data      []byte
+	mediaType types.MediaType
+}
+
+// blob 可下载的 blob
+// path 不为空时数据位于该文件的 [offset, offset+size) 区间，可直接按 Range 读取；
+// data 不为空时数据在内存中；否则通过 open 顺序读取
+type blob struct {
+	size   int64
+	path   string
+	offset int64
+	data   []byte
+	open   func() (io.ReadCloser, error)
+}
+
+// source 一个镜像文件（或 OCI layout 目录）中读出的内容
+type source struct {
+	path      string
+	modTime   time.Time
+	size      int64
+	tags      map[string]map[string]v1.Hash // 仓库 -> tag -> 清单 digest
+	manifests map[v1.Hash]manifest
+	blobs     map[v1.Hash]*blob
+	cleanup   func()
+}
+
+// catalog 保存目录中所有镜像的索引，按需重新扫描目录
+type catalog struct {
+	dir   string
+	onLog func(level, msg string)
+
+	mu       sync.RWMutex
+	sources  map[string]*source
+	repos    map[string]map[string]v1.Hash
+	lastScan time.Time
+}
+
+// rescanInterval 两次扫描目录的最小间隔
+const rescanInterval = 5 * time.Second
+
+func newCatalog(dir string, onLog func(level, msg string)) *catalog {
+	return &catalog{dir: dir, onLog: onLog, sources: make(map[string]*source)}
+}
+
+func (c *catalog) logf(level, format string, args ...interface{}) {
+	if c.onLog != nil {
+		c.onLog(level, fmt.Sprintf(format, args...))
+	}
+}
+
+// refresh 距上次扫描超过 rescanInterval 时重新扫描目录，只重新读取新增或修改过的文件
+func (c *catalog) refresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.lastScan) < rescanInterval {
+		return
+	}
+	if err := c.scanLocked(); err != nil {
+		c.logf("error", "扫描 %s 失败: %v", c.dir, err)
+	}
+}
+
+// scanLocked 扫描目录并重建仓库索引，调用方需持有写锁
+func (c *catalog) scanLocked() error {
+	c.lastScan = time.Now()
+	paths, err := docker.ImageSources(c.dir)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		seen[p] = true
+		modTime, size := sourceStamp(p)
+		if old, ok := c.sources[p]; ok && old.modTime.Equal(modTime) && old.size == size {
+			continue
+		}
+		src, err := loadSource(p)
+		if err != nil {
+			c.logf("warning", "跳过 %s: %v", filepath.Base(p), err)
+			delete(c.sources, p)
+			continue
+		}
+		src.modTime, src.size = modTime, size
+		if old, ok := c.sources[p]; ok {
+			old.cleanup()
+		}
+		c.sources[p] = src
+	}
+	for p, src := range c.sources {
+		if !seen[p] {
+			src.cleanup()
+			delete(c.sources, p)
+		}
+	}
+
+	// 按文件名排序合并，同名 tag 以排在后面的文件为准
+	names := make([]string, 0, len(c.sources))
+	for p := range c.sources {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	c.repos = make(map[string]map[string]v1.Hash)
+	for _, p := range names {
+		for repo, tags := range c.sources[p].tags {
+			if c.repos[repo] == nil {
+				c.repos[repo] = make(map[string]v1.Hash)
+			}
+			for tag, d := range tags {
+				c.repos[repo][tag] = d
+			}
+		}
+	}
+	return nil
+}
+
+// sourceStamp 返回用于判断文件是否变化的修改时间与大小
+// OCI layout 以 index.json 为准，分卷输出以分卷清单为准
+func sourceStamp(p string) (time.Time, int64) {
+	for _, candidate := range []string{filepath.Join(p, "index.json"), p, p + ".parts.json"} {
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+			return fi.ModTime(), fi.Size()
+		}
+	}
+	return time.Time{}, 0
+}
+
+// close 清理所有临时文件
+func (c *catalog) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, src := range c.sources {
+		src.cleanup()
+	}
+	c.sources = make(map[string]*source)
+}
+
+// repositories 返回排序后的仓库列表
+func (c *catalog) repositories() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	repos := make([]string, 0, len(c.repos))
+	for r := range c.repos {
+		repos = append(repos, r)
+	}
+	sort.Strings(repos)
+	return repos
+}
+
+// tags 返回仓库中排序后的 tag，仓库不存在时 ok 为 false
+func (c *catalog) tags(repo string) (tags []string, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	m, ok := c.repos[repo]
+	if !ok {
+		return nil, false
+	}
+	for t := range m {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags, true
+}
+
+// hasRepo 判断仓库是否存在
+func (c *catalog) hasRepo(repo string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.repos[repo]
+	return ok
+}
+
+// manifest 按 tag 或 digest 查找仓库中的清单
+func (c *catalog) manifest(repo, ref string) (manifest, v1.Hash, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tags, ok := c.repos[repo]
+	if !ok {
+		return manifest{}, v1.Hash{}, false
+	}
+	d, err := v1.NewHash(ref)
+	if err != nil {
+		if d, ok = tags[ref]; !ok {
+			return manifest{}, v1.Hash{}, false
+		}
+	}
+	for _, src := range c.sources {
+		if m, ok := src.manifests[d]; ok {
+			return m, d, true
+		}
+	}
+	return manifest{}, v1.Hash{}, false
+}
+
+// blob 按 digest 查找 blob
+func (c *catalog) blob(d v1.Hash) (*blob, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, src := range c.sources {
+		if b, ok := src.blobs[d]; ok {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// loadSource 读取一个镜像文件中的全部镜像
+func loadSource(p string) (*source, error) {
+	images, cleanup, err := docker.LoadImages(p)
+	if err != nil {
+		return nil, err
+	}
+	src := &source{
+		path:      p,
+		tags:      make(map[string]map[string]v1.Hash),
+		manifests: make(map[v1.Hash]manifest),
+		blobs:     make(map[v1.Hash]*blob),
+		cleanup:   cleanup,
+	}
+	sections := fileSections(p)
+	for _, img := range images {
+		item, ok := img.Item.(manifestItem)
+		if !ok {
+			cleanup()
+			return nil, fmt.Errorf("无法读取 %s 的清单", img.Ref)
+		}
+		d, err := src.addItem(item, sections)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("读取 %s 失败: %v", img.Ref, err)
+		}
+		for _, repo := range repoNames(img.Ref.Context().RepositoryStr()) {
+			if src.tags[repo] == nil {
+				src.tags[repo] = make(map[string]v1.Hash)
+			}
+			if tag, ok := img.Ref.(name.Tag); ok {
+				src.tags[repo][tag.TagStr()] = d
+			}
+		}
+	}
+	return src, nil
+}
+
+// repoNames 返回仓库可被访问的名称，Docker Hub 官方镜像同时可省略 library/ 前缀
+func repoNames(repo string) []string {
+	if short := strings.TrimPrefix(repo, "library/"); short != repo {
+		return []string{repo, short}
+	}
+	return []string{repo}
+}
+
+// manifestItem 镜像或镜像索引
+type manifestItem interface {
+	RawManifest() ([]byte, error)
+	MediaType() (types.MediaType, error)
+	Digest() (v1.Hash, error)
+}
+
+// addItem 登记镜像或镜像索引的清单及其引用的所有 blob，返回清单 digest
+func (s *source) addItem(item manifestItem, sections map[v1.Hash]section) (v1.Hash, error) {
+	raw, err := item.RawManifest()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	mt, err := item.MediaType()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	d, err := item.Digest()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	s.manifests[d] = manifest{data: raw, mediaType: mt}
+
+	switch v := item.(type) {
+	case v1.ImageIndex:
+		im, err := v.IndexManifest()
+		if err != nil {
+			return d, err
+		}
+		for _, desc := range im.Manifests {
+			var child manifestItem
+			if desc.MediaType.IsIndex() {
+				child, err = v.ImageIndex(desc.Digest)
+			} else if desc.MediaType.IsImage() {
+				child, err = v.Image(desc.Digest)
+			} else {
+				continue
+			}
+			if err != nil {
+				return d, err
+			}
+			if _, err := s.addItem(child, sections); err != nil {
+				return d, err
+			}
+		}
+	case v1.Image:
+		if err := s.addImageBlobs(v, sections); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+// addImageBlobs 登记镜像的 config 与各层
+func (s *source) addImageBlobs(img v1.Image, sections map[v1.Hash]section) error {
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	cfgName, err := img.ConfigName()
+	if err != nil {
+		return err
+	}
+	s.blobs[cfgName] = &blob{size: int64(len(cfg)), data: cfg}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	for _, l := range layers {
+		d, err := l.Digest()
+		if err != nil {
+			return err
+		}
+		size, err := l.Size()
+		if err != nil {
+			return err
+		}
+		if sec, ok := sections[d]; ok && sec.size == size {
+			s.blobs[d] = &blob{size: size, path: sec.path, offset: sec.offset}
+			continue
+		}
+		s.blobs[d] = &blob{size: size, open: l.Compressed}
+	}
+	return nil
+}
+
+// section 文件中的一段数据
+type section struct {
+	path   string
+	offset int64
+	size   int64
+}
+
+// fileSections 找出可直接按偏移读取的 blob：
+// OCI layout 目录中的 blobs/sha256/<hex> 文件，以及未压缩 tar 中按 digest 命名的条目
+// （docker 格式中的 <hex>.tar.gz 与 sha256:<hex>，oci-archive 中的 blobs/sha256/<hex>）
+func fileSections(p string) map[v1.Hash]section {
+	sections := make(map[v1.Hash]section)
+	if fi, err := os.Stat(p); err != nil {
+		return sections
+	} else if fi.IsDir() {
+		entries, _ := os.ReadDir(filepath.Join(p, "blobs", "sha256"))
+		for _, e := range entries {
+			d, err := v1.NewHash("sha256:" + e.Name())
+			if err != nil {
+				continue
+			}
+			if info, err := e.Info(); err == nil {
+				sections[d] = section{path: filepath.Join(p, "blobs", "sha256", e.Name()), size: info.Size()}
+			}
+		}
+		return sections
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return sections
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	if magic, _ := br.Peek(4); bytes.HasPrefix(magic, []byte{0x1f, 0x8b}) || bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		return sections
+	}
+	cr := &countingReader{r: br}
+	tr := tar.NewReader(cr)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return sections
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if d, err := v1.NewHash(docker.DigestFromPath(hdr.Name)); err == nil {
+			sections[d] = section{path: p, offset: cr.n, size: hdr.Size}
+		}
+	}
+}
+
+// countingReader 统计已读取的字节数，用于得到 tar 条目数据在文件中的偏移
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,312 @@
+// Package serve 将保存目录中的镜像以只读的 OCI distribution API 提供出去，
+// 让 docker pull、containerd、k3s 等可以直接从本机拉取
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Options 服务选项
+type Options struct {
+	Dir   string                  // 镜像目录，其中的 tar 文件、分卷输出与 OCI layout 都会被提供
+	OnLog func(level, msg string) // 日志回调
+}
+
+// Server 只读镜像仓库，实现 http.Handler
+type Server struct {
+	catalog *catalog
+}
+
+// New 扫描目录并创建服务；目录中新增或修改的文件会在之后的请求中自动加载
+func New(opts Options) (*Server, error) {
+	fi, err := os.Stat(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s 不是目录", opts.Dir)
+	}
+	c := newCatalog(opts.Dir, opts.OnLog)
+	c.mu.Lock()
+	err = c.scanLocked()
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &Server{catalog: c}, nil
+}
+
+// Close 清理读取 oci-archive 时产生的临时文件
+func (s *Server) Close() {
+	s.catalog.close()
+}
+
+// Repositories 返回当前可拉取的仓库
+func (s *Server) Repositories() []string {
+	return s.catalog.repositories()
+}
+
+// Tags 返回仓库中的 tag
+func (s *Server) Tags(repo string) []string {
+	tags, _ := s.catalog.tags(repo)
+	return tags
+}
+
+// 错误码，见 OCI distribution spec
+const (
+	codeNameUnknown     = "NAME_UNKNOWN"
+	codeManifestUnknown = "MANIFEST_UNKNOWN"
+	codeBlobUnknown     = "BLOB_UNKNOWN"
+	codeDigestInvalid   = "DIGEST_INVALID"
+	codeUnsupported     = "UNSUPPORTED"
+)
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, codeUnsupported, "只读仓库，不支持 "+r.Method)
+		return
+	}
+
+	path := r.URL.Path
+	if path == "/v2" || path == "/v2/" {
+		writeJSONBody(w, r, struct{}{})
+		return
+	}
+	if !strings.HasPrefix(path, "/v2/") {
+		http.NotFound(w, r)
+		return
+	}
+	s.catalog.refresh()
+
+	rest := strings.TrimPrefix(path, "/v2/")
+	if rest == "_catalog" {
+		repos, next := paginate(s.catalog.repositories(), r)
+		if next != "" {
+			w.Header().Set("Link", fmt.Sprintf(`</v2/_catalog?%s>; rel="next"`, next))
+		}
+		writeJSONBody(w, r, struct {
+			Repositories []string `json:"repositories"`
+		}{repos})
+		return
+	}
+
+	if repo, ok := strings.CutSuffix(rest, "/tags/list"); ok {
+		s.serveTags(w, r, repo)
+		return
+	}
+	if i := strings.LastIndex(rest, "/manifests/"); i > 0 {
+		s.serveManifest(w, r, rest[:i], rest[i+len("/manifests/"):])
+		return
+	}
+	if i := strings.LastIndex(rest, "/blobs/"); i > 0 {
+		s.serveBlob(w, r, rest[:i], rest[i+len("/blobs/"):])
+		return
+	}
+	writeError(w, http.StatusNotFound, codeUnsupported, "未知的接口 "+path)
+}
+
+// serveTags 处理 /v2/<name>/tags/list
+func (s *Server) serveTags(w http.ResponseWriter, r *http.Request, repo string) {
+	tags, ok := s.catalog.tags(repo)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeNameUnknown, "仓库不存在: "+repo)
+		return
+	}
+	tags, next := paginate(tags, r)
+	if next != "" {
+		w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?%s>; rel="next"`, repo, next))
+	}
+	writeJSONBody(w, r, struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}{repo, tags})
+}
+
+// serveManifest 处理 /v2/<name>/manifests/<tag 或 digest>
+func (s *Server) serveManifest(w http.ResponseWriter, r *http.Request, repo, ref string) {
+	if !s.catalog.hasRepo(repo) {
+		writeError(w, http.StatusNotFound, codeNameUnknown, "仓库不存在: "+repo)
+		return
+	}
+	m, d, ok := s.catalog.manifest(repo, ref)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeManifestUnknown, fmt.Sprintf("清单不存在: %s:%s", repo, ref))
+		return
+	}
+	if r.Method == http.MethodGet {
+		s.catalog.logf("info", "%s 拉取 %s:%s", r.RemoteAddr, repo, ref)
+	}
+	w.Header().Set("Content-Type", string(m.mediaType))
+	w.Header().Set("Docker-Content-Digest", d.String())
+	w.Header().Set("Content-Length", strconv.Itoa(len(m.data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(m.data)
+	}
+}
+
+// serveBlob 处理 /v2/<name>/blobs/<digest>，支持 Range 请求
+func (s *Server) serveBlob(w http.ResponseWriter, r *http.Request, repo, digest string) {
+	if !s.catalog.hasRepo(repo) {
+		writeError(w, http.StatusNotFound, codeNameUnknown, "仓库不存在: "+repo)
+		return
+	}
+	d, err := v1.NewHash(digest)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeDigestInvalid, "digest 无效: "+digest)
+		return
+	}
+	b, ok := s.catalog.blob(d)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeBlobUnknown, "blob 不存在: "+digest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", d.String())
+	w.Header().Set("ETag", `"`+d.String()+`"`)
+	switch {
+	case b.data != nil:
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b.data))
+	case b.path != "":
+		f, err := os.Open(b.path)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeBlobUnknown, err.Error())
+			return
+		}
+		defer f.Close()
+		http.ServeContent(w, r, "", time.Time{}, io.NewSectionReader(f, b.offset, b.size))
+	default:
+		serveStream(w, r, b)
+	}
+}
+
+// serveStream 提供只能顺序读取的 blob（如压缩 tar 中的层），Range 请求通过跳过前面的数据实现
+func serveStream(w http.ResponseWriter, r *http.Request, b *blob) {
+	start, length := int64(0), b.size
+	status := http.StatusOK
+	if h := r.Header.Get("Range"); h != "" {
+		s, e, ok := parseRange(h, b.size)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", b.size))
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, codeUnsupported, "无效的 Range: "+h)
+			return
+		}
+		start, length = s, e-s+1
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", s, e, b.size))
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+
+	rc, err := b.open()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeBlobUnknown, err.Error())
+		return
+	}
+	defer rc.Close()
+	if _, err := io.CopyN(io.Discard, rc, start); err != nil {
+		writeError(w, http.StatusInternalServerError, codeBlobUnknown, err.Error())
+		return
+	}
+	w.WriteHeader(status)
+	io.CopyN(w, rc, length)
+}
+
+// parseRange 解析单个区间的 Range 头（bytes=a-b、bytes=a-、bytes=-n），返回闭区间 [start, end]
+func parseRange(h string, size int64) (start, end int64, ok bool) {
+	spec, found := strings.CutPrefix(h, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	from, to, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+	if from == "" {
+		n, err := strconv.ParseInt(to, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, size > 0
+	}
+	start, err := strconv.ParseInt(from, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end = size - 1
+	if to != "" {
+		if end, err = strconv.ParseInt(to, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+// paginate 按 n 与 last 参数分页，还有更多结果时返回下一页的查询参数
+func paginate(items []string, r *http.Request) ([]string, string) {
+	q := r.URL.Query()
+	if last := q.Get("last"); last != "" {
+		i := 0
+		for i < len(items) && items[i] <= last {
+			i++
+		}
+		items = items[i:]
+	}
+	n, err := strconv.Atoi(q.Get("n"))
+	if err != nil || n <= 0 || n >= len(items) {
+		if items == nil {
+			items = []string{}
+		}
+		return items, ""
+	}
+	items = items[:n]
+	return items, fmt.Sprintf("last=%s&n=%d", items[n-1], n)
+}
+
+// writeJSONBody 输出 JSON 响应
+func writeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) {
+	data, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+// writeError 按 distribution spec 的格式输出错误
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	type apiError struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	data, _ := json.Marshal(struct {
+		Errors []apiError `json:"errors"`
+	}{[]apiError{{code, message}}})
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
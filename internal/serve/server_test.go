@@ -0,0 +1,109 @@
+package serve
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+func TestServePullAndRange(t *testing.T) {
+	dir := t.TempDir()
+	img, err := random.Image(2048, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag("docker.io/library/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tarball.WriteToFile(filepath.Join(dir, "app.tar"), tag, img); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := random.Index(512, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp, err := layout.Write(filepath.Join(dir, "multi"), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.AppendIndex(idx, layout.WithAnnotations(map[string]string{
+		"io.containerd.image.name": "example.com/team/multi:2",
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer s.Close()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	pulled, err := remote.Image(mustRef(t, u.Host+"/app:1.0"))
+	if err != nil {
+		t.Fatalf("pull app:1.0: %v", err)
+	}
+	got, _ := pulled.Digest()
+	want, _ := img.Digest()
+	if got != want {
+		t.Errorf("app digest = %s, want %s", got, want)
+	}
+
+	pulledIdx, err := remote.Index(mustRef(t, u.Host+"/team/multi:2"))
+	if err != nil {
+		t.Fatalf("pull team/multi:2: %v", err)
+	}
+	got, _ = pulledIdx.Digest()
+	want, _ = idx.Digest()
+	if got != want {
+		t.Errorf("index digest = %s, want %s", got, want)
+	}
+
+	layers, _ := img.Layers()
+	layerDigest, _ := layers[0].Digest()
+	rc, _ := layers[0].Compressed()
+	full, _ := io.ReadAll(rc)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v2/library/app/blobs/"+layerDigest.String(), nil)
+	req.Header.Set("Range", "bytes=10-99")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, full[10:100]) {
+		t.Errorf("range request: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+
+	resp, err = http.Get(srv.URL + "/v2/app/manifests/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing tag: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func mustRef(t *testing.T, s string) name.Reference {
+	t.Helper()
+	ref, err := name.ParseReference(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
@@ -0,0 +1,332 @@
+// Package split 将输出文件按固定大小分卷写入，并根据分卷清单校验、合并
+package split
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Manifest 分卷清单，与分卷文件放在同一目录
+type Manifest struct {
+	File     string `json:"file"`      // 合并后的文件名
+	Size     int64  `json:"size"`      // 合并后的总大小
+	SHA256   string `json:"sha256"`    // 合并后文件的校验和
+	PartSize int64  `json:"part_size"` // 分卷大小（最后一卷可能更小）
+	Parts    []Part `json:"parts"`
+}
+
+// Part 单个分卷
+type Part struct {
+	Name   string `json:"name"` // 分卷文件名（相对清单所在目录）
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ManifestPath 返回输出文件对应的分卷清单路径
+func ManifestPath(path string) string {
+	return path + ".parts.json"
+}
+
+// PartPath 返回第 n 个分卷（从 1 开始）的路径
+func PartPath(path string, n int) string {
+	return fmt.Sprintf("%s.part%03d", path, n)
+}
+
+// partialSuffix 写入过程中分卷与清单使用的临时文件后缀，Close 时才重命名为正式文件名
+const partialSuffix = ".partial"
+
+// Writer 按固定大小分卷写入，Close 时写出分卷清单
+type Writer struct {
+	path     string
+	partSize int64
+	manifest Manifest
+	total    hash.Hash
+
+	cur     *os.File
+	curHash hash.Hash
+	curSize int64
+	parts   []string // 分卷的正式路径，写入时使用加上 partialSuffix 的临时文件
+}
+
+// NewWriter 创建分卷写入器，path 为合并后文件的路径
+// 分卷先写入临时文件，Close 成功后才替换同名的旧分卷与清单，写入失败或取消时旧的输出保持不变
+func NewWriter(path string, partSize int64) (*Writer, error) {
+	if partSize <= 0 {
+		return nil, fmt.Errorf("分卷大小必须大于 0")
+	}
+	return &Writer{
+		path:     path,
+		partSize: partSize,
+		manifest: Manifest{File: filepath.Base(path), PartSize: partSize},
+		total:    sha256.New(),
+	}, nil
+}
+
+// Write 写入数据，当前分卷写满时自动切换到下一个分卷
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if w.cur == nil {
+			if err := w.openPart(); err != nil {
+				return written, err
+			}
+		}
+		chunk := p
+		if remain := w.partSize - w.curSize; int64(len(chunk)) > remain {
+			chunk = chunk[:remain]
+		}
+		n, err := w.cur.Write(chunk)
+		w.curHash.Write(chunk[:n])
+		w.total.Write(chunk[:n])
+		w.curSize += int64(n)
+		w.manifest.Size += int64(n)
+		written += n
+		if err != nil {
+			return written, fmt.Errorf("写入分卷失败: %v", err)
+		}
+		p = p[n:]
+		if w.curSize == w.partSize {
+			if err := w.closePart(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *Writer) openPart() error {
+	path := PartPath(w.path, len(w.parts)+1)
+	f, err := os.Create(path + partialSuffix)
+	if err != nil {
+		return fmt.Errorf("创建分卷失败: %v", err)
+	}
+	w.cur, w.curHash, w.curSize = f, sha256.New(), 0
+	w.parts = append(w.parts, path)
+	return nil
+}
+
+func (w *Writer) closePart() error {
+	if w.cur == nil {
+		return nil
+	}
+	err := w.cur.Close()
+	w.manifest.Parts = append(w.manifest.Parts, Part{
+		Name:   filepath.Base(w.parts[len(w.parts)-1]),
+		Size:   w.curSize,
+		SHA256: hex.EncodeToString(w.curHash.Sum(nil)),
+	})
+	w.cur = nil
+	if err != nil {
+		return fmt.Errorf("写入分卷失败: %v", err)
+	}
+	return nil
+}
+
+// Close 结束最后一个分卷，删除旧的分卷后把临时文件重命名为正式分卷，并写出分卷清单
+func (w *Writer) Close() error {
+	if err := w.closePart(); err != nil {
+		return err
+	}
+	if len(w.manifest.Parts) == 0 {
+		// 空输出也生成一个空分卷，保证 join 能还原
+		if err := w.openPart(); err != nil {
+			return err
+		}
+		if err := w.closePart(); err != nil {
+			return err
+		}
+	}
+	w.manifest.SHA256 = hex.EncodeToString(w.total.Sum(nil))
+	data, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifest := ManifestPath(w.path)
+	if err := os.WriteFile(manifest+partialSuffix, data, 0644); err != nil {
+		return fmt.Errorf("写入分卷清单失败: %v", err)
+	}
+
+	// 旧输出的分卷可能比本次多，多出的分卷一并删除
+	keep := make(map[string]bool, len(w.parts))
+	for _, p := range w.parts {
+		keep[p] = true
+	}
+	old, _ := filepath.Glob(GlobEscape(w.path) + ".part[0-9]*")
+	for _, p := range old {
+		if !keep[p] && !strings.HasSuffix(p, partialSuffix) {
+			os.Remove(p)
+		}
+	}
+	for _, p := range append(w.parts, manifest) {
+		if err := os.Rename(p+partialSuffix, p); err != nil {
+			return fmt.Errorf("写入分卷失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// Abort 放弃写入，删除本次生成的临时分卷，已有的同名输出保持不变
+func (w *Writer) Abort() {
+	if w.cur != nil {
+		w.cur.Close()
+		w.cur = nil
+	}
+	for _, p := range w.parts {
+		os.Remove(p + partialSuffix)
+	}
+	os.Remove(ManifestPath(w.path) + partialSuffix)
+}
+
+// Parts 返回已生成的分卷路径
+func (w *Writer) Parts() []string {
+	return append([]string(nil), w.parts...)
+}
+
+// ReadManifest 读取分卷清单
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取分卷清单失败: %v", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("解析分卷清单失败: %v", err)
+	}
+	if m.File == "" || len(m.Parts) == 0 {
+		return nil, fmt.Errorf("分卷清单 %s 内容不完整", path)
+	}
+	for _, p := range m.Parts {
+		if p.Name != filepath.Base(p.Name) || p.Name == "." || p.Name == ".." {
+			return nil, fmt.Errorf("分卷清单中的文件名无效: %s", p.Name)
+		}
+	}
+	if m.File != filepath.Base(m.File) {
+		return nil, fmt.Errorf("分卷清单中的文件名无效: %s", m.File)
+	}
+	return &m, nil
+}
+
+// PartError 分卷缺失或损坏
+type PartError struct {
+	Part   string
+	Reason string
+}
+
+func (e *PartError) Error() string {
+	return fmt.Sprintf("分卷 %s %s", e.Part, e.Reason)
+}
+
+// Verify 按清单校验所有分卷的大小与校验和，onPart 在每个分卷校验通过后调用
+func Verify(manifestPath string, onPart func(index int, part Part)) (*Manifest, error) {
+	m, err := ReadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	err = copyParts(io.Discard, filepath.Dir(manifestPath), m, onPart)
+	return m, err
+}
+
+// Join 校验并按顺序合并分卷，output 为空时写到清单所在目录下的原文件名
+// 先写入临时文件，全部校验通过后才重命名为目标文件
+func Join(manifestPath, output string, onPart func(index int, part Part)) (string, error) {
+	m, err := ReadManifest(manifestPath)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(manifestPath)
+	if output == "" {
+		output = filepath.Join(dir, m.File)
+	}
+
+	// 与其他输出一样以 0644（受 umask 影响）创建
+	tmp, err := os.OpenFile(output+partialSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", fmt.Errorf("创建输出文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := copyParts(tmp, dir, m, onPart); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("写入输出文件失败: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return "", fmt.Errorf("写入输出文件失败: %v", err)
+	}
+	return output, nil
+}
+
+// copyParts 依次读取分卷写入 w，同时校验每个分卷与整体的大小和校验和
+func copyParts(w io.Writer, dir string, m *Manifest, onPart func(index int, part Part)) error {
+	total := sha256.New()
+	var size int64
+	for i, part := range m.Parts {
+		f, err := os.Open(filepath.Join(dir, part.Name))
+		if err != nil {
+			return &PartError{Part: part.Name, Reason: "缺失"}
+		}
+		h := sha256.New()
+		n, err := io.Copy(io.MultiWriter(w, h, total), f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("读取分卷 %s 失败: %v", part.Name, err)
+		}
+		if n != part.Size {
+			return &PartError{Part: part.Name, Reason: fmt.Sprintf("大小不符（应为 %d 字节，实际 %d 字节）", part.Size, n)}
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != part.SHA256 {
+			return &PartError{Part: part.Name, Reason: "校验和不符，文件已损坏"}
+		}
+		size += n
+		if onPart != nil {
+			onPart(i, part)
+		}
+	}
+	if size != m.Size || hex.EncodeToString(total.Sum(nil)) != m.SHA256 {
+		return fmt.Errorf("合并后的文件校验失败，分卷清单可能已被修改")
+	}
+	return nil
+}
+
+// ParseSize 解析分卷大小，支持 K/M/G/T 后缀（按 1024 进位，可写作 KB、KiB 等），无后缀为字节
+func ParseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+	mult := int64(1)
+	if str != "" {
+		switch str[len(str)-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		case 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			str = str[:len(str)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("无效的分卷大小: %s (示例: 2GiB, 500M, 1048576)", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// GlobEscape 转义路径中的通配符，用于以路径为前缀拼接 filepath.Glob 的模式
+func GlobEscape(path string) string {
+	r := strings.NewReplacer("*", "\\*", "?", "\\?", "[", "\\[")
+	return r.Replace(path)
+}
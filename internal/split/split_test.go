@@ -0,0 +1,154 @@
+package split
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeParts(t *testing.T, path string, data []byte, partSize int64) *Writer {
+	t.Helper()
+	w, err := NewWriter(path, partSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 分多次写入，跨越分卷边界
+	for off := 0; off < len(data); off += 700 {
+		end := off + 700
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := w.Write(data[off:end]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestWriterJoin(t *testing.T) {
+	dir := t.TempDir()
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(data)
+	path := filepath.Join(dir, "image.tar")
+
+	w := writeParts(t, path, data, 4096)
+	if got := len(w.Parts()); got != 3 {
+		t.Fatalf("got %d parts, want 3", got)
+	}
+
+	m, err := ReadManifest(ManifestPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.File != "image.tar" || m.Size != int64(len(data)) || m.Parts[2].Size != 10000-2*4096 {
+		t.Errorf("manifest = %+v", m)
+	}
+
+	out, err := Join(ManifestPath(path), "", nil)
+	if err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("joined data differs from original")
+	}
+
+	// 合并后的文件与其他输出权限相同
+	ref := filepath.Join(dir, "ref")
+	if err := os.WriteFile(ref, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := os.Stat(ref)
+	if info, _ := os.Stat(out); info.Mode().Perm() != want.Mode().Perm() {
+		t.Errorf("joined file mode = %v, want %v", info.Mode().Perm(), want.Mode().Perm())
+	}
+}
+
+// TestWriterReplacesOldParts 重新写入更少的分卷时删除多出的旧分卷，放弃写入时保留旧的分卷
+func TestWriterReplacesOldParts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "image.tar")
+	writeParts(t, path, make([]byte, 10000), 4096)
+
+	w, err := NewWriter(path, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+	w.Abort()
+	if m, err := Verify(ManifestPath(path), nil); err != nil || len(m.Parts) != 3 {
+		t.Fatalf("old parts damaged by Abort: %v", err)
+	}
+
+	writeParts(t, path, []byte("small"), 4096)
+	if _, err := os.Stat(PartPath(path, 2)); !os.IsNotExist(err) {
+		t.Errorf("stale part left behind: %v", err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.partial"))
+	if len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+	if _, err := Verify(ManifestPath(path), nil); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestJoinDetectsCorruptPart(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("dipt"), 3000)
+	path := filepath.Join(dir, "image.tar")
+	writeParts(t, path, data, 5000)
+
+	part := PartPath(path, 2)
+	corrupt, _ := os.ReadFile(part)
+	corrupt[10] ^= 0xff
+	if err := os.WriteFile(part, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Join(ManifestPath(path), filepath.Join(dir, "out.tar"), nil)
+	var partErr *PartError
+	if !errors.As(err, &partErr) || partErr.Part != filepath.Base(part) {
+		t.Fatalf("Join() error = %v, want PartError for %s", err, filepath.Base(part))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.tar")); !os.IsNotExist(err) {
+		t.Error("output should not exist after failed join")
+	}
+
+	os.Remove(part)
+	if _, err := Verify(ManifestPath(path), nil); !errors.As(err, &partErr) {
+		t.Errorf("Verify() error = %v, want missing part error", err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"1024":  1024,
+		"4GiB":  4 << 30,
+		"2G":    2 << 30,
+		"500MB": 500 << 20,
+		"1.5k":  1536,
+	}
+	for in, want := range tests {
+		got, err := ParseSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0", "-1G", "abc"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) expected error", in)
+		}
+	}
+}
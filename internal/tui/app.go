@@ -1,15 +1,19 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"dipt/internal/batch"
 	"dipt/internal/config"
+	"dipt/internal/docker"
+	"dipt/internal/errors"
+	"dipt/internal/history"
 	"dipt/internal/tui/components"
 	"dipt/internal/tui/theme"
 	"dipt/internal/types"
-	"dipt/internal/docker"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -18,12 +22,19 @@ import (
 type AppState int
 
 const (
-	StateSetup    AppState = iota // 首次运行配置向导
-	StateMenu                    // 主菜单
-	StatePullForm                // 拉取表单
-	StatePulling                 // 拉取进度
-	StateSettings                // 设置
-	StateMirrors                 // 镜像源管理
+	StateSetup     AppState = iota // 首次运行配置向导
+	StateMenu                      // 主菜单
+	StatePullForm                  // 拉取表单
+	StatePulling                   // 拉取进度
+	StateSettings                  // 设置
+	StateMirrors                   // 镜像源管理
+	StateBatchForm                 // 批量拉取表单
+	StateBatching                  // 批量拉取进度
+	StateSaved                     // 已保存镜像
+	StateCopyForm                  // 仓库复制表单
+	StateCopying                   // 仓库复制进度
+	StateJobs                      // 任务列表
+	StateHistory                   // 拉取历史
 )
 
 // programRef 共享引用，解决 Bubble Tea 值拷贝导致 program 为 nil 的问题
@@ -40,12 +51,29 @@ type AppModel struct {
 	height     int
 
 	// 子模型
-	setup    components.SetupModel
-	menu     components.MenuModel
-	pullForm components.PullFormModel
-	pullProg components.PullProgressModel
-	settings components.SettingsModel
-	mirrors  components.MirrorsModel
+	setup     components.SetupModel
+	menu      components.MenuModel
+	pullForm  components.PullFormModel
+	jobs      components.JobsModel
+	settings  components.SettingsModel
+	mirrors   components.MirrorsModel
+	batchForm components.BatchFormModel
+	batchProg components.BatchProgressModel
+	saved     components.SavedModel
+	copyForm  components.CopyFormModel
+	copyProg  components.PullProgressModel
+	history   components.HistoryModel
+
+	// 拉取都在任务队列中进行：jobView 为拉取进度视图中显示的任务，
+	// jobReturn 为关闭该视图后返回的界面（菜单、任务列表或拉取历史）
+	jobView    int
+	jobReturn  AppState
+	jobCancels map[int]context.CancelFunc // 进行中任务的取消函数，所有副本共享
+	quitting   bool                       // 已取消全部任务，等进行中的任务结束（删除未写完的输出）后退出
+	copyCancel context.CancelFunc         // 进行中复制的取消函数，复制不在任务队列中
+
+	// historyStore 拉取历史的存储，未启用历史记录时为 nil
+	historyStore *history.Store
 
 	// tea.Program 共享引用，所有副本共享同一个指针
 	program *programRef
@@ -57,18 +85,24 @@ func NewApp() AppModel {
 	if err != nil || userCfg == nil {
 		// 需要首次配置
 		return AppModel{
-			state:   StateSetup,
-			setup:   components.NewSetupModel(),
-			program: &programRef{},
+			state:        StateSetup,
+			setup:        components.NewSetupModel(),
+			jobs:         components.NewJobsModel(),
+			jobCancels:   make(map[int]context.CancelFunc),
+			historyStore: history.DefaultStore(),
+			program:      &programRef{},
 		}
 	}
 
 	return AppModel{
-		state:      StateMenu,
-		userConfig: userCfg,
-		effConfig:  effCfg,
-		menu:       components.NewMenuModel(),
-		program:    &programRef{},
+		state:        StateMenu,
+		userConfig:   userCfg,
+		effConfig:    effCfg,
+		menu:         components.NewMenuModel(),
+		jobs:         components.NewJobsModel(),
+		jobCancels:   make(map[int]context.CancelFunc),
+		historyStore: history.DefaultStore(),
+		program:      &programRef{},
 	}
 }
 
@@ -78,8 +112,6 @@ func (m AppModel) Init() tea.Cmd {
 		return m.setup.Init()
 	case StateMenu:
 		return m.menu.Init()
-	case StatePulling:
-		return m.pullProg.Init()
 	default:
 		return nil
 	}
@@ -91,13 +123,25 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		m.jobs, _ = m.jobs.Update(msg)
+		m.history, _ = m.history.Update(msg)
+	case components.JobMsg:
+		// 后台任务的消息无论当前在哪个界面都要处理
+		return m.updateJob(msg)
 	case tea.KeyMsg:
 		if msg.String() == "ctrl+c" {
-			return m, tea.Quit
+			// 再次按下时不再等待任务结束
+			if m.quitting {
+				return m, tea.Quit
+			}
+			return m.quit()
+		}
+		if m.quitting {
+			return m, nil
 		}
 		// 仅在菜单状态下 q 退出
 		if msg.String() == "q" && m.state == StateMenu {
-			return m, tea.Quit
+			return m.requestQuit()
 		}
 	}
 
@@ -115,11 +159,30 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.updateSettings(msg)
 	case StateMirrors:
 		return m.updateMirrors(msg)
+	case StateBatchForm:
+		return m.updateBatchForm(msg)
+	case StateBatching:
+		return m.updateBatching(msg)
+	case StateSaved:
+		return m.updateSaved(msg)
+	case StateCopyForm:
+		return m.updateCopyForm(msg)
+	case StateCopying:
+		return m.updateCopying(msg)
+	case StateJobs:
+		return m.updateJobs(msg)
+	case StateHistory:
+		return m.updateHistory(msg)
 	}
 	return m, nil
 }
 
 func (m AppModel) View() string {
+	if m.quitting {
+		return theme.AppStyle.Render(theme.WarningStyle.Render(fmt.Sprintf("  正在取消 %d 个进行中的任务，删除未写完的输出后退出…", len(m.jobCancels))) +
+			"\n\n" + theme.HelpStyle.Render("  再次按 ctrl+c 立即退出"))
+	}
+
 	var content string
 	switch m.state {
 	case StateSetup:
@@ -129,11 +192,27 @@ func (m AppModel) View() string {
 	case StatePullForm:
 		content = m.pullForm.View()
 	case StatePulling:
-		content = m.pullProg.View()
+		if job := m.jobs.Job(m.jobView); job != nil {
+			content = job.Progress.View()
+		}
 	case StateSettings:
 		content = m.settings.View()
 	case StateMirrors:
 		content = m.mirrors.View()
+	case StateBatchForm:
+		content = m.batchForm.View()
+	case StateBatching:
+		content = m.batchProg.View()
+	case StateSaved:
+		content = m.saved.View()
+	case StateCopyForm:
+		content = m.copyForm.View()
+	case StateCopying:
+		content = m.copyProg.View()
+	case StateJobs:
+		content = m.jobs.View()
+	case StateHistory:
+		content = m.history.View()
 	}
 	return theme.AppStyle.Render(content)
 }
@@ -147,7 +226,7 @@ func (m AppModel) updateSetup(msg tea.Msg) (tea.Model, tea.Cmd) {
 		_, effCfg, _ := config.LoadEffectiveConfigs()
 		m.effConfig = effCfg
 		m.state = StateMenu
-		m.menu = components.NewMenuModel()
+		m.menu = m.newMenu()
 		return m, m.menu.Init()
 	}
 	var cmd tea.Cmd
@@ -161,8 +240,33 @@ func (m AppModel) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.Choice {
 		case components.MenuPull:
 			m.state = StatePullForm
-			m.pullForm = components.NewPullFormModel(m.userConfig)
+			m.pullForm = m.newPullForm()
+			if m.width > 0 {
+				m.pullForm, _ = m.pullForm.Update(m.windowSize())
+			}
 			return m, m.pullForm.Init()
+		case components.MenuJobs:
+			m.state = StateJobs
+			return m, m.jobs.Init()
+		case components.MenuHistory:
+			m.state = StateHistory
+			m.history = components.NewHistoryModel(m.historyStore)
+			if m.width > 0 {
+				m.history, _ = m.history.Update(m.windowSize())
+			}
+			return m, m.history.Init()
+		case components.MenuBatch:
+			m.state = StateBatchForm
+			m.batchForm = components.NewBatchFormModel()
+			return m, m.batchForm.Init()
+		case components.MenuCopy:
+			m.state = StateCopyForm
+			m.copyForm = components.NewCopyFormModel()
+			return m, m.copyForm.Init()
+		case components.MenuSaved:
+			m.state = StateSaved
+			m.saved = components.NewSavedModel(m.userConfig)
+			return m, m.saved.Init()
 		case components.MenuSettings:
 			m.state = StateSettings
 			m.settings = components.NewSettingsModel(m.userConfig)
@@ -172,8 +276,10 @@ func (m AppModel) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.mirrors = components.NewMirrorsModel(m.userConfig)
 			return m, m.mirrors.Init()
 		case components.MenuQuit:
-			return m, tea.Quit
+			return m.requestQuit()
 		}
+	case components.QuitConfirmedMsg:
+		return m.quit()
 	}
 	var cmd tea.Cmd
 	m.menu, cmd = m.menu.Update(msg)
@@ -184,31 +290,23 @@ func (m AppModel) updatePullForm(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case components.BackToMenuMsg:
 		m.state = StateMenu
-		m.menu = components.NewMenuModel()
+		m.menu = m.newMenu()
 		return m, m.menu.Init()
-	case components.StartPullMsg:
-		m.state = StatePulling
-		m.pullProg = components.NewPullProgressModel(msg.ImageName)
-
-		// 计算输出文件
-		outputFile := msg.OutputFile
-		if outputFile == "" {
-			outputFile = docker.GenerateOutputFileName(msg.ImageName, msg.Platform)
-			if m.userConfig != nil && m.userConfig.DefaultSaveDir != "" {
-				outputFile = filepath.Join(m.userConfig.DefaultSaveDir, outputFile)
-			}
-		}
-		_ = os.MkdirAll(filepath.Dir(outputFile), 0755)
-
-		// 重新加载配置以获取最新镜像源
+	case components.ListTagsMsg:
+		// 重新加载配置以获取最新镜像源与凭据
 		_, effCfg, _ := config.LoadEffectiveConfigs()
 		m.effConfig = effCfg
-
-		// 启动异步拉取
-		return m, tea.Batch(
-			m.pullProg.Init(),
-			m.startPull(msg.ImageName, outputFile, msg.Platform),
-		)
+		return m, m.listTags(msg.Repository)
+	case components.StartPullMsg:
+		job := m.enqueuePull(msg)
+		if msg.Background {
+			m.pullForm = m.pullForm.WithNotice(fmt.Sprintf("已加入任务队列: %s（%s）", msg.ImageName, m.jobs.Summary()))
+			return m, m.scheduleJobs()
+		}
+		// 保留表单以便取消后带着原有输入返回
+		m.state = StatePulling
+		m.jobView, m.jobReturn = job.ID, StatePullForm
+		return m, tea.Batch(m.scheduleJobs(), job.Progress.Init())
 	}
 	var cmd tea.Cmd
 	m.pullForm, cmd = m.pullForm.Update(msg)
@@ -216,19 +314,195 @@ func (m AppModel) updatePullForm(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m AppModel) updatePulling(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg.(type) {
+	case components.BackToMenuMsg:
+		// 拉取结束或转到后台：从任务列表或拉取历史打开的回到原处，否则回到菜单
+		switch m.jobReturn {
+		case StateJobs:
+			m.state = StateJobs
+			return m, nil
+		case StateHistory:
+			m.state = StateHistory
+			m.history.Reload()
+			return m, nil
+		}
+		m.state = StateMenu
+		m.menu = m.newMenu()
+		return m, m.menu.Init()
+	case components.CancelPullMsg:
+		m.cancelJob(m.jobView)
+		return m, nil
+	}
+	return m, m.jobs.UpdateJob(m.jobView, msg)
+}
+
+func (m AppModel) updateJobs(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case components.BackToMenuMsg:
 		m.state = StateMenu
-		m.menu = components.NewMenuModel()
+		m.menu = m.newMenu()
 		return m, m.menu.Init()
-	default:
-		_ = msg
+	case components.OpenJobMsg:
+		job := m.jobs.Job(msg.ID)
+		if job == nil {
+			return m, nil
+		}
+		m.state = StatePulling
+		m.jobView, m.jobReturn = job.ID, StateJobs
+		return m, job.Progress.Init()
+	case components.RetryJobMsg:
+		if m.jobs.Retry(msg.ID) {
+			_, effCfg, _ := config.LoadEffectiveConfigs()
+			m.effConfig = effCfg
+			return m, m.scheduleJobs()
+		}
+		return m, nil
+	case components.CancelJobMsg:
+		m.cancelJob(msg.ID)
+		return m, nil
 	}
 	var cmd tea.Cmd
-	m.pullProg, cmd = m.pullProg.Update(msg)
+	m.jobs, cmd = m.jobs.Update(msg)
 	return m, cmd
 }
 
+func (m AppModel) updateHistory(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case components.BackToMenuMsg:
+		m.state = StateMenu
+		m.menu = m.newMenu()
+		return m, m.menu.Init()
+	case components.RepullMsg:
+		job := m.enqueuePull(msg.Request)
+		m.state = StatePulling
+		m.jobView, m.jobReturn = job.ID, StateHistory
+		return m, tea.Batch(m.scheduleJobs(), job.Progress.Init())
+	}
+	var cmd tea.Cmd
+	m.history, cmd = m.history.Update(msg)
+	return m, cmd
+}
+
+// enqueuePull 计算输出文件并把拉取加入任务队列，由调用方启动调度
+func (m *AppModel) enqueuePull(req components.StartPullMsg) *components.Job {
+	outputFile := req.OutputFile
+	if outputFile == "" {
+		saveDir := ""
+		if m.userConfig != nil {
+			saveDir = m.userConfig.DefaultSaveDir
+		}
+		if req.AllPlatforms || len(req.Platforms) > 0 {
+			outputFile = docker.DefaultMultiOutputPath(req.ImageName, req.AllPlatforms, req.Platforms, req.Format, saveDir)
+		} else {
+			outputFile = docker.DefaultOutputPath(req.ImageName, req.Platform, req.Format, saveDir)
+		}
+	}
+	_ = os.MkdirAll(filepath.Dir(outputFile), 0755)
+
+	// 重新加载配置以获取最新镜像源
+	_, effCfg, _ := config.LoadEffectiveConfigs()
+	m.effConfig = effCfg
+
+	return m.jobs.Add(req, outputFile)
+}
+
+// updateJob 处理后台任务的消息：更新任务状态，任务结束时启动排队中的任务
+func (m AppModel) updateJob(msg components.JobMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.jobs, cmd = m.jobs.Update(msg)
+	done, ok := msg.Msg.(components.PullDoneMsg)
+	if !ok {
+		return m, cmd
+	}
+	if cancel := m.jobCancels[msg.ID]; cancel != nil {
+		cancel()
+		delete(m.jobCancels, msg.ID)
+	}
+	if m.quitting {
+		if len(m.jobCancels) == 0 {
+			return m, tea.Batch(cmd, tea.Quit)
+		}
+		return m, cmd
+	}
+	cmds := []tea.Cmd{cmd, m.scheduleJobs()}
+	switch {
+	case m.state == StatePulling && m.jobView == msg.ID && m.jobReturn == StatePullForm && errors.IsCanceledError(done.Err):
+		// 从表单发起的拉取被取消时带着原有输入回到表单
+		m.state = StatePullForm
+		m.pullForm = m.pullForm.WithNotice("已取消拉取，未写完的输出已删除；已下载的层保留在缓存中，再次拉取时续传")
+		cmds = append(cmds, m.pullForm.Init())
+	case m.state == StateMenu:
+		m.menu = m.menu.WithJobs(m.jobs.Summary())
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// maxJobs 返回任务队列中同时进行的拉取数
+func (m AppModel) maxJobs() int {
+	if n := m.effConfig.Transfer.MaxJobs; n > 0 {
+		return n
+	}
+	return components.DefaultMaxJobs
+}
+
+// scheduleJobs 按最大并发数启动排队中的任务
+func (m AppModel) scheduleJobs() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, job := range m.jobs.Start(m.maxJobs()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		m.jobCancels[job.ID] = cancel
+		cmds = append(cmds, m.startPull(ctx, job))
+	}
+	return tea.Batch(cmds...)
+}
+
+// cancelJob 取消任务：排队中的直接标记为已取消，进行中的中断拉取，结束后由 PullDoneMsg 更新状态
+func (m AppModel) cancelJob(id int) {
+	if m.jobs.CancelQueued(id) {
+		return
+	}
+	if cancel := m.jobCancels[id]; cancel != nil {
+		cancel()
+	}
+}
+
+// requestQuit 从菜单退出：还有排队中或进行中的任务时先请求确认，确认后由 quit 取消任务
+func (m AppModel) requestQuit() (tea.Model, tea.Cmd) {
+	if n := m.jobs.Active(); n > 0 {
+		m.menu = m.menu.ConfirmQuit(n)
+		return m, nil
+	}
+	return m, tea.Quit
+}
+
+// quit 取消排队中与进行中的全部任务后退出；有进行中的任务时等它们结束，
+// 让拉取删除未写完的输出，全部 PullDoneMsg 到达后再退出
+func (m AppModel) quit() (tea.Model, tea.Cmd) {
+	m.jobs.CancelAllQueued()
+	if len(m.jobCancels) == 0 {
+		return m, tea.Quit
+	}
+	for _, cancel := range m.jobCancels {
+		cancel()
+	}
+	m.quitting = true
+	return m, nil
+}
+
+// newPullForm 创建拉取表单，镜像名称可从拉取历史补全
+func (m AppModel) newPullForm() components.PullFormModel {
+	form := components.NewPullFormModel(m.userConfig)
+	if entries, err := m.historyStore.Load(); err == nil {
+		form = form.WithSuggestions(history.References(entries))
+	}
+	return form
+}
+
+// newMenu 创建主菜单，任务列表菜单项显示当前任务数量
+func (m AppModel) newMenu() components.MenuModel {
+	return components.NewMenuModel().WithJobs(m.jobs.Summary())
+}
+
 func (m AppModel) updateSettings(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg.(type) {
 	case components.BackToMenuMsg:
@@ -239,7 +513,7 @@ func (m AppModel) updateSettings(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.effConfig = effCfg
 		m.state = StateMenu
-		m.menu = components.NewMenuModel()
+		m.menu = m.newMenu()
 		return m, m.menu.Init()
 	}
 	var cmd tea.Cmd
@@ -257,7 +531,7 @@ func (m AppModel) updateMirrors(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.effConfig = effCfg
 		m.state = StateMenu
-		m.menu = components.NewMenuModel()
+		m.menu = m.newMenu()
 		return m, m.menu.Init()
 	}
 	var cmd tea.Cmd
@@ -265,34 +539,221 @@ func (m AppModel) updateMirrors(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-// startPull 启动异步拉取
-func (m AppModel) startPull(imageName, outputFile string, platform types.Platform) tea.Cmd {
+func (m AppModel) updateBatchForm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case components.BackToMenuMsg:
+		m.state = StateMenu
+		m.menu = m.newMenu()
+		return m, m.menu.Init()
+	case components.StartBatchMsg:
+		m.state = StateBatching
+		m.batchProg = components.NewBatchProgressModel(msg.Entries)
+
+		// 重新加载配置以获取最新镜像源
+		_, effCfg, _ := config.LoadEffectiveConfigs()
+		m.effConfig = effCfg
+
+		return m, tea.Batch(
+			m.batchProg.Init(),
+			m.startBatch(msg.Entries, msg.Bundle),
+		)
+	}
+	var cmd tea.Cmd
+	m.batchForm, cmd = m.batchForm.Update(msg)
+	return m, cmd
+}
+
+func (m AppModel) updateBatching(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(components.BackToMenuMsg); ok {
+		m.state = StateMenu
+		m.menu = m.newMenu()
+		return m, m.menu.Init()
+	}
+	var cmd tea.Cmd
+	m.batchProg, cmd = m.batchProg.Update(msg)
+	return m, cmd
+}
+
+func (m AppModel) updateSaved(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(components.BackToMenuMsg); ok {
+		m.state = StateMenu
+		m.menu = m.newMenu()
+		return m, m.menu.Init()
+	}
+	var cmd tea.Cmd
+	m.saved, cmd = m.saved.Update(msg)
+	return m, cmd
+}
+
+func (m AppModel) updateCopyForm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case components.BackToMenuMsg:
+		m.state = StateMenu
+		m.menu = m.newMenu()
+		return m, m.menu.Init()
+	case components.StartCopyMsg:
+		m.state = StateCopying
+		m.copyProg = components.NewCopyProgressModel(msg.Source, msg.Destination)
+		if m.width > 0 {
+			m.copyProg, _ = m.copyProg.Update(m.windowSize())
+		}
+
+		// 重新加载配置以获取最新镜像源
+		_, effCfg, _ := config.LoadEffectiveConfigs()
+		m.effConfig = effCfg
+
+		ctx, cancel := context.WithCancel(context.Background())
+		m.copyCancel = cancel
+		return m, tea.Batch(
+			m.copyProg.Init(),
+			m.startCopy(ctx, msg),
+		)
+	}
+	var cmd tea.Cmd
+	m.copyForm, cmd = m.copyForm.Update(msg)
+	return m, cmd
+}
+
+func (m AppModel) updateCopying(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg.(type) {
+	case components.BackToMenuMsg:
+		m.state = StateMenu
+		m.menu = m.newMenu()
+		return m, m.menu.Init()
+	case components.CancelPullMsg:
+		if m.copyCancel != nil {
+			m.copyCancel()
+		}
+		return m, nil
+	case components.CopyDoneMsg:
+		if m.copyCancel != nil {
+			m.copyCancel()
+			m.copyCancel = nil
+		}
+	}
+	var cmd tea.Cmd
+	m.copyProg, cmd = m.copyProg.Update(msg)
+	return m, cmd
+}
+
+// windowSize 返回当前终端大小，用于新建的视图按终端大小布局
+func (m AppModel) windowSize() tea.WindowSizeMsg {
+	return tea.WindowSizeMsg{Width: m.width, Height: m.height}
+}
+
+// send 向 tea.Program 发送消息（program 尚未就绪时丢弃）
+func (m AppModel) send(msg tea.Msg) {
+	if m.program.p != nil {
+		m.program.p.Send(msg)
+	}
+}
+
+// startBatch 启动异步批量拉取，bundle 非空时合并写入该打包文件
+func (m AppModel) startBatch(entries []batch.Entry, bundle string) tea.Cmd {
+	return func() tea.Msg {
+		opts := batch.Options{
+			Config:  m.effConfig,
+			History: m.historyStore,
+			OnStart: func(index int, _ batch.Entry, outputFile string) {
+				m.send(components.BatchItemStartMsg{Index: index, OutputFile: outputFile})
+			},
+			OnProgress: func(_ int, downloaded, total int64) {
+				m.send(components.ProgressMsg{Downloaded: downloaded, Total: total})
+			},
+			OnLog: func(_ int, level, msg string) {
+				m.send(components.LogMsg{Level: level, Message: msg})
+			},
+			OnWritten: func(_ int, written int64) {
+				m.send(components.WrittenMsg{Written: written})
+			},
+			OnDone: func(index int, result batch.Result) {
+				m.send(components.BatchItemDoneMsg{Index: index, Result: result})
+			},
+		}
+		if m.userConfig != nil {
+			opts.DefaultPlatform = types.Platform{OS: m.userConfig.DefaultOS, Arch: m.userConfig.DefaultArch}
+			opts.SaveDir = m.userConfig.DefaultSaveDir
+		}
+
+		if bundle == "" {
+			return components.BatchDoneMsg{Results: batch.Run(entries, opts)}
+		}
+		if !filepath.IsAbs(bundle) && opts.SaveDir != "" {
+			bundle = filepath.Join(opts.SaveDir, bundle)
+		}
+		results, info, err := batch.RunBundle(entries, opts, bundle)
+		return components.BatchDoneMsg{Results: results, Bundle: info, Err: err}
+	}
+}
+
+// listTags 异步获取仓库的标签列表
+func (m AppModel) listTags(repo string) tea.Cmd {
+	cfg := m.effConfig
+	return func() tea.Msg {
+		list, err := docker.ListTags(docker.ListTagsOptions{Repository: repo, Config: cfg})
+		return components.TagsLoadedMsg{Repository: repo, List: list, Err: err}
+	}
+}
+
+// startCopy 启动异步仓库间复制；ctx 被取消时中断复制
+func (m AppModel) startCopy(ctx context.Context, req components.StartCopyMsg) tea.Cmd {
+	return func() tea.Msg {
+		result, err := docker.CopyImage(docker.CopyOptions{
+			Source:      req.Source,
+			Destination: req.Destination,
+			Insecure:    req.Insecure,
+			Context:     ctx,
+			Config:      m.effConfig,
+			OnProgress: func(done, total int64) {
+				m.send(components.ProgressMsg{Downloaded: done, Total: total})
+			},
+			OnLog: func(level, msg string) {
+				m.send(components.LogMsg{Level: level, Message: msg})
+			},
+		})
+		return components.CopyDoneMsg{Result: result, Err: err}
+	}
+}
+
+// startPull 在后台执行任务的拉取，进度消息都带上任务 ID；ctx 被取消时中断拉取
+func (m AppModel) startPull(ctx context.Context, job *components.Job) tea.Cmd {
+	id, req, outputFile, cfg := job.ID, job.Request, job.OutputFile, m.effConfig
+	send := func(msg tea.Msg) {
+		m.send(components.JobMsg{ID: id, Msg: msg})
+	}
 	return func() tea.Msg {
 		opts := docker.PullOptions{
-			ImageName:  imageName,
-			OutputFile: outputFile,
-			Platform:   platform,
-			Config:     m.effConfig,
+			ImageName:    req.ImageName,
+			OutputFile:   outputFile,
+			Platform:     req.Platform,
+			Format:       req.Format,
+			AllPlatforms: req.AllPlatforms,
+			Platforms:    req.Platforms,
+			Config:       cfg,
+			Context:      ctx,
 			OnProgress: func(downloaded, total int64) {
-				if m.program.p != nil {
-					m.program.p.Send(components.ProgressMsg{
-						Downloaded: downloaded,
-						Total:      total,
-					})
-				}
+				send(components.ProgressMsg{Downloaded: downloaded, Total: total})
 			},
 			OnLog: func(level, msg string) {
-				if m.program.p != nil {
-					m.program.p.Send(components.LogMsg{
-						Level:   level,
-						Message: msg,
-					})
-				}
+				send(components.LogMsg{Level: level, Message: msg})
+			},
+			OnLayers: func(layers []docker.LayerProgress) {
+				send(components.LayersMsg{Layers: layers})
+			},
+			OnWritten: func(written int64) {
+				send(components.WrittenMsg{Written: written})
+			},
+			OnMirror: func(mirror string) {
+				send(components.MirrorMsg{Mirror: mirror})
 			},
 		}
 
-		err := docker.PullAndSave(opts)
-		return components.PullDoneMsg{Err: err}
+		rec := history.Track(m.historyStore, &opts)
+		result, err := docker.PullAndSave(opts)
+		if herr := rec.Finish(result, err); herr != nil {
+			send(components.LogMsg{Level: "warning", Message: herr.Error()})
+		}
+		return components.JobMsg{ID: id, Msg: components.PullDoneMsg{Result: result, Err: err}}
 	}
 }
 
@@ -0,0 +1,332 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dipt/internal/batch"
+	"dipt/internal/docker"
+	"dipt/internal/tui/theme"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// StartBatchMsg 开始批量拉取消息
+type StartBatchMsg struct {
+	File    string
+	Entries []batch.Entry
+	Bundle  string // 非空时合并写入该 docker save 打包文件
+}
+
+// BatchItemStartMsg 批量拉取中某一项开始
+type BatchItemStartMsg struct {
+	Index      int
+	OutputFile string
+}
+
+// BatchItemDoneMsg 批量拉取中某一项结束
+type BatchItemDoneMsg struct {
+	Index  int
+	Result batch.Result
+}
+
+// BatchDoneMsg 批量拉取全部结束
+type BatchDoneMsg struct {
+	Results []batch.Result
+	Bundle  *docker.BundleInfo // 打包模式下的打包结果
+	Err     error              // 打包失败的原因
+}
+
+// BatchFormModel 批量拉取表单
+type BatchFormModel struct {
+	fileInput   textinput.Model
+	bundleInput textinput.Model
+	focusBundle bool
+	err         string
+}
+
+// NewBatchFormModel 创建批量拉取表单
+func NewBatchFormModel() BatchFormModel {
+	ti := textinput.New()
+	ti.Placeholder = "images.txt / images.yaml / images.json"
+	ti.CharLimit = 512
+	ti.Width = 50
+	ti.Focus()
+
+	bi := textinput.New()
+	bi.Placeholder = "留空则每个镜像单独保存，如 bundle.tar"
+	bi.CharLimit = 512
+	bi.Width = 50
+	return BatchFormModel{fileInput: ti, bundleInput: bi}
+}
+
+func (m BatchFormModel) Init() tea.Cmd { return textinput.Blink }
+
+func (m BatchFormModel) Update(msg tea.Msg) (BatchFormModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			return m, func() tea.Msg { return BackToMenuMsg{} }
+		case "tab", "shift+tab", "up", "down":
+			m.focusBundle = !m.focusBundle
+			if m.focusBundle {
+				m.fileInput.Blur()
+				m.bundleInput.Focus()
+			} else {
+				m.bundleInput.Blur()
+				m.fileInput.Focus()
+			}
+			return m, nil
+		case "enter":
+			file := strings.TrimSpace(m.fileInput.Value())
+			if file == "" {
+				m.err = "请输入列表文件路径"
+				return m, nil
+			}
+			entries, err := batch.ParseFile(file)
+			if err != nil {
+				m.err = err.Error()
+				return m, nil
+			}
+			m.err = ""
+			bundle := strings.TrimSpace(m.bundleInput.Value())
+			return m, func() tea.Msg { return StartBatchMsg{File: file, Entries: entries, Bundle: bundle} }
+		}
+	}
+
+	var cmd tea.Cmd
+	if m.focusBundle {
+		m.bundleInput, cmd = m.bundleInput.Update(msg)
+	} else {
+		m.fileInput, cmd = m.fileInput.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m BatchFormModel) View() string {
+	var b strings.Builder
+	b.WriteString(theme.TitleStyle.Render("  批量拉取"))
+	b.WriteString("\n\n")
+	fileLabel, bundleLabel := "  列表文件: ", "  打包文件: "
+	if m.focusBundle {
+		bundleLabel = theme.HighlightStyle.Render(bundleLabel)
+	} else {
+		fileLabel = theme.HighlightStyle.Render(fileLabel)
+	}
+	b.WriteString(fileLabel + m.fileInput.View() + "\n\n")
+	b.WriteString(bundleLabel + m.bundleInput.View() + "\n\n")
+	b.WriteString(theme.SubtitleStyle.Render("  纯文本每行一个镜像；YAML/JSON 可为每项指定 platform 与 output\n"))
+	b.WriteString(theme.SubtitleStyle.Render("  填写打包文件时所有镜像合并为一个 docker save tar，可一次 docker load 导入"))
+
+	if m.err != "" {
+		b.WriteString("\n\n" + theme.ErrorStyle.Render("  "+m.err))
+	}
+
+	b.WriteString("\n\n" + theme.HelpStyle.Render("  tab 切换字段 · enter 开始 · esc 返回"))
+	return b.String()
+}
+
+// batchItemState 批量拉取条目状态
+type batchItemState int
+
+const (
+	batchPending batchItemState = iota
+	batchRunning
+	batchSucceeded
+	batchFailed
+)
+
+// batchListHeight 条目列表最多显示的行数
+const batchListHeight = 8
+
+// BatchProgressModel 批量拉取进度视图
+type BatchProgressModel struct {
+	spinner    spinner.Model
+	progress   progress.Model
+	viewport   viewport.Model
+	entries    []batch.Entry
+	states     []batchItemState
+	durations  []time.Duration
+	current    int
+	logs       []string
+	downloaded int64
+	total      int64
+	written    int64
+	results    []batch.Result
+	done       bool
+	width      int
+}
+
+// NewBatchProgressModel 创建批量拉取进度视图
+func NewBatchProgressModel(entries []batch.Entry) BatchProgressModel {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(theme.ColorPrimary)
+
+	p := progress.New(
+		progress.WithGradient(string(theme.ColorPrimary), string(theme.ColorSecondary)),
+		progress.WithWidth(50),
+	)
+
+	vp := viewport.New(60, 8)
+	vp.Style = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(theme.ColorMuted).
+		Padding(0, 1)
+
+	return BatchProgressModel{
+		spinner:   s,
+		progress:  p,
+		viewport:  vp,
+		entries:   entries,
+		states:    make([]batchItemState, len(entries)),
+		durations: make([]time.Duration, len(entries)),
+		width:     60,
+	}
+}
+
+func (m BatchProgressModel) Init() tea.Cmd {
+	return m.spinner.Tick
+}
+
+func (m BatchProgressModel) Update(msg tea.Msg) (BatchProgressModel, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width - 4
+		m.viewport.Width = m.width
+		m.progress = progress.New(
+			progress.WithGradient(string(theme.ColorPrimary), string(theme.ColorSecondary)),
+			progress.WithWidth(m.width-10),
+		)
+	case BatchItemStartMsg:
+		m.current = msg.Index
+		m.states[msg.Index] = batchRunning
+		m.downloaded, m.total, m.written = 0, 0, 0
+		cmds = append(cmds, m.progress.SetPercent(0))
+		m.appendLog(theme.HighlightStyle.Render(fmt.Sprintf("[%d/%d] %s -> %s",
+			msg.Index+1, len(m.entries), m.entries[msg.Index].Image, msg.OutputFile)))
+	case BatchItemDoneMsg:
+		m.durations[msg.Index] = msg.Result.Duration
+		if msg.Result.Err != nil {
+			m.states[msg.Index] = batchFailed
+			m.appendLog(theme.ErrorStyle.Render("拉取失败: " + msg.Result.Err.Error()))
+		} else {
+			m.states[msg.Index] = batchSucceeded
+		}
+	case BatchDoneMsg:
+		m.done = true
+		m.results = msg.Results
+		failed := batch.Failed(msg.Results)
+		if msg.Err != nil {
+			m.appendLog(theme.ErrorStyle.Render("打包失败: " + msg.Err.Error()))
+		} else if msg.Bundle != nil {
+			m.appendLog(theme.SuccessStyle.Render(fmt.Sprintf("已打包 %d 个引用（%d 个镜像，%d 个层，%s）",
+				len(msg.Bundle.Refs), msg.Bundle.Images, msg.Bundle.Layers, formatBytes(msg.Bundle.Size))))
+		}
+		summary := fmt.Sprintf("批量拉取结束: 成功 %d 个，失败 %d 个", len(msg.Results)-failed, failed)
+		if failed > 0 {
+			m.appendLog(theme.WarningStyle.Render(summary))
+		} else {
+			m.appendLog(theme.SuccessStyle.Render(summary))
+		}
+	case ProgressMsg:
+		m.downloaded = msg.Downloaded
+		m.total = msg.Total
+		if m.total > 0 {
+			cmds = append(cmds, m.progress.SetPercent(float64(m.downloaded)/float64(m.total)))
+		}
+	case WrittenMsg:
+		m.written = msg.Written
+	case LogMsg:
+		m.appendLog(styleLog(msg.Level, msg.Message))
+	case tea.KeyMsg:
+		if m.done {
+			switch msg.String() {
+			case "enter", "esc":
+				return m, func() tea.Msg { return BackToMenuMsg{} }
+			}
+		}
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		cmds = append(cmds, cmd)
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		cmds = append(cmds, cmd)
+	case progress.FrameMsg:
+		progressModel, cmd := m.progress.Update(msg)
+		m.progress = progressModel.(progress.Model)
+		cmds = append(cmds, cmd)
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m *BatchProgressModel) appendLog(line string) {
+	m.logs = append(m.logs, line)
+	m.viewport.SetContent(strings.Join(m.logs, "\n"))
+	m.viewport.GotoBottom()
+}
+
+func (m BatchProgressModel) View() string {
+	var b strings.Builder
+	b.WriteString(theme.TitleStyle.Render("  批量拉取"))
+	b.WriteString("\n\n")
+
+	finished := 0
+	for _, st := range m.states {
+		if st == batchSucceeded || st == batchFailed {
+			finished++
+		}
+	}
+	b.WriteString(fmt.Sprintf("  进度: %d / %d\n\n", finished, len(m.entries)))
+
+	// 以当前项为中心显示一个窗口
+	start := m.current - batchListHeight/2
+	if start > len(m.entries)-batchListHeight {
+		start = len(m.entries) - batchListHeight
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + batchListHeight
+	if end > len(m.entries) {
+		end = len(m.entries)
+	}
+	for i := start; i < end; i++ {
+		var icon, extra string
+		switch m.states[i] {
+		case batchPending:
+			icon = theme.SubtitleStyle.Render("·")
+		case batchRunning:
+			icon = m.spinner.View()
+		case batchSucceeded:
+			icon = theme.SuccessStyle.Render("✓")
+			extra = theme.SubtitleStyle.Render(" " + m.durations[i].Round(100*time.Millisecond).String())
+		case batchFailed:
+			icon = theme.ErrorStyle.Render("✗")
+		}
+		b.WriteString(fmt.Sprintf("  %s %s%s\n", icon, m.entries[i].Image, extra))
+	}
+	b.WriteString("\n")
+
+	if !m.done && m.total > 0 {
+		b.WriteString("  " + m.progress.View() + "\n")
+		b.WriteString(fmt.Sprintf("  %s / %s%s\n\n", formatBytes(m.downloaded), formatBytes(m.total), writtenSuffix(m.written)))
+	}
+
+	b.WriteString("  " + strings.ReplaceAll(m.viewport.View(), "\n", "\n  ") + "\n")
+
+	if m.done {
+		b.WriteString("\n" + theme.HelpStyle.Render("  enter/esc 返回菜单"))
+	}
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package components
+
+import (
+	"strings"
+
+	"dipt/internal/docker"
+	"dipt/internal/tui/theme"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// StartCopyMsg 开始仓库间复制消息
+type StartCopyMsg struct {
+	Source      string
+	Destination string
+	Insecure    bool
+}
+
+// CopyDoneMsg 仓库间复制完成消息
+type CopyDoneMsg struct {
+	Result *docker.CopyResult
+	Err    error
+}
+
+// CopyFormModel 仓库间复制表单
+type CopyFormModel struct {
+	srcInput textinput.Model
+	dstInput textinput.Model
+	focusDst bool
+	insecure bool
+	err      string
+}
+
+// NewCopyFormModel 创建仓库间复制表单
+func NewCopyFormModel() CopyFormModel {
+	si := textinput.New()
+	si.Placeholder = "docker.io/library/redis:7"
+	si.CharLimit = 256
+	si.Width = 50
+	si.Focus()
+
+	di := textinput.New()
+	di.Placeholder = "registry.internal/mirror/redis（不写 tag 时沿用源 tag）"
+	di.CharLimit = 256
+	di.Width = 50
+	return CopyFormModel{srcInput: si, dstInput: di}
+}
+
+func (m CopyFormModel) Init() tea.Cmd { return textinput.Blink }
+
+func (m CopyFormModel) Update(msg tea.Msg) (CopyFormModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			return m, func() tea.Msg { return BackToMenuMsg{} }
+		case "tab", "shift+tab", "up", "down":
+			m.focusDst = !m.focusDst
+			if m.focusDst {
+				m.srcInput.Blur()
+				m.dstInput.Focus()
+			} else {
+				m.dstInput.Blur()
+				m.srcInput.Focus()
+			}
+			return m, nil
+		case "ctrl+t":
+			m.insecure = !m.insecure
+			return m, nil
+		case "enter":
+			src := strings.TrimSpace(m.srcInput.Value())
+			dst := strings.TrimSpace(m.dstInput.Value())
+			if src == "" || dst == "" {
+				m.err = "请输入源镜像和目标引用"
+				return m, nil
+			}
+			srcRef, err := name.ParseReference(src)
+			if err != nil {
+				m.err = "源镜像引用无效: " + err.Error()
+				return m, nil
+			}
+			if _, err := docker.CopyDestination(srcRef, dst, m.insecure); err != nil {
+				m.err = err.Error()
+				return m, nil
+			}
+			m.err = ""
+			insecure := m.insecure
+			return m, func() tea.Msg { return StartCopyMsg{Source: src, Destination: dst, Insecure: insecure} }
+		}
+	}
+
+	var cmd tea.Cmd
+	if m.focusDst {
+		m.dstInput, cmd = m.dstInput.Update(msg)
+	} else {
+		m.srcInput, cmd = m.srcInput.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m CopyFormModel) View() string {
+	var b strings.Builder
+	b.WriteString(theme.TitleStyle.Render("  仓库复制"))
+	b.WriteString("\n\n")
+	srcLabel, dstLabel := "  源镜像:   ", "  目标引用: "
+	if m.focusDst {
+		dstLabel = theme.HighlightStyle.Render(dstLabel)
+	} else {
+		srcLabel = theme.HighlightStyle.Render(srcLabel)
+	}
+	b.WriteString(srcLabel + m.srcInput.View() + "\n\n")
+	b.WriteString(dstLabel + m.dstInput.View() + "\n\n")
+	check := "[ ]"
+	if m.insecure {
+		check = "[x]"
+	}
+	b.WriteString("  " + check + " 使用 HTTP 访问目标仓库\n\n")
+	b.WriteString(theme.SubtitleStyle.Render("  镜像直接在仓库之间传输，不写入磁盘；多平台索引按原样复制，目标已有的层不会重复上传\n"))
+	b.WriteString(theme.SubtitleStyle.Render("  源为 Docker Hub 镜像时优先使用配置的镜像加速器"))
+
+	if m.err != "" {
+		b.WriteString("\n\n" + theme.ErrorStyle.Render("  "+m.err))
+	}
+
+	b.WriteString("\n\n" + theme.HelpStyle.Render("  tab 切换字段 · ctrl+t 切换 HTTP · enter 开始 · esc 返回"))
+	return b.String()
+}
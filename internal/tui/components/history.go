@@ -0,0 +1,353 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"dipt/internal/docker"
+	"dipt/internal/history"
+	"dipt/internal/tui/theme"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// RepullMsg 按历史记录重新拉取
+type RepullMsg struct {
+	Request StartPullMsg
+}
+
+// HistoryModel 拉取历史视图：搜索过滤、查看日志与重新拉取
+type HistoryModel struct {
+	store     *history.Store
+	entries   []history.Entry // 全部记录，最近的在前
+	filtered  []history.Entry // 匹配搜索词的记录
+	search    textinput.Model
+	searching bool // 正在输入搜索词
+	cursor    int
+	offset    int // 列表滚动位置
+	logView   bool
+	log       viewport.Model
+	err       string
+	size      tea.WindowSizeMsg
+}
+
+// NewHistoryModel 创建历史视图并读取记录
+func NewHistoryModel(store *history.Store) HistoryModel {
+	search := textinput.New()
+	search.Prompt = "/ "
+	search.Placeholder = "镜像、平台、结果或输出路径"
+	search.CharLimit = 128
+	search.Width = 40
+
+	vp := viewport.New(60, 10)
+	vp.Style = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(theme.ColorMuted).
+		Padding(0, 1)
+
+	m := HistoryModel{store: store, search: search, log: vp}
+	m.Reload()
+	return m
+}
+
+// Reload 重新读取记录，保留搜索词
+func (m *HistoryModel) Reload() {
+	entries, err := m.store.Load()
+	m.entries, m.err = entries, ""
+	if err != nil {
+		m.err = err.Error()
+	}
+	m.applyFilter()
+}
+
+// applyFilter 按搜索词过滤记录，并把光标限制在范围内
+func (m *HistoryModel) applyFilter() {
+	m.filtered = history.Filter(m.entries, m.search.Value())
+	m.cursor = min(m.cursor, max(0, len(m.filtered)-1))
+	m.offset = min(m.offset, m.cursor)
+}
+
+// selected 返回光标所在的记录
+func (m HistoryModel) selected() *history.Entry {
+	if m.cursor < 0 || m.cursor >= len(m.filtered) {
+		return nil
+	}
+	return &m.filtered[m.cursor]
+}
+
+// rows 列表可显示的行数
+func (m HistoryModel) rows() int {
+	if m.size.Height == 0 {
+		return 12
+	}
+	return max(3, m.size.Height-16)
+}
+
+// repullRequest 按记录生成拉取请求：沿用原来的平台、格式与输出路径，合并打包中的记录按默认路径保存
+func repullRequest(e history.Entry) StartPullMsg {
+	req := StartPullMsg{
+		ImageName:    e.Reference,
+		OutputFile:   e.Output,
+		Platform:     e.Platform,
+		Format:       e.Format,
+		AllPlatforms: e.AllPlatforms,
+		Platforms:    e.Platforms,
+	}
+	if req.Format == "" {
+		req.Format = docker.FormatDocker
+	}
+	return req
+}
+
+func (m HistoryModel) Init() tea.Cmd { return nil }
+
+func (m HistoryModel) Update(msg tea.Msg) (HistoryModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.size = msg
+		m.log.Width = msg.Width - 4
+		m.log.Height = max(5, msg.Height-20)
+		return m, nil
+	case tea.KeyMsg:
+		switch {
+		case m.searching:
+			return m.updateSearch(msg)
+		case m.logView:
+			return m.updateLog(msg)
+		}
+		entry := m.selected()
+		switch msg.String() {
+		case "esc":
+			if m.search.Value() != "" {
+				m.search.SetValue("")
+				m.applyFilter()
+				return m, nil
+			}
+			return m, func() tea.Msg { return BackToMenuMsg{} }
+		case "/":
+			m.searching = true
+			return m, m.search.Focus()
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.filtered)-1 {
+				m.cursor++
+			}
+		case "enter", "l":
+			if entry != nil {
+				m.openLog(*entry)
+			}
+		case "r":
+			if entry != nil {
+				req := repullRequest(*entry)
+				return m, func() tea.Msg { return RepullMsg{Request: req} }
+			}
+		case "ctrl+r":
+			m.Reload()
+		}
+		m.offset = min(m.offset, m.cursor)
+		if m.cursor >= m.offset+m.rows() {
+			m.offset = m.cursor - m.rows() + 1
+		}
+	}
+	return m, nil
+}
+
+// updateSearch 处理搜索框中的输入，边输入边过滤
+func (m HistoryModel) updateSearch(msg tea.KeyMsg) (HistoryModel, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.search.SetValue("")
+		fallthrough
+	case "enter", "down":
+		m.searching = false
+		m.search.Blur()
+		m.applyFilter()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.search, cmd = m.search.Update(msg)
+	m.cursor, m.offset = 0, 0
+	m.applyFilter()
+	return m, cmd
+}
+
+// updateLog 处理日志视图中的按键
+func (m HistoryModel) updateLog(msg tea.KeyMsg) (HistoryModel, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "enter", "q":
+		m.logView = false
+		return m, nil
+	case "r":
+		if entry := m.selected(); entry != nil {
+			req := repullRequest(*entry)
+			return m, func() tea.Msg { return RepullMsg{Request: req} }
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.log, cmd = m.log.Update(msg)
+	return m, cmd
+}
+
+// openLog 读取记录的日志并切换到日志视图
+func (m *HistoryModel) openLog(e history.Entry) {
+	lines, err := m.store.ReadLog(e.ID)
+	var logs []string
+	switch {
+	case err != nil:
+		logs = append(logs, theme.ErrorStyle.Render(err.Error()))
+	case len(lines) == 0:
+		logs = append(logs, layerMutedStyle.Render("没有保存日志"))
+	}
+	for _, l := range lines {
+		// 多行消息的后续行与第一行对齐
+		msg := strings.ReplaceAll(l.Message, "\n", "\n"+strings.Repeat(" ", len(l.Time)+9))
+		logs = append(logs, layerMutedStyle.Render(l.Time)+" "+styleLog(l.Level, msg))
+	}
+	m.log.SetContent(strings.Join(logs, "\n"))
+	m.log.GotoBottom()
+	m.logView = true
+}
+
+// historyColumns 历史列表各列的显示宽度（最后一列输出不限宽）
+var historyColumns = []int{16, 36, 14, 6, 8}
+
+// historyWidth 输出列之前各列的总宽度
+const historyWidth = 16 + 36 + 14 + 6 + 8 + 5
+
+// historyStatusStyle 返回拉取结果的显示样式
+func historyStatusStyle(status history.Status) lipgloss.Style {
+	switch status {
+	case history.StatusSuccess:
+		return theme.SuccessStyle
+	case history.StatusFailed:
+		return theme.ErrorStyle
+	default:
+		return layerMutedStyle
+	}
+}
+
+// historyOutput 返回记录的输出描述
+func historyOutput(e history.Entry) string {
+	switch {
+	case e.Bundle != "":
+		return "打包到 " + e.Bundle
+	case e.Output != "":
+		return e.Output
+	default:
+		return "-"
+	}
+}
+
+func (m HistoryModel) View() string {
+	var b strings.Builder
+	b.WriteString(theme.TitleStyle.Render("  拉取历史"))
+	b.WriteString("\n\n")
+
+	if m.logView {
+		if e := m.selected(); e != nil {
+			b.WriteString(m.detailView(*e))
+		}
+		b.WriteString("\n" + m.log.View() + "\n")
+		b.WriteString("\n" + theme.HelpStyle.Render("  ↑↓ 滚动 · r 重新拉取 · esc 返回列表"))
+		return b.String()
+	}
+
+	if m.searching || m.search.Value() != "" {
+		b.WriteString("  " + m.search.View() + "\n")
+	}
+	summary := fmt.Sprintf("共 %d 条记录", len(m.entries))
+	if m.search.Value() != "" {
+		summary = fmt.Sprintf("匹配 %d / %d 条记录", len(m.filtered), len(m.entries))
+	}
+	b.WriteString(theme.SubtitleStyle.Render("  "+summary) + "\n\n")
+
+	switch {
+	case m.err != "":
+		b.WriteString("  " + theme.ErrorStyle.Render(m.err) + "\n")
+	case len(m.entries) == 0:
+		b.WriteString("  暂无拉取记录\n")
+	case len(m.filtered) == 0:
+		b.WriteString("  没有匹配的记录\n")
+	default:
+		header := []string{"时间", "镜像", "平台", "结果", "耗时"}
+		for i, h := range header {
+			header[i] = pad(h, historyColumns[i])
+		}
+		b.WriteString("  " + jobHeaderStyle.Render(strings.Join(header, " ")+" 输出") + "\n")
+		end := min(len(m.filtered), m.offset+m.rows())
+		for i := m.offset; i < end; i++ {
+			e := m.filtered[i]
+			output := historyOutput(e)
+			if m.size.Width > 0 {
+				output = truncate(output, max(10, m.size.Width-12-historyWidth))
+			}
+			row := strings.Join([]string{
+				pad(e.Time.Local().Format("01-02 15:04:05"), historyColumns[0]),
+				pad(e.Reference, historyColumns[1]),
+				pad(e.PlatformLabel(), historyColumns[2]),
+				historyStatusStyle(e.Status).Render(pad(e.Status.Label(), historyColumns[3])),
+				pad(formatETA(e.Duration()), historyColumns[4]),
+				output,
+			}, " ")
+			if i == m.cursor {
+				b.WriteString(theme.SelectedStyle.Render("▸") + " " + row + "\n")
+			} else {
+				b.WriteString("  " + row + "\n")
+			}
+		}
+		if len(m.filtered) > m.rows() {
+			b.WriteString(layerMutedStyle.Render(fmt.Sprintf("  第 %d-%d 条，共 %d 条", m.offset+1, end, len(m.filtered))) + "\n")
+		}
+	}
+
+	if e := m.selected(); e != nil && e.Status == history.StatusFailed && e.Error != "" {
+		msg := strings.SplitN(e.Error, "\n", 2)[0]
+		b.WriteString("\n  " + theme.ErrorStyle.Render(truncate(msg, max(20, m.size.Width-8))) + "\n")
+	}
+
+	if m.searching {
+		b.WriteString("\n" + theme.HelpStyle.Render("  输入搜索词过滤 · enter 完成 · esc 清除"))
+	} else {
+		b.WriteString("\n" + theme.HelpStyle.Render("  ↑↓ 选择 · / 搜索 · enter 查看日志 · r 重新拉取 · ctrl+r 刷新 · esc 返回"))
+	}
+	return b.String()
+}
+
+// detailView 渲染记录的详细信息
+func (m HistoryModel) detailView(e history.Entry) string {
+	keyStyle := lipgloss.NewStyle().Foreground(theme.ColorMuted).Width(10)
+	mirror := e.Mirror
+	if mirror == "" {
+		mirror = "直连"
+	}
+	fields := [][2]string{
+		{"镜像", e.Reference},
+		{"时间", e.Time.Local().Format("2006-01-02 15:04:05")},
+		{"平台", e.PlatformLabel()},
+		{"格式", string(repullRequest(e).Format)},
+		{"输出", historyOutput(e)},
+		{"镜像源", mirror},
+		{"耗时", formatETA(e.Duration())},
+	}
+	if e.Digest != "" {
+		fields = append(fields, [2]string{"Digest", e.Digest})
+	}
+
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString("  " + keyStyle.Render(f[0]) + f[1] + "\n")
+	}
+	b.WriteString("  " + keyStyle.Render("结果") + historyStatusStyle(e.Status).Render(e.Status.Label()) + "\n")
+	if e.Error != "" {
+		msg := strings.SplitN(e.Error, "\n", 2)[0]
+		b.WriteString("  " + keyStyle.Render("") + theme.ErrorStyle.Render(truncate(msg, max(20, m.size.Width-20))) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,435 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dipt/internal/docker"
+	"dipt/internal/errors"
+	"dipt/internal/tui/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultMaxJobs 未配置时任务队列中同时进行的拉取数
+const DefaultMaxJobs = 2
+
+// JobStatus 任务状态
+type JobStatus string
+
+const (
+	JobQueued   JobStatus = "排队中"
+	JobRunning  JobStatus = "进行中"
+	JobDone     JobStatus = "完成"
+	JobFailed   JobStatus = "失败"
+	JobCanceled JobStatus = "已取消"
+)
+
+// finished 任务是否已经结束
+func (s JobStatus) finished() bool {
+	return s == JobDone || s == JobFailed || s == JobCanceled
+}
+
+// JobMsg 后台任务发出的消息，Msg 为 ProgressMsg、LogMsg、PullDoneMsg 等进度消息
+type JobMsg struct {
+	ID  int
+	Msg tea.Msg
+}
+
+// MirrorMsg 任务开始使用某个镜像加速器，Mirror 为空表示使用原始地址
+type MirrorMsg struct {
+	Mirror string
+}
+
+// OpenJobMsg 打开任务的进度与日志
+type OpenJobMsg struct{ ID int }
+
+// RetryJobMsg 重新拉取失败或已取消的任务
+type RetryJobMsg struct{ ID int }
+
+// CancelJobMsg 取消排队中或进行中的任务
+type CancelJobMsg struct{ ID int }
+
+// Job 任务队列中的一次拉取
+type Job struct {
+	ID         int
+	Request    StartPullMsg
+	OutputFile string
+	Status     JobStatus
+	Mirror     string
+	Started    time.Time
+	Finished   time.Time
+	Err        error
+	Progress   PullProgressModel // 进度与日志，打开任务时直接显示
+}
+
+// Elapsed 任务已运行（或运行了）多久
+func (j *Job) Elapsed() time.Duration {
+	switch {
+	case j.Started.IsZero():
+		return 0
+	case j.Finished.IsZero():
+		return time.Since(j.Started)
+	default:
+		return j.Finished.Sub(j.Started)
+	}
+}
+
+// PlatformLabel 返回任务的平台描述
+func (j *Job) PlatformLabel() string {
+	r := j.Request
+	if r.AllPlatforms || len(r.Platforms) > 0 {
+		return docker.PlatformsLabel(r.AllPlatforms, r.Platforms)
+	}
+	return r.Platform.OS + "/" + r.Platform.Arch
+}
+
+// JobsModel 任务队列与任务列表视图
+type JobsModel struct {
+	jobs       []*Job
+	nextID     int
+	cursor     int
+	confirming bool // 等待确认取消选中的任务
+	size       tea.WindowSizeMsg
+}
+
+// NewJobsModel 创建空的任务队列
+func NewJobsModel() JobsModel {
+	return JobsModel{nextID: 1}
+}
+
+// Add 把一次拉取加入队列
+func (m *JobsModel) Add(req StartPullMsg, outputFile string) *Job {
+	job := &Job{ID: m.nextID, Request: req, OutputFile: outputFile}
+	m.nextID++
+	m.reset(job)
+	m.jobs = append(m.jobs, job)
+	return job
+}
+
+// reset 让任务回到排队状态，进度与日志重新开始
+func (m *JobsModel) reset(job *Job) {
+	job.Status = JobQueued
+	job.Mirror, job.Err = "", nil
+	job.Started, job.Finished = time.Time{}, time.Time{}
+	job.Progress = NewPullProgressModel(job.Request.ImageName)
+	job.Progress.queued = true
+	if m.size.Width > 0 {
+		job.Progress, _ = job.Progress.Update(m.size)
+	}
+}
+
+// Job 按 ID 查找任务，不存在时返回 nil
+func (m JobsModel) Job(id int) *Job {
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// Retry 将失败或已取消的任务重新排队
+func (m *JobsModel) Retry(id int) bool {
+	job := m.Job(id)
+	if job == nil || (job.Status != JobFailed && job.Status != JobCanceled) {
+		return false
+	}
+	m.reset(job)
+	return true
+}
+
+// CancelQueued 取消尚未开始的任务，任务不在排队中时返回 false
+func (m *JobsModel) CancelQueued(id int) bool {
+	job := m.Job(id)
+	if job == nil || job.Status != JobQueued {
+		return false
+	}
+	job.Status = JobCanceled
+	job.Err = errors.NewCanceledError(nil)
+	job.Progress.queued = false
+	job.Progress.finish(job.Err, "", "")
+	return true
+}
+
+// CancelAllQueued 取消全部尚未开始的任务
+func (m *JobsModel) CancelAllQueued() {
+	for _, job := range m.jobs {
+		m.CancelQueued(job.ID)
+	}
+}
+
+// Start 按最大并发数取出要开始的排队任务，并标记为进行中
+func (m *JobsModel) Start(maxJobs int) []*Job {
+	if maxJobs < 1 {
+		maxJobs = DefaultMaxJobs
+	}
+	running := m.count(JobRunning)
+	var started []*Job
+	for _, job := range m.jobs {
+		if running >= maxJobs {
+			break
+		}
+		if job.Status == JobQueued {
+			job.Status = JobRunning
+			job.Started = time.Now()
+			job.Progress.queued = false
+			started = append(started, job)
+			running++
+		}
+	}
+	return started
+}
+
+// count 统计处于某状态的任务数
+func (m JobsModel) count(status JobStatus) int {
+	n := 0
+	for _, job := range m.jobs {
+		if job.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Active 返回排队中与进行中的任务数
+func (m JobsModel) Active() int {
+	return m.count(JobQueued) + m.count(JobRunning)
+}
+
+// Summary 返回任务数量摘要（如 "进行中 1 · 排队 2"），没有任务时为空
+func (m JobsModel) Summary() string {
+	var parts []string
+	for _, c := range []struct {
+		label  string
+		status JobStatus
+	}{{"进行中", JobRunning}, {"排队", JobQueued}, {"失败", JobFailed}, {"完成", JobDone}} {
+		if n := m.count(c.status); n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", c.label, n))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// UpdateJob 把消息交给任务的进度视图处理（如打开任务时的按键与动画）
+func (m *JobsModel) UpdateJob(id int, msg tea.Msg) tea.Cmd {
+	job := m.Job(id)
+	if job == nil {
+		return nil
+	}
+	var cmd tea.Cmd
+	job.Progress, cmd = job.Progress.Update(msg)
+	return cmd
+}
+
+// selected 返回光标所在的任务
+func (m JobsModel) selected() *Job {
+	if m.cursor < 0 || m.cursor >= len(m.jobs) {
+		return nil
+	}
+	return m.jobs[m.cursor]
+}
+
+func (m JobsModel) Init() tea.Cmd { return nil }
+
+func (m JobsModel) Update(msg tea.Msg) (JobsModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.size = msg
+		for _, job := range m.jobs {
+			job.Progress, _ = job.Progress.Update(msg)
+		}
+	case JobMsg:
+		job := m.Job(msg.ID)
+		if job == nil {
+			return m, nil
+		}
+		switch inner := msg.Msg.(type) {
+		case MirrorMsg:
+			job.Mirror = inner.Mirror
+			return m, nil
+		case PullDoneMsg:
+			job.Finished = time.Now()
+			job.Err = inner.Err
+			switch {
+			case inner.Err == nil:
+				job.Status = JobDone
+			case errors.IsCanceledError(inner.Err):
+				job.Status = JobCanceled
+			default:
+				job.Status = JobFailed
+			}
+			if inner.Result != nil {
+				job.Mirror = inner.Result.Mirror
+			}
+		}
+		return m, m.UpdateJob(msg.ID, msg.Msg)
+	case tea.KeyMsg:
+		if m.confirming {
+			switch msg.String() {
+			case "y", "enter":
+				m.confirming = false
+				if job := m.selected(); job != nil {
+					id := job.ID
+					return m, func() tea.Msg { return CancelJobMsg{ID: id} }
+				}
+			case "n", "esc":
+				m.confirming = false
+			}
+			return m, nil
+		}
+		job := m.selected()
+		switch msg.String() {
+		case "esc":
+			return m, func() tea.Msg { return BackToMenuMsg{} }
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.jobs)-1 {
+				m.cursor++
+			}
+		case "enter":
+			if job != nil {
+				id := job.ID
+				return m, func() tea.Msg { return OpenJobMsg{ID: id} }
+			}
+		case "r":
+			if job != nil && (job.Status == JobFailed || job.Status == JobCanceled) {
+				id := job.ID
+				return m, func() tea.Msg { return RetryJobMsg{ID: id} }
+			}
+		case "x", "ctrl+x":
+			if job != nil && !job.Status.finished() {
+				m.confirming = true
+			}
+		case "c":
+			m.clearFinished()
+		}
+	}
+	return m, nil
+}
+
+// clearFinished 从列表中移除已完成的任务，失败与已取消的任务保留以便重试
+func (m *JobsModel) clearFinished() {
+	var kept []*Job
+	for _, job := range m.jobs {
+		if job.Status != JobDone {
+			kept = append(kept, job)
+		}
+	}
+	m.jobs = kept
+	m.cursor = min(m.cursor, max(0, len(m.jobs)-1))
+}
+
+var jobHeaderStyle = lipgloss.NewStyle().Foreground(theme.ColorMuted).Bold(true)
+
+// jobColumns 任务列表各列的显示宽度（最后一列耗时不限宽）
+var jobColumns = []int{4, 36, 14, 8, 16, 24, 8}
+
+// jobStatusStyle 返回任务状态的显示样式
+func jobStatusStyle(status JobStatus) lipgloss.Style {
+	switch status {
+	case JobRunning:
+		return layerActiveStyle
+	case JobDone:
+		return theme.SuccessStyle
+	case JobFailed:
+		return theme.ErrorStyle
+	default:
+		return layerMutedStyle
+	}
+}
+
+// jobProgress 返回任务进度列的文字
+func jobProgress(job *Job) string {
+	p := job.Progress
+	switch {
+	case job.Status == JobDone:
+		return "100%"
+	case p.total <= 0:
+		return "-"
+	default:
+		return fmt.Sprintf("%3.0f%% %s", float64(p.downloaded)/float64(p.total)*100, formatBytes(p.total))
+	}
+}
+
+func (m JobsModel) View() string {
+	var b strings.Builder
+	b.WriteString(theme.TitleStyle.Render("  任务列表"))
+	b.WriteString("\n\n")
+	if summary := m.Summary(); summary != "" {
+		b.WriteString(theme.SubtitleStyle.Render("  "+summary) + "\n\n")
+	}
+
+	if len(m.jobs) == 0 {
+		b.WriteString("  暂无任务，在拉取表单中按 ctrl+b 可加入队列后继续操作\n")
+		b.WriteString("\n" + theme.HelpStyle.Render("  esc 返回"))
+		return b.String()
+	}
+
+	header := []string{"#", "镜像", "平台", "状态", "进度", "镜像源", "耗时"}
+	for i, h := range header {
+		header[i] = pad(h, jobColumns[i])
+	}
+	b.WriteString("  " + jobHeaderStyle.Render(strings.Join(header, " ")) + "\n")
+	for i, job := range m.jobs {
+		mirror := job.Mirror
+		if mirror == "" {
+			mirror = "-"
+		}
+		elapsed := "-"
+		if d := job.Elapsed(); d > 0 {
+			elapsed = formatETA(d)
+		}
+		row := strings.Join([]string{
+			pad(fmt.Sprint(job.ID), jobColumns[0]),
+			pad(job.Request.ImageName, jobColumns[1]),
+			pad(job.PlatformLabel(), jobColumns[2]),
+			jobStatusStyle(job.Status).Render(pad(string(job.Status), jobColumns[3])),
+			pad(jobProgress(job), jobColumns[4]),
+			pad(mirror, jobColumns[5]),
+			elapsed,
+		}, " ")
+		if i == m.cursor {
+			b.WriteString(theme.SelectedStyle.Render("▸") + " " + row + "\n")
+		} else {
+			b.WriteString("  " + row + "\n")
+		}
+	}
+
+	if job := m.selected(); job != nil && job.Status == JobFailed && job.Err != nil {
+		msg := strings.SplitN(job.Err.Error(), "\n", 2)[0]
+		b.WriteString("\n  " + theme.ErrorStyle.Render(truncate(msg, max(20, m.size.Width-8))) + "\n")
+	}
+
+	switch {
+	case m.confirming:
+		b.WriteString("\n" + theme.WarningStyle.Render("  确认取消选中的任务？") +
+			"\n" + theme.HelpStyle.Render("  y/enter 取消任务 · n/esc 继续"))
+	default:
+		b.WriteString("\n" + theme.HelpStyle.Render("  ↑↓ 选择 · enter 查看日志 · r 重试 · x 取消 · c 清除已完成 · esc 返回"))
+	}
+	return b.String()
+}
+
+// truncate 按显示宽度截断过长的文字，末尾加省略号
+func truncate(s string, n int) string {
+	if lipgloss.Width(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && lipgloss.Width(string(r))+1 > n {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
+
+// pad 截断或用空格补齐到显示宽度 n（中文按两列计算）
+func pad(s string, n int) string {
+	s = truncate(s, n)
+	return s + strings.Repeat(" ", max(0, n-lipgloss.Width(s)))
+}
@@ -0,0 +1,239 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dipt/internal/docker"
+	"dipt/internal/tui/theme"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// LayersMsg 各层下载状态更新消息
+type LayersMsg struct {
+	Layers []docker.LayerProgress
+}
+
+// speedWindow 计算平均速度的时间窗口
+const speedWindow = 5 * time.Second
+
+// speedSample 某一时刻的已下载字节数
+type speedSample struct {
+	at time.Time
+	n  int64
+}
+
+// speedMeter 统计传输速度：起始以来的整体速度与最近 speedWindow 内的移动平均速度
+type speedMeter struct {
+	start   time.Time
+	base    int64 // 开始计时时已完成的字节数（如缓存中的层）
+	samples []speedSample
+}
+
+// add 记录当前已下载的字节数，进度回退（如重试）时重新计算移动平均
+func (s *speedMeter) add(n int64, now time.Time) {
+	if s.start.IsZero() {
+		s.start, s.base = now, n
+	}
+	if k := len(s.samples); k > 0 {
+		last := s.samples[k-1]
+		if n < last.n {
+			s.samples = s.samples[:0]
+		} else if now.Sub(last.at) < 200*time.Millisecond {
+			return
+		}
+	}
+	s.samples = append(s.samples, speedSample{at: now, n: n})
+	cut := 0
+	for cut < len(s.samples)-2 && now.Sub(s.samples[cut].at) > speedWindow {
+		cut++
+	}
+	s.samples = s.samples[cut:]
+}
+
+// skip 将无需下载的字节（缓存中的层）从速度统计中排除
+func (s *speedMeter) skip(n int64) {
+	if n > s.base {
+		s.base = n
+		s.samples = s.samples[:0]
+	}
+}
+
+// rate 移动平均速度（字节/秒），按当前时间计算，下载停滞时逐渐降低
+func (s *speedMeter) rate(now time.Time) float64 {
+	if len(s.samples) < 2 {
+		return 0
+	}
+	first, last := s.samples[0], s.samples[len(s.samples)-1]
+	secs := now.Sub(first.at).Seconds()
+	if secs <= 0 {
+		return 0
+	}
+	return float64(last.n-first.n) / secs
+}
+
+// overall 开始以来的整体速度（字节/秒）
+func (s *speedMeter) overall(n int64, now time.Time) float64 {
+	secs := now.Sub(s.start).Seconds()
+	if s.start.IsZero() || secs < 1 || n <= s.base {
+		return 0
+	}
+	return float64(n-s.base) / secs
+}
+
+// eta 按移动平均速度估算剩余时间，无法估算时返回 false
+func (s *speedMeter) eta(downloaded, total int64, now time.Time) (time.Duration, bool) {
+	r := s.rate(now)
+	if r <= 0 || total <= downloaded {
+		return 0, false
+	}
+	return time.Duration(float64(total-downloaded) / r * float64(time.Second)), true
+}
+
+// formatRate 格式化速度
+func formatRate(r float64) string {
+	if r <= 0 {
+		return "-"
+	}
+	return formatBytes(int64(r)) + "/s"
+}
+
+// formatETA 格式化剩余时间
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+var (
+	layerMutedStyle  = lipgloss.NewStyle().Foreground(theme.ColorMuted)
+	layerActiveStyle = lipgloss.NewStyle().Foreground(theme.ColorSecondary)
+)
+
+// layerStateLabel 返回层状态的显示文字与样式
+func layerStateLabel(state docker.LayerState) (string, lipgloss.Style) {
+	switch state {
+	case docker.LayerDownloading:
+		return "下载中", layerActiveStyle
+	case docker.LayerVerifying:
+		return "校验中", layerActiveStyle
+	case docker.LayerDone:
+		return "完成", theme.SuccessStyle
+	case docker.LayerCached:
+		return "已缓存", layerMutedStyle
+	case docker.LayerFailed:
+		return "失败", theme.ErrorStyle
+	default:
+		return "等待", layerMutedStyle
+	}
+}
+
+// layerFinished 层是否已经不再需要下载
+func layerFinished(l docker.LayerProgress) bool {
+	return l.State == docker.LayerDone || l.State == docker.LayerCached
+}
+
+// renderLayers 渲染各层进度表，最多 rows 行；放不下时优先显示未完成的层，其余汇总为一行
+func renderLayers(layers []docker.LayerProgress, width, rows int) string {
+	if len(layers) == 0 || rows < 1 {
+		return ""
+	}
+	show := make([]bool, len(layers))
+	limit := rows
+	if len(layers) > rows {
+		limit = rows - 1 // 留一行汇总
+	}
+	picked := 0
+	for pass := 0; pass < 2 && picked < limit; pass++ {
+		for i, l := range layers {
+			if picked == limit {
+				break
+			}
+			if !show[i] && (pass == 1 || !layerFinished(l)) {
+				show[i] = true
+				picked++
+			}
+		}
+	}
+
+	// digest 与大小各占一列，状态文字与缩进之外的宽度给进度条（最多 30 列）
+	barWidth := width - 19 - 10 - 8 - 8
+	if barWidth > 30 {
+		barWidth = 30
+	}
+
+	var b strings.Builder
+	var hiddenDone, hiddenCached, hiddenOther int
+	for i, l := range layers {
+		if !show[i] {
+			switch l.State {
+			case docker.LayerDone:
+				hiddenDone++
+			case docker.LayerCached:
+				hiddenCached++
+			default:
+				hiddenOther++
+			}
+			continue
+		}
+		name := shortLayerDigest(l.Digest)
+		if l.Config {
+			name = "config " + strings.TrimPrefix(name, "sha256:")
+		}
+		label, style := layerStateLabel(l.State)
+		row := fmt.Sprintf("%-19s %9s ", name, formatBytes(l.Total))
+		if barWidth >= 5 {
+			row += layerBar(l, barWidth) + " "
+		}
+		if l.State == docker.LayerCached {
+			b.WriteString("  " + layerMutedStyle.Render(row+label) + "\n")
+			continue
+		}
+		b.WriteString("  " + row + style.Render(label) + "\n")
+	}
+	if hidden := hiddenDone + hiddenCached + hiddenOther; hidden > 0 {
+		var parts []string
+		for _, c := range []struct {
+			label string
+			n     int
+		}{{"完成", hiddenDone}, {"已缓存", hiddenCached}, {"未完成", hiddenOther}} {
+			if c.n > 0 {
+				parts = append(parts, fmt.Sprintf("%s %d", c.label, c.n))
+			}
+		}
+		b.WriteString("  " + layerMutedStyle.Render(fmt.Sprintf("… 另有 %d 层（%s）", hidden, strings.Join(parts, "，"))) + "\n")
+	}
+	return b.String()
+}
+
+// layerBar 单个层的文字进度条，缓存中的层用不同的字符区分
+func layerBar(l docker.LayerProgress, width int) string {
+	filled := 0
+	if l.Total > 0 {
+		filled = int(float64(width) * float64(min(l.Downloaded, l.Total)) / float64(l.Total))
+	}
+	if l.State == docker.LayerCached {
+		return strings.Repeat("▒", width)
+	}
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+	switch l.State {
+	case docker.LayerDone:
+		return theme.SuccessStyle.Render(bar)
+	case docker.LayerFailed:
+		return theme.ErrorStyle.Render(bar)
+	default:
+		return layerActiveStyle.Render(bar)
+	}
+}
+
+// shortLayerDigest 返回 sha256: 加 12 位十六进制的短 digest
+func shortLayerDigest(digest string) string {
+	if i := strings.Index(digest, ":"); i >= 0 && len(digest) > i+13 {
+		return digest[:i+13]
+	}
+	return digest
+}
@@ -17,6 +17,11 @@ type MenuChoice int
 
 const (
 	MenuPull MenuChoice = iota
+	MenuJobs
+	MenuHistory
+	MenuBatch
+	MenuCopy
+	MenuSaved
 	MenuSettings
 	MenuMirrors
 	MenuQuit
@@ -61,9 +66,10 @@ func (d menuDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 
 // MenuModel 主菜单模型
 type MenuModel struct {
-	list   list.Model
-	choice MenuChoice
-	chosen bool
+	list        list.Model
+	choice      MenuChoice
+	chosen      bool
+	confirmQuit int // 大于 0 时等待确认退出，为未结束的任务数
 }
 
 // MenuChosenMsg 菜单选择消息
@@ -71,16 +77,24 @@ type MenuChosenMsg struct {
 	Choice MenuChoice
 }
 
+// QuitConfirmedMsg 确认取消未结束的任务并退出
+type QuitConfirmedMsg struct{}
+
 // NewMenuModel 创建主菜单
 func NewMenuModel() MenuModel {
 	items := []list.Item{
 		menuItem{title: "拉取镜像", desc: "从 Docker Registry 拉取并保存镜像", icon: "📦"},
+		menuItem{title: "任务列表", desc: "查看后台拉取的进度与日志，重试失败的任务", icon: "📋"},
+		menuItem{title: "拉取历史", desc: "搜索拉取记录，查看日志或一键重新拉取", icon: "🕘"},
+		menuItem{title: "批量拉取", desc: "按列表文件依次拉取多个镜像", icon: "📚"},
+		menuItem{title: "仓库复制", desc: "在仓库之间直接复制镜像，不落盘", icon: "🔁"},
+		menuItem{title: "已保存镜像", desc: "查看保存目录中镜像的来源与 digest", icon: "🗂️"},
 		menuItem{title: "设置", desc: "配置默认平台、保存目录等", icon: "⚙️"},
 		menuItem{title: "镜像源管理", desc: "添加、删除、测试镜像加速器", icon: "🔗"},
 		menuItem{title: "退出", desc: "退出 DIPT", icon: "👋"},
 	}
 
-	l := list.New(items, menuDelegate{}, 50, 14)
+	l := list.New(items, menuDelegate{}, 50, 31)
 	l.Title = ""
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(false)
@@ -90,11 +104,38 @@ func NewMenuModel() MenuModel {
 	return MenuModel{list: l}
 }
 
+// WithJobs 在任务列表菜单项中显示任务数量摘要，summary 为空时显示默认说明
+func (m MenuModel) WithJobs(summary string) MenuModel {
+	if summary == "" {
+		return m
+	}
+	item := m.list.Items()[MenuJobs].(menuItem)
+	item.desc = summary
+	m.list.SetItem(int(MenuJobs), item)
+	return m
+}
+
+// ConfirmQuit 还有 active 个排队中或进行中的任务时，退出前先请求确认
+func (m MenuModel) ConfirmQuit(active int) MenuModel {
+	m.confirmQuit = active
+	return m
+}
+
 func (m MenuModel) Init() tea.Cmd { return nil }
 
 func (m MenuModel) Update(msg tea.Msg) (MenuModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.confirmQuit > 0 {
+			switch msg.String() {
+			case "y", "enter":
+				m.confirmQuit = 0
+				return m, func() tea.Msg { return QuitConfirmedMsg{} }
+			case "n", "esc":
+				m.confirmQuit = 0
+			}
+			return m, nil
+		}
 		switch msg.String() {
 		case "enter":
 			m.choice = MenuChoice(m.list.Index())
@@ -117,6 +158,11 @@ func (m MenuModel) View() string {
 	b.WriteString("\n\n")
 	b.WriteString(m.list.View())
 	b.WriteString("\n")
+	if m.confirmQuit > 0 {
+		b.WriteString(theme.WarningStyle.Render(fmt.Sprintf("  还有 %d 个任务未完成，退出将取消这些任务并删除未写完的输出，确认退出？", m.confirmQuit)) +
+			"\n" + theme.HelpStyle.Render("  y/enter 取消任务并退出 · n/esc 继续"))
+		return b.String()
+	}
 	b.WriteString(theme.HelpStyle.Render("  ↑↓ 选择 · enter 确认 · q 退出"))
 	return b.String()
 }
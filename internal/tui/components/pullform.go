@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"dipt/internal/docker"
 	"dipt/internal/tui/theme"
 	"dipt/internal/types"
 
@@ -16,6 +17,14 @@ type StartPullMsg struct {
 	ImageName  string
 	OutputFile string
 	Platform   types.Platform
+	Format     docker.OutputFormat
+
+	// 多平台拉取，设置时忽略 Platform
+	AllPlatforms bool
+	Platforms    []types.Platform
+
+	// Background 为 true 时只加入任务队列，留在表单中继续填写下一个
+	Background bool
 }
 
 // BackToMenuMsg 返回菜单消息
@@ -29,6 +38,7 @@ const (
 	fieldOutput
 	fieldOS
 	fieldArch
+	fieldFormat
 )
 
 // PullFormModel 拉取表单
@@ -37,9 +47,15 @@ type PullFormModel struct {
 	outputInput textinput.Model
 	osIdx       int
 	archIdx     int
+	archMarked  map[int]bool // 空格勾选的架构，勾选多个时按多平台拉取
+	formatIdx   int
 	focused     pullFormField
 	userConfig  *types.UserConfig
 	err         string
+	notice      string // 提示信息，如上一次拉取已取消
+	tags        TagsModel
+	browsing    bool // 正在标签选择器中选择标签
+	size        tea.WindowSizeMsg
 }
 
 // NewPullFormModel 创建拉取表单
@@ -76,39 +92,97 @@ func NewPullFormModel(cfg *types.UserConfig) PullFormModel {
 		outputInput: outInput,
 		osIdx:       osIdx,
 		archIdx:     archIdx,
+		archMarked:  make(map[int]bool),
 		userConfig:  cfg,
 	}
 }
 
 func (m PullFormModel) Init() tea.Cmd { return textinput.Blink }
 
+// WithSuggestions 为镜像名称输入设置补全候选（如历史记录中拉取过的镜像），输入前缀后按 tab 补全
+func (m PullFormModel) WithSuggestions(refs []string) PullFormModel {
+	m.imageInput.ShowSuggestions = len(refs) > 0
+	m.imageInput.SetSuggestions(refs)
+	return m
+}
+
+// canComplete 镜像名称输入中是否有可以补全的候选
+func (m PullFormModel) canComplete() bool {
+	s := m.imageInput.CurrentSuggestion()
+	return m.focused == fieldImage && s != "" && s != m.imageInput.Value()
+}
+
+// WithNotice 返回带提示信息的表单，保留已填写的内容
+func (m PullFormModel) WithNotice(notice string) PullFormModel {
+	m.err = ""
+	m.notice = notice
+	return m
+}
+
 func (m PullFormModel) Update(msg tea.Msg) (PullFormModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.size = msg
+		m.tags, _ = m.tags.Update(msg)
+		return m, nil
+	case TagChosenMsg:
+		m.browsing = false
+		m.imageInput.SetValue(msg.Repository + ":" + msg.Tag)
+		m.imageInput.CursorEnd()
+		return m, nil
+	case TagsClosedMsg:
+		m.browsing = false
+		return m, nil
+	}
+	if m.browsing {
+		var cmd tea.Cmd
+		m.tags, cmd = m.tags.Update(msg)
+		return m, cmd
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
+		case "ctrl+t":
+			return m.browseTags()
 		case "esc":
 			return m, func() tea.Msg { return BackToMenuMsg{} }
 		case "tab", "shift+tab":
+			if msg.String() == "tab" && m.canComplete() {
+				// 交给输入框补全
+				break
+			}
 			return m.cycleFocus(msg.String() == "shift+tab"), nil
 		case "enter":
-			if m.focused == fieldArch {
-				return m.submit()
+			if m.focused == fieldFormat {
+				return m.submit(false)
 			}
 			return m.cycleFocus(false), nil
+		case "ctrl+b":
+			return m.submit(true)
 		case "left":
 			if m.focused == fieldOS && m.osIdx > 0 {
 				m.osIdx--
 			} else if m.focused == fieldArch && m.archIdx > 0 {
 				m.archIdx--
+			} else if m.focused == fieldFormat && m.formatIdx > 0 {
+				m.formatIdx--
 			}
 			return m, nil
 		case "right":
 			if m.focused == fieldOS && m.osIdx < len(osOptions)-1 {
 				m.osIdx++
-			} else if m.focused == fieldArch && m.archIdx < len(archOptions)-1 {
+			} else if m.focused == fieldArch && m.archIdx < len(archOptions) {
 				m.archIdx++
+			} else if m.focused == fieldFormat && m.formatIdx < len(docker.OutputFormats)-1 {
+				m.formatIdx++
 			}
 			return m, nil
+		case " ":
+			if m.focused == fieldArch && m.archIdx < len(archOptions) {
+				m.archMarked[m.archIdx] = !m.archMarked[m.archIdx]
+				return m, nil
+			}
 		}
 	}
 
@@ -122,13 +196,26 @@ func (m PullFormModel) Update(msg tea.Msg) (PullFormModel, tea.Cmd) {
 	return m, cmd
 }
 
+// browseTags 打开标签选择器，列出镜像名称所在仓库的标签
+func (m PullFormModel) browseTags() (PullFormModel, tea.Cmd) {
+	repo := repositoryOf(m.imageInput.Value())
+	if repo == "" {
+		m.err = "请先输入镜像仓库名称，如 nginx"
+		return m, nil
+	}
+	m.err = ""
+	m.browsing = true
+	m.tags = NewTagsModel(repo, m.size)
+	return m, tea.Batch(m.tags.Init(), func() tea.Msg { return ListTagsMsg{Repository: repo} })
+}
+
 func (m PullFormModel) cycleFocus(reverse bool) PullFormModel {
 	if reverse {
 		if m.focused > 0 {
 			m.focused--
 		}
 	} else {
-		if m.focused < fieldArch {
+		if m.focused < fieldFormat {
 			m.focused++
 		}
 	}
@@ -143,29 +230,55 @@ func (m PullFormModel) cycleFocus(reverse bool) PullFormModel {
 	return m
 }
 
-func (m PullFormModel) submit() (PullFormModel, tea.Cmd) {
+func (m PullFormModel) submit(background bool) (PullFormModel, tea.Cmd) {
 	imageName := strings.TrimSpace(m.imageInput.Value())
 	if imageName == "" {
 		m.err = "请输入镜像名称"
 		return m, nil
 	}
+	outputFile := strings.TrimSpace(m.outputInput.Value())
+	msg := StartPullMsg{
+		ImageName:  imageName,
+		OutputFile: outputFile,
+		Format:     docker.OutputFormats[m.formatIdx],
+		Background: background,
+	}
+	platforms := m.markedPlatforms()
+	switch {
+	case m.archIdx == len(archOptions):
+		msg.AllPlatforms = true
+	case len(platforms) > 1:
+		msg.Platforms = platforms
+	case len(platforms) == 1:
+		msg.Platform = platforms[0]
+	default:
+		msg.Platform = types.Platform{OS: osOptions[m.osIdx], Arch: archOptions[m.archIdx]}
+	}
+	if (msg.AllPlatforms || len(msg.Platforms) > 0) && msg.Format != docker.FormatOCI && msg.Format != docker.FormatOCIArchive {
+		m.err = "多平台拉取请选择 oci 或 oci-archive 格式"
+		return m, nil
+	}
 	m.err = ""
+	m.notice = ""
 
-	outputFile := strings.TrimSpace(m.outputInput.Value())
+	return m, func() tea.Msg { return msg }
+}
 
-	return m, func() tea.Msg {
-		return StartPullMsg{
-			ImageName:  imageName,
-			OutputFile: outputFile,
-			Platform: types.Platform{
-				OS:   osOptions[m.osIdx],
-				Arch: archOptions[m.archIdx],
-			},
+// markedPlatforms 返回空格勾选的平台（使用当前选择的操作系统）
+func (m PullFormModel) markedPlatforms() []types.Platform {
+	var platforms []types.Platform
+	for i, arch := range archOptions {
+		if m.archMarked[i] {
+			platforms = append(platforms, types.Platform{OS: osOptions[m.osIdx], Arch: arch})
 		}
 	}
+	return platforms
 }
 
 func (m PullFormModel) View() string {
+	if m.browsing {
+		return m.tags.View()
+	}
 	var b strings.Builder
 	b.WriteString(theme.TitleStyle.Render("  拉取镜像"))
 	b.WriteString("\n\n")
@@ -175,7 +288,11 @@ func (m PullFormModel) View() string {
 	if m.focused == fieldImage {
 		label = theme.HighlightStyle.Render(label)
 	}
-	b.WriteString(label + m.imageInput.View() + "\n\n")
+	b.WriteString(label + m.imageInput.View() + "\n")
+	if matches := m.imageInput.MatchedSuggestions(); m.focused == fieldImage && len(matches) > 1 {
+		b.WriteString(layerMutedStyle.Render(fmt.Sprintf("            历史中有 %d 个匹配，↑↓ 切换 · tab 补全", len(matches))))
+	}
+	b.WriteString("\n")
 
 	// 输出文件
 	label = "  输出文件: "
@@ -202,7 +319,10 @@ func (m PullFormModel) View() string {
 
 	// 架构选择
 	b.WriteString("  架构:     ")
-	for i, opt := range archOptions {
+	for i, opt := range append(archOptions[:len(archOptions):len(archOptions)], "全部平台") {
+		if m.archMarked[i] {
+			opt = "✓" + opt
+		}
 		if i == m.archIdx {
 			if m.focused == fieldArch {
 				b.WriteString(theme.SelectedStyle.Render("[" + opt + "]"))
@@ -214,11 +334,30 @@ func (m PullFormModel) View() string {
 		}
 		b.WriteString(" ")
 	}
+	b.WriteString("\n\n")
+
+	// 保存格式选择
+	b.WriteString("  保存格式: ")
+	for i, f := range docker.OutputFormats {
+		opt := string(f)
+		if i == m.formatIdx {
+			if m.focused == fieldFormat {
+				b.WriteString(theme.SelectedStyle.Render("[" + opt + "]"))
+			} else {
+				b.WriteString(theme.HighlightStyle.Render("[" + opt + "]"))
+			}
+		} else {
+			b.WriteString(fmt.Sprintf(" %s ", opt))
+		}
+		b.WriteString(" ")
+	}
 
 	if m.err != "" {
 		b.WriteString("\n\n" + theme.ErrorStyle.Render("  "+m.err))
+	} else if m.notice != "" {
+		b.WriteString("\n\n" + theme.InfoStyle.Render("  "+m.notice))
 	}
 
-	b.WriteString("\n\n" + theme.HelpStyle.Render("  tab 切换字段 · ←→ 选择平台 · enter 开始拉取 · esc 返回"))
+	b.WriteString("\n\n" + theme.HelpStyle.Render("  tab 切换字段/补全 · ctrl+t 浏览标签 · ←→ 选择平台/格式 · 空格 勾选多个架构 · enter 开始拉取 · ctrl+b 加入队列 · esc 返回"))
 	return b.String()
 }
@@ -3,7 +3,10 @@ package components
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"dipt/internal/docker"
+	"dipt/internal/errors"
 	"dipt/internal/tui/theme"
 
 	"github.com/charmbracelet/bubbles/progress"
@@ -19,15 +22,24 @@ type ProgressMsg struct {
 	Total      int64
 }
 
+// WrittenMsg 输出文件写入进度消息（压缩后写入磁盘的字节数）
+type WrittenMsg struct {
+	Written int64
+}
+
 // LogMsg 日志消息
 type LogMsg struct {
 	Level   string
 	Message string
 }
 
+// CancelPullMsg 确认取消进行中的拉取
+type CancelPullMsg struct{}
+
 // PullDoneMsg 拉取完成消息
 type PullDoneMsg struct {
-	Err error
+	Result *docker.PullResult
+	Err    error
 }
 
 // PullProgressModel 拉取进度视图
@@ -38,10 +50,21 @@ type PullProgressModel struct {
 	logs       []string
 	downloaded int64
 	total      int64
+	written    int64
+	layers     []docker.LayerProgress
+	meter      speedMeter
 	done       bool
 	err        error
 	imageName  string
+	action     string // 拉取或复制，用于标题与状态文字
+	cancelable bool   // 进行中可按 esc/ctrl+x 取消
+	background bool   // 进行中可按 b 转到后台（任务队列中的拉取）
+	cancelNote string // 确认取消时的提示
+	queued     bool   // 在任务队列中等待开始
+	confirming bool   // 等待确认取消
+	canceling  bool   // 已请求取消，等待拉取结束
 	width      int
+	height     int
 }
 
 // NewPullProgressModel 创建进度视图
@@ -62,14 +85,28 @@ func NewPullProgressModel(imageName string) PullProgressModel {
 		Padding(0, 1)
 
 	return PullProgressModel{
-		spinner:   s,
-		progress:  p,
-		viewport:  vp,
-		imageName: imageName,
-		width:     60,
+		spinner:    s,
+		progress:   p,
+		viewport:   vp,
+		imageName:  imageName,
+		action:     "拉取",
+		cancelable: true,
+		background: true,
+		cancelNote: "未写完的输出将被删除，已下载的层保留在缓存中",
+		width:      60,
 	}
 }
 
+// NewCopyProgressModel 创建仓库间复制的进度视图
+func NewCopyProgressModel(source, destination string) PullProgressModel {
+	m := NewPullProgressModel(source + " -> " + destination)
+	m.action = "复制"
+	// 复制不在任务队列中，不能转到后台
+	m.background = false
+	m.cancelNote = "目标仓库中已上传的 blob 会保留，再次复制时不会重复上传"
+	return m
+}
+
 func (m PullProgressModel) Init() tea.Cmd {
 	return m.spinner.Tick
 }
@@ -80,7 +117,9 @@ func (m PullProgressModel) Update(msg tea.Msg) (PullProgressModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width - 4
+		m.height = msg.Height
 		m.viewport.Width = m.width
+		m.viewport.Height = max(4, min(10, msg.Height/4))
 		m.progress = progress.New(
 			progress.WithGradient(string(theme.ColorPrimary), string(theme.ColorSecondary)),
 			progress.WithWidth(m.width-10),
@@ -88,31 +127,65 @@ func (m PullProgressModel) Update(msg tea.Msg) (PullProgressModel, tea.Cmd) {
 	case ProgressMsg:
 		m.downloaded = msg.Downloaded
 		m.total = msg.Total
+		m.meter.add(msg.Downloaded, time.Now())
 		if m.total > 0 {
 			pct := float64(m.downloaded) / float64(m.total)
 			cmds = append(cmds, m.progress.SetPercent(pct))
 		}
+	case LayersMsg:
+		m.layers = msg.Layers
+		var cached int64
+		for _, l := range m.layers {
+			if l.State == docker.LayerCached {
+				cached += l.Total
+			}
+		}
+		m.meter.skip(cached)
+	case WrittenMsg:
+		m.written = msg.Written
 	case LogMsg:
-		styled := m.styleLog(msg.Level, msg.Message)
+		styled := styleLog(msg.Level, msg.Message)
 		m.logs = append(m.logs, styled)
 		m.viewport.SetContent(strings.Join(m.logs, "\n"))
 		m.viewport.GotoBottom()
 	case PullDoneMsg:
-		m.done = true
-		m.err = msg.Err
-		if msg.Err != nil {
-			m.logs = append(m.logs, theme.ErrorStyle.Render("拉取失败: "+msg.Err.Error()))
-		} else {
-			m.logs = append(m.logs, theme.SuccessStyle.Render("拉取完成!"))
+		var digest, mirror string
+		if r := msg.Result; r != nil {
+			digest, mirror = r.ManifestDigest, r.Mirror
 		}
-		m.viewport.SetContent(strings.Join(m.logs, "\n"))
-		m.viewport.GotoBottom()
+		m.finish(msg.Err, digest, mirror)
+	case CopyDoneMsg:
+		var digest, mirror string
+		if r := msg.Result; r != nil {
+			digest, mirror = r.Digest, r.Mirror
+		}
+		m.finish(msg.Err, digest, mirror)
 	case tea.KeyMsg:
 		if m.done {
 			switch msg.String() {
 			case "enter", "esc":
 				return m, func() tea.Msg { return BackToMenuMsg{} }
 			}
+			break
+		}
+		if m.confirming {
+			switch msg.String() {
+			case "y", "enter":
+				m.confirming = false
+				m.canceling = true
+				return m, func() tea.Msg { return CancelPullMsg{} }
+			case "n", "esc":
+				m.confirming = false
+			}
+			break
+		}
+		switch msg.String() {
+		case "esc", "ctrl+x":
+			m.confirming = m.cancelable && !m.canceling
+		case "b":
+			if m.background {
+				return m, func() tea.Msg { return BackToMenuMsg{} }
+			}
 		}
 	case spinner.TickMsg:
 		var cmd tea.Cmd
@@ -127,7 +200,29 @@ func (m PullProgressModel) Update(msg tea.Msg) (PullProgressModel, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
-func (m PullProgressModel) styleLog(level, message string) string {
+// finish 记录结束状态与结果摘要
+func (m *PullProgressModel) finish(err error, digest, mirror string) {
+	m.done = true
+	m.err = err
+	if errors.IsCanceledError(err) {
+		m.logs = append(m.logs, theme.WarningStyle.Render(m.action+"已取消"))
+	} else if err != nil {
+		m.logs = append(m.logs, theme.ErrorStyle.Render(m.action+"失败: "+err.Error()))
+	} else {
+		if digest != "" {
+			m.logs = append(m.logs, styleLog("info", "Digest: "+digest))
+			if mirror != "" {
+				m.logs = append(m.logs, styleLog("info", "镜像源: "+mirror))
+			}
+		}
+		m.logs = append(m.logs, theme.SuccessStyle.Render(m.action+"完成!"))
+	}
+	m.viewport.SetContent(strings.Join(m.logs, "\n"))
+	m.viewport.GotoBottom()
+}
+
+// styleLog 按日志级别渲染日志行
+func styleLog(level, message string) string {
 	switch level {
 	case "debug":
 		return theme.LogDebugStyle.Render("[DEBUG] " + message)
@@ -146,37 +241,88 @@ func (m PullProgressModel) styleLog(level, message string) string {
 
 func (m PullProgressModel) View() string {
 	var b strings.Builder
-	b.WriteString(theme.TitleStyle.Render("  拉取镜像"))
+	b.WriteString(theme.TitleStyle.Render("  " + m.action + "镜像"))
 	b.WriteString("\n\n")
 
-	if !m.done {
-		b.WriteString(fmt.Sprintf("  %s 正在拉取 %s\n\n",
-			m.spinner.View(),
+	if m.queued {
+		b.WriteString(fmt.Sprintf("  %s 排队等待%s %s（其他任务完成后开始）\n\n",
+			layerMutedStyle.Render("…"), m.action,
+			theme.HighlightStyle.Render(m.imageName)))
+	} else if m.canceling && !m.done {
+		b.WriteString(fmt.Sprintf("  %s 正在取消%s %s\n\n",
+			m.spinner.View(), m.action,
 			theme.HighlightStyle.Render(m.imageName)))
+	} else if !m.done {
+		b.WriteString(fmt.Sprintf("  %s 正在%s %s\n\n",
+			m.spinner.View(), m.action,
+			theme.HighlightStyle.Render(m.imageName)))
+	} else if errors.IsCanceledError(m.err) {
+		b.WriteString(fmt.Sprintf("  %s %s已取消\n\n",
+			theme.WarningStyle.Render("✗"), m.action))
 	} else if m.err != nil {
-		b.WriteString(fmt.Sprintf("  %s 拉取失败\n\n",
-			theme.ErrorStyle.Render("✗")))
+		b.WriteString(fmt.Sprintf("  %s %s失败\n\n",
+			theme.ErrorStyle.Render("✗"), m.action))
 	} else {
-		b.WriteString(fmt.Sprintf("  %s 拉取完成\n\n",
-			theme.SuccessStyle.Render("✓")))
+		b.WriteString(fmt.Sprintf("  %s %s完成\n\n",
+			theme.SuccessStyle.Render("✓"), m.action))
 	}
 
 	// 进度条
 	if m.total > 0 {
 		b.WriteString("  " + m.progress.View() + "\n")
-		b.WriteString(fmt.Sprintf("  %s / %s\n\n",
-			formatBytes(m.downloaded), formatBytes(m.total)))
+		b.WriteString(fmt.Sprintf("  %s / %s%s\n",
+			formatBytes(m.downloaded), formatBytes(m.total), writtenSuffix(m.written)))
+		if !m.done {
+			now := time.Now()
+			stats := fmt.Sprintf("速度 %s · 平均 %s", formatRate(m.meter.rate(now)), formatRate(m.meter.overall(m.downloaded, now)))
+			if eta, ok := m.meter.eta(m.downloaded, m.total, now); ok {
+				stats += " · 剩余 " + formatETA(eta)
+			}
+			b.WriteString("  " + theme.SubtitleStyle.Render(stats) + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	// 各层进度
+	if len(m.layers) > 0 {
+		b.WriteString(renderLayers(m.layers, m.width, m.layerRows()) + "\n")
 	}
 
 	// 日志视图 — 需要对每行缩进，否则边框只有首行偏移
 	b.WriteString("  " + strings.ReplaceAll(m.viewport.View(), "\n", "\n  ") + "\n")
 
-	if m.done {
-		b.WriteString("\n" + theme.HelpStyle.Render("  enter/esc 返回菜单"))
+	switch {
+	case m.done:
+		b.WriteString("\n" + theme.HelpStyle.Render("  enter/esc 返回"))
+	case m.confirming:
+		b.WriteString("\n" + theme.WarningStyle.Render("  确认取消"+m.action+"？"+m.cancelNote) +
+			"\n" + theme.HelpStyle.Render("  y/enter 取消"+m.action+" · n/esc 继续"))
+	case m.cancelable && !m.canceling:
+		help := "  esc/ctrl+x 取消" + m.action
+		if m.background {
+			help = "  b 转到后台 ·" + help
+		}
+		b.WriteString("\n" + theme.HelpStyle.Render(help))
 	}
 	return b.String()
 }
 
+// layerRows 返回各层进度表可用的行数：终端高度减去标题、进度条、日志与帮助所占的行
+func (m PullProgressModel) layerRows() int {
+	if m.height <= 0 {
+		return 8
+	}
+	return max(3, m.height-m.viewport.Height-17)
+}
+
+// writtenSuffix 返回进度行末尾的已写入字节数说明
+func writtenSuffix(written int64) string {
+	if written <= 0 {
+		return ""
+	}
+	return " · 已写入 " + formatBytes(written)
+}
+
 func formatBytes(b int64) string {
 	const unit = 1024
 	if b < unit {
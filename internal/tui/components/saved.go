@@ -0,0 +1,172 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"dipt/internal/docker"
+	"dipt/internal/tui/theme"
+	"dipt/internal/types"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// SavedModel 已保存镜像视图，读取保存目录中的 .dipt.json 元数据文件
+type SavedModel struct {
+	table  table.Model
+	dir    string
+	items  []*docker.Metadata
+	detail bool
+	err    string
+}
+
+// NewSavedModel 创建已保存镜像视图
+func NewSavedModel(cfg *types.UserConfig) SavedModel {
+	m := SavedModel{}
+	if cfg != nil {
+		m.dir = cfg.DefaultSaveDir
+	}
+	m.load()
+	return m
+}
+
+// load 重新读取保存目录中的元数据
+func (m *SavedModel) load() {
+	items, err := docker.ListMetadata(m.dir)
+	m.items, m.err = items, ""
+	if err != nil {
+		m.err = err.Error()
+	}
+	m.table = m.buildTable()
+}
+
+func (m SavedModel) buildTable() table.Model {
+	columns := []table.Column{
+		{Title: "文件", Width: 30},
+		{Title: "镜像", Width: 34},
+		{Title: "平台", Width: 14},
+		{Title: "拉取时间", Width: 16},
+	}
+
+	rows := make([]table.Row, len(m.items))
+	for i, md := range m.items {
+		ref := md.Reference
+		if len(md.Images) > 0 {
+			ref = fmt.Sprintf("(%d 个镜像)", len(md.Images))
+		}
+		platform := md.PlatformLabel()
+		if platform == "" {
+			platform = "—"
+		}
+		rows[i] = table.Row{md.Output, ref, platform, md.PulledAt.Local().Format("2006-01-02 15:04")}
+	}
+
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithRows(rows),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(theme.ColorMuted).
+		BorderBottom(true).
+		Bold(true)
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(theme.ColorPrimary).
+		Bold(false)
+	t.SetStyles(s)
+
+	return t
+}
+
+func (m SavedModel) Init() tea.Cmd { return nil }
+
+func (m SavedModel) Update(msg tea.Msg) (SavedModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		if m.detail {
+			switch msg.String() {
+			case "esc", "enter":
+				m.detail = false
+			}
+			return m, nil
+		}
+		switch msg.String() {
+		case "esc":
+			return m, func() tea.Msg { return BackToMenuMsg{} }
+		case "enter":
+			if len(m.items) > 0 {
+				m.detail = true
+			}
+			return m, nil
+		case "r":
+			m.load()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+func (m SavedModel) View() string {
+	var b strings.Builder
+	b.WriteString(theme.TitleStyle.Render("  已保存镜像"))
+	b.WriteString("\n\n")
+
+	if m.detail {
+		b.WriteString(m.detailView())
+		b.WriteString("\n" + theme.HelpStyle.Render("  esc 返回列表"))
+		return b.String()
+	}
+
+	dir := m.dir
+	if dir == "" {
+		dir = "当前目录"
+	}
+	b.WriteString(theme.SubtitleStyle.Render("  保存目录: "+dir) + "\n\n")
+	switch {
+	case m.err != "":
+		b.WriteString("  " + theme.ErrorStyle.Render(m.err) + "\n")
+	case len(m.items) == 0:
+		b.WriteString("  暂无带元数据的已保存镜像\n")
+	default:
+		b.WriteString("  " + m.table.View() + "\n")
+	}
+	b.WriteString("\n" + theme.HelpStyle.Render("  enter 详情 · r 刷新 · esc 返回"))
+	return b.String()
+}
+
+// detailView 渲染选中镜像的元数据
+func (m SavedModel) detailView() string {
+	idx := m.table.Cursor()
+	if idx < 0 || idx >= len(m.items) {
+		return ""
+	}
+	md := m.items[idx]
+	keyStyle := lipgloss.NewStyle().Foreground(theme.ColorMuted).Width(12)
+
+	var b strings.Builder
+	for _, f := range md.Fields() {
+		b.WriteString("  " + keyStyle.Render(f[0]) + f[1] + "\n")
+	}
+	if labels := md.SortedLabels(); len(labels) > 0 {
+		b.WriteString("\n  " + theme.HighlightStyle.Render("标签") + "\n")
+		for _, l := range labels {
+			b.WriteString("    " + l + "\n")
+		}
+	}
+	if len(md.Images) > 0 {
+		b.WriteString("\n  " + theme.HighlightStyle.Render(fmt.Sprintf("包含 %d 个镜像", len(md.Images))) + "\n")
+		for _, img := range md.Images {
+			b.WriteString(fmt.Sprintf("    %s (%s)\n", img.Reference, img.PlatformLabel()))
+		}
+	}
+	return b.String()
+}
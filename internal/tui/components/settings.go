@@ -2,9 +2,11 @@ package components
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"dipt/internal/config"
+	"dipt/internal/docker"
 	"dipt/internal/tui/theme"
 	"dipt/internal/types"
 
@@ -21,6 +23,10 @@ const (
 	settingsSaveDir
 	settingsUsername
 	settingsPassword
+	settingsConcurrency
+	settingsBandwidth
+	settingsBurst
+	settingsJobs
 	settingsSave
 )
 
@@ -32,6 +38,10 @@ type SettingsModel struct {
 	dirInput      textinput.Model
 	usernameInput textinput.Model
 	passwordInput textinput.Model
+	parallelInput textinput.Model
+	rateInput     textinput.Model
+	burstInput    textinput.Model
+	jobsInput     textinput.Model
 	userConfig    *types.UserConfig
 	message       string
 	isError       bool
@@ -58,6 +68,34 @@ func NewSettingsModel(cfg *types.UserConfig) SettingsModel {
 	passInput.EchoCharacter = '*'
 	passInput.Placeholder = "留空表示匿名访问"
 
+	parallelInput := textinput.New()
+	if cfg.Transfer.Concurrency > 0 {
+		parallelInput.SetValue(strconv.Itoa(cfg.Transfer.Concurrency))
+	}
+	parallelInput.CharLimit = 4
+	parallelInput.Width = 50
+	parallelInput.Placeholder = fmt.Sprintf("留空表示默认 (%d)", docker.DefaultConcurrency)
+
+	rateInput := textinput.New()
+	rateInput.SetValue(config.FormatRate(cfg.Transfer.BandwidthLimit))
+	rateInput.CharLimit = 32
+	rateInput.Width = 50
+	rateInput.Placeholder = "每秒，如 10M、512K，留空表示不限速"
+
+	burstInput := textinput.New()
+	burstInput.SetValue(config.FormatRate(cfg.Transfer.BandwidthBurst))
+	burstInput.CharLimit = 32
+	burstInput.Width = 50
+	burstInput.Placeholder = "如 4M，留空表示上限的 1/10"
+
+	jobsInput := textinput.New()
+	if cfg.Transfer.MaxJobs > 0 {
+		jobsInput.SetValue(strconv.Itoa(cfg.Transfer.MaxJobs))
+	}
+	jobsInput.CharLimit = 4
+	jobsInput.Width = 50
+	jobsInput.Placeholder = fmt.Sprintf("任务队列同时拉取的镜像数，留空表示默认 (%d)", DefaultMaxJobs)
+
 	osIdx := 0
 	for i, o := range osOptions {
 		if o == cfg.DefaultOS {
@@ -79,6 +117,10 @@ func NewSettingsModel(cfg *types.UserConfig) SettingsModel {
 		dirInput:      ti,
 		usernameInput: userInput,
 		passwordInput: passInput,
+		parallelInput: parallelInput,
+		rateInput:     rateInput,
+		burstInput:    burstInput,
+		jobsInput:     jobsInput,
 		userConfig:    cfg,
 	}
 }
@@ -135,6 +177,26 @@ func (m SettingsModel) Update(msg tea.Msg) (SettingsModel, tea.Cmd) {
 		m.passwordInput, cmd = m.passwordInput.Update(msg)
 		return m, cmd
 	}
+	if m.focused == settingsConcurrency {
+		var cmd tea.Cmd
+		m.parallelInput, cmd = m.parallelInput.Update(msg)
+		return m, cmd
+	}
+	if m.focused == settingsBandwidth {
+		var cmd tea.Cmd
+		m.rateInput, cmd = m.rateInput.Update(msg)
+		return m, cmd
+	}
+	if m.focused == settingsBurst {
+		var cmd tea.Cmd
+		m.burstInput, cmd = m.burstInput.Update(msg)
+		return m, cmd
+	}
+	if m.focused == settingsJobs {
+		var cmd tea.Cmd
+		m.jobsInput, cmd = m.jobsInput.Update(msg)
+		return m, cmd
+	}
 	return m, nil
 }
 
@@ -142,6 +204,10 @@ func (m SettingsModel) nextField() SettingsModel {
 	m.dirInput.Blur()
 	m.usernameInput.Blur()
 	m.passwordInput.Blur()
+	m.parallelInput.Blur()
+	m.rateInput.Blur()
+	m.burstInput.Blur()
+	m.jobsInput.Blur()
 	if m.focused < settingsSave {
 		m.focused++
 	}
@@ -152,6 +218,14 @@ func (m SettingsModel) nextField() SettingsModel {
 		m.usernameInput.Focus()
 	case settingsPassword:
 		m.passwordInput.Focus()
+	case settingsConcurrency:
+		m.parallelInput.Focus()
+	case settingsBandwidth:
+		m.rateInput.Focus()
+	case settingsBurst:
+		m.burstInput.Focus()
+	case settingsJobs:
+		m.jobsInput.Focus()
 	}
 	return m
 }
@@ -160,6 +234,10 @@ func (m SettingsModel) prevField() SettingsModel {
 	m.dirInput.Blur()
 	m.usernameInput.Blur()
 	m.passwordInput.Blur()
+	m.parallelInput.Blur()
+	m.rateInput.Blur()
+	m.burstInput.Blur()
+	m.jobsInput.Blur()
 	if m.focused > 0 {
 		m.focused--
 	}
@@ -170,11 +248,35 @@ func (m SettingsModel) prevField() SettingsModel {
 		m.usernameInput.Focus()
 	case settingsPassword:
 		m.passwordInput.Focus()
+	case settingsConcurrency:
+		m.parallelInput.Focus()
+	case settingsBandwidth:
+		m.rateInput.Focus()
+	case settingsBurst:
+		m.burstInput.Focus()
+	case settingsJobs:
+		m.jobsInput.Focus()
 	}
 	return m
 }
 
 func (m SettingsModel) save() (SettingsModel, tea.Cmd) {
+	concurrency, err := config.ParseConcurrency(m.parallelInput.Value())
+	if err == nil {
+		m.userConfig.Transfer.BandwidthLimit, err = config.ParseRate(m.rateInput.Value())
+	}
+	if err == nil {
+		m.userConfig.Transfer.BandwidthBurst, err = config.ParseRate(m.burstInput.Value())
+	}
+	if err == nil {
+		m.userConfig.Transfer.MaxJobs, err = config.ParseConcurrency(m.jobsInput.Value())
+	}
+	if err != nil {
+		m.message = err.Error()
+		m.isError = true
+		return m, nil
+	}
+	m.userConfig.Transfer.Concurrency = concurrency
 	m.userConfig.DefaultOS = osOptions[m.osIdx]
 	m.userConfig.DefaultArch = archOptions[m.archIdx]
 	dir := strings.TrimSpace(m.dirInput.Value())
@@ -252,6 +354,31 @@ func (m SettingsModel) View() string {
 	}
 	b.WriteString(passLabel + m.passwordInput.View() + "\n\n")
 
+	// Transfer
+	parallelLabel := "  同时下载层数: "
+	if m.focused == settingsConcurrency {
+		parallelLabel = theme.HighlightStyle.Render(parallelLabel)
+	}
+	b.WriteString(parallelLabel + m.parallelInput.View() + "\n\n")
+
+	rateLabel := "  带宽上限:     "
+	if m.focused == settingsBandwidth {
+		rateLabel = theme.HighlightStyle.Render(rateLabel)
+	}
+	b.WriteString(rateLabel + m.rateInput.View() + "\n\n")
+
+	burstLabel := "  突发流量:     "
+	if m.focused == settingsBurst {
+		burstLabel = theme.HighlightStyle.Render(burstLabel)
+	}
+	b.WriteString(burstLabel + m.burstInput.View() + "\n\n")
+
+	jobsLabel := "  同时拉取任务: "
+	if m.focused == settingsJobs {
+		jobsLabel = theme.HighlightStyle.Render(jobsLabel)
+	}
+	b.WriteString(jobsLabel + m.jobsInput.View() + "\n\n")
+
 	// Save button
 	if m.focused == settingsSave {
 		b.WriteString("  " + theme.SelectedStyle.Render("[ 保存设置 ]"))
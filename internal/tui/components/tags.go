@@ -0,0 +1,303 @@
+package components
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"dipt/internal/docker"
+	"dipt/internal/tui/theme"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ListTagsMsg 请求获取仓库的标签列表
+type ListTagsMsg struct {
+	Repository string
+}
+
+// TagsLoadedMsg 标签列表获取完成
+type TagsLoadedMsg struct {
+	Repository string
+	List       *docker.TagList
+	Err        error
+}
+
+// TagChosenMsg 在标签选择器中选中了标签
+type TagChosenMsg struct {
+	Repository string
+	Tag        string
+}
+
+// TagsClosedMsg 关闭标签选择器，不修改镜像名称
+type TagsClosedMsg struct{}
+
+// tagMatch 过滤后的一个标签及匹配到的字符位置
+type tagMatch struct {
+	tag       string
+	positions []int
+	score     int
+}
+
+// TagsModel 标签选择器：模糊过滤、按版本排序并分页显示
+type TagsModel struct {
+	repository string
+	spinner    spinner.Model
+	loading    bool
+	tags       []string // 按版本排序的全部标签
+	mirror     string
+	filter     textinput.Model
+	matches    []tagMatch
+	cursor     int
+	err        string
+	size       tea.WindowSizeMsg
+}
+
+// NewTagsModel 创建标签选择器，标签列表由 TagsLoadedMsg 填充
+func NewTagsModel(repository string, size tea.WindowSizeMsg) TagsModel {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(theme.ColorPrimary)
+
+	filter := textinput.New()
+	filter.Prompt = "过滤: "
+	filter.Placeholder = "输入部分字符模糊匹配，如 127alp"
+	filter.CharLimit = 128
+	filter.Width = 40
+	filter.Focus()
+
+	return TagsModel{
+		repository: repository,
+		spinner:    s,
+		loading:    true,
+		filter:     filter,
+		size:       size,
+	}
+}
+
+func (m TagsModel) Init() tea.Cmd {
+	return tea.Batch(m.spinner.Tick, textinput.Blink)
+}
+
+// pageSize 每页显示的标签数
+func (m TagsModel) pageSize() int {
+	if m.size.Height == 0 {
+		return 15
+	}
+	return max(5, m.size.Height-14)
+}
+
+// applyFilter 按过滤词重新匹配标签，光标回到第一项
+func (m *TagsModel) applyFilter() {
+	m.matches = fuzzyFilter(m.tags, m.filter.Value())
+	m.cursor = 0
+}
+
+func (m TagsModel) Update(msg tea.Msg) (TagsModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.size = msg
+		return m, nil
+	case spinner.TickMsg:
+		if !m.loading {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case TagsLoadedMsg:
+		if msg.Repository != m.repository {
+			return m, nil
+		}
+		m.loading = false
+		if msg.Err != nil {
+			m.err = msg.Err.Error()
+			return m, nil
+		}
+		m.err = ""
+		m.tags, m.mirror = msg.List.Tags, msg.List.Mirror
+		m.applyFilter()
+		return m, nil
+	case tea.KeyMsg:
+		page := m.pageSize()
+		switch msg.String() {
+		case "esc":
+			if m.filter.Value() != "" {
+				m.filter.SetValue("")
+				m.applyFilter()
+				return m, nil
+			}
+			return m, func() tea.Msg { return TagsClosedMsg{} }
+		case "enter":
+			if m.cursor < len(m.matches) {
+				repo, tag := m.repository, m.matches[m.cursor].tag
+				return m, func() tea.Msg { return TagChosenMsg{Repository: repo, Tag: tag} }
+			}
+			return m, nil
+		case "ctrl+r":
+			if m.loading {
+				return m, nil
+			}
+			m.loading, m.err = true, ""
+			repo := m.repository
+			return m, tea.Batch(m.spinner.Tick, func() tea.Msg { return ListTagsMsg{Repository: repo} })
+		case "up", "ctrl+p":
+			m.cursor = max(0, m.cursor-1)
+			return m, nil
+		case "down", "ctrl+n":
+			m.cursor = max(0, min(len(m.matches)-1, m.cursor+1))
+			return m, nil
+		case "pgup", "ctrl+u":
+			m.cursor = max(0, (m.cursor/page-1)*page)
+			return m, nil
+		case "pgdown", "ctrl+d":
+			if next := (m.cursor/page + 1) * page; next < len(m.matches) {
+				m.cursor = next
+			}
+			return m, nil
+		}
+		var cmd tea.Cmd
+		before := m.filter.Value()
+		m.filter, cmd = m.filter.Update(msg)
+		if m.filter.Value() != before {
+			m.applyFilter()
+		}
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m TagsModel) View() string {
+	var b strings.Builder
+	b.WriteString(theme.TitleStyle.Render("  选择标签"))
+	b.WriteString("\n\n")
+	source := "仓库: " + m.repository
+	if m.mirror != "" {
+		source += " · 镜像源: " + m.mirror
+	}
+	b.WriteString(theme.SubtitleStyle.Render("  "+source) + "\n\n")
+
+	switch {
+	case m.loading:
+		b.WriteString(fmt.Sprintf("  %s 正在获取标签列表…\n", m.spinner.View()))
+		b.WriteString("\n" + theme.HelpStyle.Render("  esc 返回"))
+		return b.String()
+	case m.err != "":
+		b.WriteString("  " + theme.ErrorStyle.Render("获取标签失败: "+strings.SplitN(m.err, "\n", 2)[0]) + "\n")
+		b.WriteString("\n" + theme.HelpStyle.Render("  ctrl+r 重试 · esc 返回"))
+		return b.String()
+	}
+
+	b.WriteString("  " + m.filter.View() + "\n")
+	page, pageSize := m.cursor/m.pageSize(), m.pageSize()
+	pages := max(1, (len(m.matches)+pageSize-1)/pageSize)
+	stats := fmt.Sprintf("共 %d 个标签", len(m.tags))
+	if m.filter.Value() != "" {
+		stats += fmt.Sprintf(" · 匹配 %d 个", len(m.matches))
+	}
+	stats += fmt.Sprintf(" · 第 %d/%d 页", page+1, pages)
+	b.WriteString(layerMutedStyle.Render("  "+stats) + "\n\n")
+
+	if len(m.matches) == 0 {
+		b.WriteString("  没有匹配的标签\n")
+	}
+	end := min(len(m.matches), (page+1)*pageSize)
+	for i := page * pageSize; i < end; i++ {
+		tag := highlightMatch(m.matches[i].tag, m.matches[i].positions)
+		if i == m.cursor {
+			b.WriteString(theme.SelectedStyle.Render("▸") + " " + tag + "\n")
+		} else {
+			b.WriteString("  " + tag + "\n")
+		}
+	}
+
+	b.WriteString("\n" + theme.HelpStyle.Render("  输入过滤 · ↑↓ 选择 · pgup/pgdn 翻页 · enter 使用该标签 · ctrl+r 刷新 · esc 清除过滤/返回"))
+	return b.String()
+}
+
+// highlightMatch 高亮标签中模糊匹配到的字符
+func highlightMatch(tag string, positions []int) string {
+	if len(positions) == 0 {
+		return tag
+	}
+	marked := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		marked[p] = true
+	}
+	var b strings.Builder
+	for i, r := range []rune(tag) {
+		if marked[i] {
+			b.WriteString(theme.HighlightStyle.Render(string(r)))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// fuzzyFilter 返回按顺序包含过滤词全部字符的标签，匹配度高的在前，匹配度相同时保持原有（版本）顺序
+func fuzzyFilter(tags []string, pattern string) []tagMatch {
+	pattern = strings.TrimSpace(pattern)
+	matches := make([]tagMatch, 0, len(tags))
+	for _, tag := range tags {
+		if pattern == "" {
+			matches = append(matches, tagMatch{tag: tag})
+			continue
+		}
+		if score, positions, ok := fuzzyMatch(pattern, tag); ok {
+			matches = append(matches, tagMatch{tag: tag, positions: positions, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	return matches
+}
+
+// fuzzyMatch 判断 s 是否按顺序包含 pattern 的全部字符（不区分大小写），返回匹配度与匹配位置
+// 连续匹配、位于开头或分隔符（. - _）之后的匹配得分更高，整段子串匹配额外加分
+func fuzzyMatch(pattern, s string) (int, []int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	r := []rune(strings.ToLower(s))
+	positions := make([]int, 0, len(p))
+	score, j := 0, 0
+	for i := 0; i < len(r) && j < len(p); i++ {
+		if r[i] != p[j] {
+			continue
+		}
+		score++
+		switch {
+		case i == 0:
+			score += 8
+		case len(positions) > 0 && positions[len(positions)-1] == i-1:
+			score += 5
+		case !unicode.IsLetter(r[i-1]) && !unicode.IsDigit(r[i-1]):
+			score += 3
+		}
+		positions = append(positions, i)
+		j++
+	}
+	if j < len(p) {
+		return 0, nil, false
+	}
+	if strings.Contains(string(r), string(p)) {
+		score += 10
+	}
+	// 越短的标签越接近过滤词
+	score -= (len(r) - len(p)) / 4
+	return score, positions, true
+}
+
+// repositoryOf 去掉镜像名称中的 tag 与 digest，得到仓库名
+func repositoryOf(image string) string {
+	image = strings.TrimSpace(image)
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
@@ -3,6 +3,7 @@ package types
 // Config 定义 JSON 配置文件的结构
 type Config struct {
 	Registry Registry `json:"registry"`
+	Transfer Transfer `json:"transfer"`
 }
 
 // Registry 镜像仓库配置
@@ -10,12 +11,23 @@ type Registry struct {
 	Mirrors  []string `json:"mirrors,omitempty"`
 	Username string   `json:"username,omitempty"`
 	Password string   `json:"password,omitempty"`
+	// Server 用户名和密码所属的仓库，为空时为 Docker Hub
+	// 推送时只有目标是该仓库才会使用这组凭据
+	Server string `json:"server,omitempty"`
+}
+
+// Transfer 下载并发与限速配置，零值表示使用默认值（不限速）
+type Transfer struct {
+	Concurrency    int   `json:"concurrency,omitempty"`     // 每次拉取同时下载的层数
+	BandwidthLimit int64 `json:"bandwidth_limit,omitempty"` // 全局带宽上限（字节/秒）
+	BandwidthBurst int64 `json:"bandwidth_burst,omitempty"` // 允许瞬时超出上限的字节数
+	MaxJobs        int   `json:"max_jobs,omitempty"`        // TUI 任务队列中同时进行的拉取数
 }
 
 // Platform 定义平台信息
 type Platform struct {
-	OS   string
-	Arch string
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
 }
 
 // UserConfig 用户配置结构
@@ -24,4 +36,5 @@ type UserConfig struct {
 	DefaultArch    string   `json:"default_arch"`     // 默认架构
 	DefaultSaveDir string   `json:"default_save_dir"` // 默认保存目录
 	Registry       Registry `json:"registry"`         // 镜像仓库配置
+	Transfer       Transfer `json:"transfer"`         // 下载并发与限速
 }
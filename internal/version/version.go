@@ -0,0 +1,4 @@
+package version
+
+// Version 当前版本号，构建时通过 -ldflags "-X dipt/internal/version.Version=..." 注入
+var Version = "dev"
@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"os"
 
+	"dipt/internal/cli"
 	"dipt/internal/tui"
 )
 
 func main() {
+	// 带参数时进入命令行模式，否则启动 TUI
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
 	if err := tui.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
 		os.Exit(1)